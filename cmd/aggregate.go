@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"io/fs"
 	"io/ioutil"
+	"log"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -14,11 +16,13 @@ var (
 	aggregateInputDirname   string
 	aggregateOutputFilename string
 	aggregationDate         int64
+	aggregateStoreDirname   string
 )
 
 func init() {
 	aggregateCmd.Flags().StringVarP(&aggregateInputDirname, "input", "i", "results", "Directory containing all results JSON files to aggregate")
 	aggregateCmd.Flags().StringVarP(&aggregateOutputFilename, "output", "o", "results.json", "Output filename for aggregated results")
+	aggregateCmd.Flags().StringVarP(&aggregateStoreDirname, "store", "s", "", "Append-only result store directory to add the results to, empty to skip")
 	aggregateCmd.Flags().Int64VarP(&aggregationDate, "date", "d", time.Now().Unix(), "Unix Timestamp in seconds since epoch")
 	rootCmd.AddCommand(aggregateCmd)
 }
@@ -27,14 +31,33 @@ var aggregateCmd = &cobra.Command{
 	Use:   "aggregate",
 	Short: "aggregate results",
 	RunE: func(*cobra.Command, []string) error {
-		return aggregate(aggregateInputDirname, aggregateOutputFilename)
+		return aggregate(aggregateInputDirname, aggregateOutputFilename, aggregateStoreDirname)
 	},
 }
 
-func aggregate(inputDirname, outputFilename string) error {
-	aggregated := make(map[string]map[string]Result)
+// runLogDirs are the directories of a run directory which contain logs.
+var runLogDirs = map[string]bool{
+	"output":        true,
+	"sender_logs":   true,
+	"receiver_logs": true,
+	crossTrafficDir: true,
+}
+
+// aggregate collects the results of all runs in inputDirname, keeping every
+// repetition of an implementation/testcase combination. If storeDirname is
+// not empty, the results are also added to the result store in storeDirname.
+func aggregate(inputDirname, outputFilename, storeDirname string) error {
+	aggregated := make(AggregatedResults)
 
 	err := filepath.Walk(inputDirname, func(path string, info fs.FileInfo, _ error) error {
+		// Run directories of matrix runs also contain the config and the
+		// logs of the run, only consider result files.
+		if info != nil && info.IsDir() && runLogDirs[info.Name()] {
+			return filepath.SkipDir
+		}
+		if filepath.Ext(path) != ".json" || filepath.Base(path) == configFilename {
+			return nil
+		}
 		if !info.IsDir() {
 			var result Result
 			err := parseJSONFile(path, &result)
@@ -45,18 +68,39 @@ func aggregate(inputDirname, outputFilename string) error {
 			testcase := result.Config.TestCase.Name
 
 			if _, ok := aggregated[implementation]; !ok {
-				aggregated[implementation] = map[string]Result{
-					testcase: result,
-				}
-				return nil
+				aggregated[implementation] = map[string]Repetitions{}
 			}
-			aggregated[implementation][testcase] = result
+			aggregated[implementation][testcase] = append(aggregated[implementation][testcase], &result)
 		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
+	for _, t := range aggregated {
+		for _, rs := range t {
+			sort.SliceStable(rs, func(i, j int) bool {
+				return rs[i].Config.Repetition < rs[j].Config.Repetition
+			})
+		}
+	}
+
+	if storeDirname != "" {
+		store := resultStore{dir: storeDirname}
+		for _, t := range aggregated {
+			for _, rs := range t {
+				for _, r := range rs {
+					added, err := store.add(r)
+					if err != nil {
+						return err
+					}
+					if !added {
+						log.Printf("result %v is already stored, skipping\n", store.path(r))
+					}
+				}
+			}
+		}
+	}
 
 	data, err := json.Marshal(aggregated)
 	if err != nil {
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUnmarshalAggregatedResults(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		input   string
+		want    map[string]map[string][]float64
+		wantErr bool
+	}{
+		{
+			name:  "repetitions",
+			input: `{"impl": {"tc": [{"metrics": {"average_ssim": 0.9}}, {"config": {"repetition": 1}, "metrics": {"average_ssim": 0.8}}]}}`,
+			want:  map[string]map[string][]float64{"impl": {"tc": {0.9, 0.8}}},
+		},
+		{
+			name:  "legacy single result",
+			input: `{"impl": {"tc": {"metrics": {"average_ssim": 0.7}}, "other": [{"metrics": {"average_ssim": 0.6}}]}}`,
+			want:  map[string]map[string][]float64{"impl": {"tc": {0.7}, "other": {0.6}}},
+		},
+		{
+			name:  "empty",
+			input: `{}`,
+			want:  map[string]map[string][]float64{},
+		},
+		{
+			name:    "invalid result",
+			input:   `{"impl": {"tc": {"metrics": 1}}}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid repetitions",
+			input:   `{"impl": {"tc": "result"}}`,
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got AggregatedResults
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v implementations, want %v", len(got), len(tt.want))
+			}
+			for implementation, testcases := range tt.want {
+				if len(got[implementation]) != len(testcases) {
+					t.Fatalf("%v: got %v testcases, want %v", implementation, len(got[implementation]), len(testcases))
+				}
+				for testcase, ssim := range testcases {
+					rs := got[implementation][testcase]
+					if len(rs) != len(ssim) {
+						t.Fatalf("%v/%v: got %v repetitions, want %v", implementation, testcase, len(rs), len(ssim))
+					}
+					for i := range rs {
+						if rs[i].Metrics.AverageSSIM != ssim[i] {
+							t.Errorf("%v/%v/%v: got SSIM %v, want %v", implementation, testcase, i, rs[i].Metrics.AverageSSIM, ssim[i])
+						}
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	alignThumbnailWidth  = 32
+	alignThumbnailHeight = 18
+	// alignSearchWindow is the number of source frames following the
+	// previous match which are searched for the best match of a received
+	// frame.
+	alignSearchWindow = 90
+	// minFreezeFrames is the number of consecutive received frames showing
+	// the same source frame from which on the repetitions count as frozen
+	// instead of duplicated frames.
+	minFreezeFrames = 3
+)
+
+type videoInfo struct {
+	width     int
+	height    int
+	frameRate string
+}
+
+// frameSize returns the size of a yuv420p frame of the video.
+func (v videoInfo) frameSize() int {
+	chroma := ((v.width + 1) / 2) * ((v.height + 1) / 2)
+	return v.width*v.height + 2*chroma
+}
+
+func probeVideo(file string) (videoInfo, error) {
+	out, err := exec.Command(
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=width,height,r_frame_rate",
+		"-of", "csv=p=0",
+		file,
+	).Output()
+	if err != nil {
+		return videoInfo{}, fmt.Errorf("failed to probe %v: %w", file, err)
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), ",")
+	if len(fields) != 3 {
+		return videoInfo{}, fmt.Errorf("unexpected ffprobe output for %v: %q", file, out)
+	}
+	var info videoInfo
+	if info.width, err = strconv.Atoi(fields[0]); err != nil {
+		return videoInfo{}, err
+	}
+	if info.height, err = strconv.Atoi(fields[1]); err != nil {
+		return videoInfo{}, err
+	}
+	info.frameRate = fields[2]
+	return info, nil
+}
+
+// readThumbnails decodes file into small grayscale thumbnails, one per frame.
+func readThumbnails(file string) ([][]byte, error) {
+	var stderr bytes.Buffer
+	ffmpeg := exec.Command(
+		"ffmpeg",
+		"-v", "error",
+		"-i", file,
+		"-vf", fmt.Sprintf("scale=%v:%v,format=gray", alignThumbnailWidth, alignThumbnailHeight),
+		"-f", "rawvideo",
+		"-",
+	)
+	ffmpeg.Stderr = &stderr
+	out, err := ffmpeg.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %v: %w: %v", file, err, stderr.String())
+	}
+	size := alignThumbnailWidth * alignThumbnailHeight
+	thumbnails := make([][]byte, 0, len(out)/size)
+	for i := 0; i+size <= len(out); i += size {
+		thumbnails = append(thumbnails, out[i:i+size])
+	}
+	return thumbnails, nil
+}
+
+// frameAlignment maps every received frame to the source frame it shows.
+type frameAlignment struct {
+	source  videoInfo
+	matches []int
+}
+
+// alignVideos matches the frames of the received video outputFile to the
+// frames of the source video inputFile by searching the most similar source
+// frame. Frames are expected to be shown in order, so the search starts at
+// the source frame matched by the previous received frame.
+func alignVideos(inputFile, outputFile string) (*frameAlignment, error) {
+	info, err := probeVideo(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	source, err := readThumbnails(inputFile)
+	if err != nil {
+		return nil, err
+	}
+	received, err := readThumbnails(outputFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(source) == 0 || len(received) == 0 {
+		return nil, fmt.Errorf("no frames to align: %v source and %v received frames", len(source), len(received))
+	}
+
+	matches := make([]int, len(received))
+	previous := 0
+	for k, r := range received {
+		best, bestDistance := previous, math.MaxInt64
+		for i := previous; i < len(source) && i <= previous+alignSearchWindow; i++ {
+			if d := thumbnailDistance(source[i], r); d < bestDistance {
+				best, bestDistance = i, d
+			}
+		}
+		matches[k] = best
+		previous = best
+	}
+	return &frameAlignment{
+		source:  info,
+		matches: matches,
+	}, nil
+}
+
+// thumbnailDistance returns the sum of absolute differences of a and b.
+func thumbnailDistance(a, b []byte) int {
+	d := 0
+	for i := range a {
+		if a[i] > b[i] {
+			d += int(a[i] - b[i])
+		} else {
+			d += int(b[i] - a[i])
+		}
+	}
+	return d
+}
+
+// count returns the number of source frames up to the last shown source frame
+// which were never shown, and the number of duplicated and frozen received
+// frames. Repeating a source frame for fewer than minFreezeFrames received
+// frames counts as duplicated, longer repetitions as frozen.
+func (a *frameAlignment) count() (dropped, duplicated, frozen int) {
+	if len(a.matches) == 0 {
+		return 0, 0, 0
+	}
+	shown := map[int]bool{}
+	run := 1
+	for k, m := range a.matches {
+		shown[m] = true
+		if k > 0 && m == a.matches[k-1] {
+			run++
+		}
+		if k == len(a.matches)-1 || a.matches[k+1] != m {
+			if run >= minFreezeFrames {
+				frozen += run - 1
+			} else {
+				duplicated += run - 1
+			}
+			run = 1
+		}
+	}
+	dropped = a.matches[len(a.matches)-1] + 1 - len(shown)
+	return dropped, duplicated, frozen
+}
+
+// writeAlignedSource decodes inputFile and writes the matching source frame
+// of every received frame to w as raw yuv420p video.
+func (a *frameAlignment) writeAlignedSource(inputFile string, w io.Writer) error {
+	ffmpeg := exec.Command(
+		"ffmpeg",
+		"-v", "error",
+		"-i", inputFile,
+		"-f", "rawvideo",
+		"-pix_fmt", "yuv420p",
+		"-",
+	)
+	stdout, err := ffmpeg.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err = ffmpeg.Start(); err != nil {
+		return err
+	}
+	// The decoder is stopped as soon as the last matched frame was written.
+	defer func() {
+		_ = ffmpeg.Process.Kill()
+		_ = ffmpeg.Wait()
+	}()
+
+	frame := make([]byte, a.source.frameSize())
+	current := -1
+	for _, m := range a.matches {
+		for current < m {
+			if _, err = io.ReadFull(stdout, frame); err != nil {
+				return fmt.Errorf("failed to read source frame %v: %w", current+1, err)
+			}
+			current++
+		}
+		if _, err = w.Write(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import "testing"
+
+func TestFrameAlignmentCount(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		matches        []int
+		wantDropped    int
+		wantDuplicated int
+		wantFrozen     int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:    "in order",
+			matches: []int{0, 1, 2, 3},
+		},
+		{
+			name:        "dropped frames",
+			matches:     []int{0, 2, 5},
+			wantDropped: 3,
+		},
+		{
+			name:           "duplicated frame",
+			matches:        []int{0, 1, 1, 2},
+			wantDuplicated: 1,
+		},
+		{
+			name:       "frozen frame",
+			matches:    []int{0, 1, 1, 1, 1, 2},
+			wantFrozen: 3,
+		},
+		{
+			name:           "duplicate shorter than a freeze",
+			matches:        []int{0, 0, 1, 1, 1, 4, 4},
+			wantDropped:    2,
+			wantDuplicated: 2,
+			wantFrozen:     2,
+		},
+		{
+			name:       "freeze at the end",
+			matches:    []int{0, 1, 2, 2, 2},
+			wantFrozen: 2,
+		},
+		{
+			name:        "late first frame",
+			matches:     []int{3, 4},
+			wantDropped: 3,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &frameAlignment{matches: tt.matches}
+			dropped, duplicated, frozen := a.count()
+			if dropped != tt.wantDropped || duplicated != tt.wantDuplicated || frozen != tt.wantFrozen {
+				t.Errorf("got dropped=%v, duplicated=%v, frozen=%v, want dropped=%v, duplicated=%v, frozen=%v",
+					dropped, duplicated, frozen, tt.wantDropped, tt.wantDuplicated, tt.wantFrozen)
+			}
+		})
+	}
+}
+
+func TestThumbnailDistance(t *testing.T) {
+	if d := thumbnailDistance([]byte{0, 10, 255}, []byte{5, 0, 255}); d != 15 {
+		t.Errorf("got %v, want 15", d)
+	}
+}
+
+func TestFrameSize(t *testing.T) {
+	for _, tt := range []struct {
+		width, height, want int
+	}{
+		{width: 1280, height: 720, want: 1382400},
+		{width: 3, height: 3, want: 17},
+	} {
+		if got := (videoInfo{width: tt.width, height: tt.height}).frameSize(); got != tt.want {
+			t.Errorf("%vx%v: got %v, want %v", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Assertion is an expectation on a metric of a testcase. Metric names a
+// metric of metricDefinitions, Min and Max bound its value. Phase selects the
+// value of a phase for metrics measured per phase, counting from 1.
+type Assertion struct {
+	Metric string   `json:"metric"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	Phase  int      `json:"phase,omitempty"`
+}
+
+func (a Assertion) String() string {
+	name := a.Metric
+	if a.Phase > 0 {
+		name = fmt.Sprintf("%v in phase %v", name, a.Phase)
+	}
+	var bounds []string
+	if a.Min != nil {
+		bounds = append(bounds, fmt.Sprintf("%v >= %v", name, *a.Min))
+	}
+	if a.Max != nil {
+		bounds = append(bounds, fmt.Sprintf("%v <= %v", name, *a.Max))
+	}
+	return strings.Join(bounds, " and ")
+}
+
+// validateAssertions checks that the assertions of t name known metrics and
+// phases.
+func validateAssertions(t TestCase) error {
+	for _, a := range t.Assertions {
+		d, ok := lookupMetric(a.Metric)
+		if !ok {
+			return fmt.Errorf("testcase %v: assertion on unknown metric: %v", t.Name, a.Metric)
+		}
+		if a.Min == nil && a.Max == nil {
+			return fmt.Errorf("testcase %v: assertion on %v sets neither min nor max", t.Name, a.Metric)
+		}
+		if a.Phase != 0 && (d.perPhase == nil || a.Phase < 0 || a.Phase > len(t.Phases)) {
+			return fmt.Errorf("testcase %v: invalid phase of assertion on %v: %v", t.Name, a.Metric, a.Phase)
+		}
+	}
+	return nil
+}
+
+// AssertionResult is the outcome of an assertion. Message explains failed
+// assertions.
+type AssertionResult struct {
+	Assertion
+	Value   float64 `json:"value"`
+	Passed  bool    `json:"passed"`
+	Message string  `json:"message,omitempty"`
+}
+
+func checkAssertion(a Assertion, m *Metrics) AssertionResult {
+	r := AssertionResult{Assertion: a}
+	d, ok := lookupMetric(a.Metric)
+	if !ok {
+		r.Message = fmt.Sprintf("unknown metric: %v", a.Metric)
+		return r
+	}
+	if d.measured != nil && !d.measured(m) {
+		r.Message = fmt.Sprintf("%v was not measured", a.Metric)
+		return r
+	}
+	r.Value = d.value(m)
+	if a.Phase > 0 {
+		phases := []float64(nil)
+		if d.perPhase != nil {
+			phases = d.perPhase(m)
+		}
+		if a.Phase > len(phases) {
+			r.Message = fmt.Sprintf("%v was not measured in phase %v", a.Metric, a.Phase)
+			return r
+		}
+		r.Value = phases[a.Phase-1]
+	}
+	switch {
+	case a.Min != nil && r.Value < *a.Min:
+		r.Message = fmt.Sprintf("%v is %v, expected at least %v", a.Metric, r.Value, *a.Min)
+	case a.Max != nil && r.Value > *a.Max:
+		r.Message = fmt.Sprintf("%v is %v, expected at most %v", a.Metric, r.Value, *a.Max)
+	default:
+		r.Passed = true
+	}
+	return r
+}
+
+// checkAssertions checks the assertions of the testcase of r and records
+// their results in r.
+func checkAssertions(r *Result) {
+	r.Assertions = nil
+	for _, a := range r.Config.TestCase.Assertions {
+		r.Assertions = append(r.Assertions, checkAssertion(a, &r.Metrics))
+	}
+}
+
+// failedAssertions returns the number of failed assertions of r.
+func (r *Result) failedAssertions() int {
+	failed := 0
+	for _, a := range r.Assertions {
+		if !a.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the assertion results of r as JUnit XML test suite to
+// filename.
+func writeJUnit(filename string, r *Result) error {
+	name := fmt.Sprintf("%v/%v", r.Config.Implementation.Name, r.Config.TestCase.Name)
+	suite := junitTestSuite{
+		Name:     name,
+		Tests:    len(r.Assertions),
+		Failures: r.failedAssertions(),
+	}
+	for _, a := range r.Assertions {
+		tc := junitTestCase{
+			Name:      a.Assertion.String(),
+			ClassName: name,
+		}
+		if !a.Passed {
+			tc.Failure = &junitFailure{Message: a.Message}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	data, err := xml.MarshalIndent(suite, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append([]byte(xml.Header), data...), 0644)
+}
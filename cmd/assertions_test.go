@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestMetricDefinitionsMeasured(t *testing.T) {
+	for _, d := range metricDefinitions {
+		if d.measured == nil {
+			t.Errorf("metric %v has no measured predicate", d.Name)
+			continue
+		}
+		if d.measured(&Metrics{}) {
+			t.Errorf("metric %v is measured without any data", d.Name)
+		}
+	}
+}
+
+func TestCheckAssertion(t *testing.T) {
+	bound := func(v float64) *float64 { return &v }
+	latency := Metrics{
+		FrameLatency:            plotter.XYs{{X: 0, Y: 40}},
+		FrameLatencyPercentiles: Percentiles{P95: 40},
+	}
+	for _, tt := range []struct {
+		name       string
+		assertion  Assertion
+		metrics    Metrics
+		wantPassed bool
+		wantValue  float64
+	}{
+		{
+			name:       "max",
+			assertion:  Assertion{Metric: "frame_latency_p95", Max: bound(50)},
+			metrics:    latency,
+			wantPassed: true,
+			wantValue:  40,
+		},
+		{
+			name:      "max exceeded",
+			assertion: Assertion{Metric: "frame_latency_p95", Max: bound(30)},
+			metrics:   latency,
+			wantValue: 40,
+		},
+		{
+			name:      "max without data",
+			assertion: Assertion{Metric: "frame_latency_p95", Max: bound(50)},
+		},
+		{
+			name:      "min",
+			assertion: Assertion{Metric: "utilization", Min: bound(0.8)},
+			metrics: Metrics{
+				Utilization:        plotter.XYs{{X: 0, Y: 0.5}},
+				AverageUtilization: 0.5,
+			},
+			wantValue: 0.5,
+		},
+		{
+			name:      "phase",
+			assertion: Assertion{Metric: "utilization", Min: bound(0.8), Phase: 2},
+			metrics: Metrics{
+				Utilization:        plotter.XYs{{X: 0, Y: 0.5}, {X: 1, Y: 0.9}},
+				AverageUtilization: 0.7,
+				PhaseUtilization:   []float64{0.5, 0.9},
+			},
+			wantPassed: true,
+			wantValue:  0.9,
+		},
+		{
+			name:      "phase without data",
+			assertion: Assertion{Metric: "utilization", Min: bound(0.8), Phase: 3},
+			metrics: Metrics{
+				Utilization:      plotter.XYs{{X: 0, Y: 0.9}},
+				PhaseUtilization: []float64{0.9},
+			},
+		},
+		{
+			name:      "unknown metric",
+			assertion: Assertion{Metric: "bitrate", Min: bound(1)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := checkAssertion(tt.assertion, &tt.metrics)
+			if got.Passed != tt.wantPassed || got.Value != tt.wantValue {
+				t.Errorf("got %+v, want passed=%v, value=%v", got, tt.wantPassed, tt.wantValue)
+			}
+			if !got.Passed && got.Message == "" {
+				t.Error("failed assertion without message")
+			}
+		})
+	}
+}
+
+func TestReportAssertions(t *testing.T) {
+	max := 50.0
+	for _, tt := range []struct {
+		name       string
+		result     Result
+		wantFailed int
+		wantJUnit  bool
+	}{
+		{
+			name: "no assertions",
+		},
+		{
+			name: "failed assertion",
+			result: Result{
+				Config: Config{TestCase: TestCase{Assertions: []Assertion{
+					{Metric: "frame_latency_p95", Max: &max},
+					{Metric: "packet_latency_p95", Max: &max},
+				}}},
+				Metrics: Metrics{
+					FrameLatency:            plotter.XYs{{X: 0, Y: 40}},
+					FrameLatencyPercentiles: Percentiles{P95: 40},
+				},
+			},
+			wantFailed: 1,
+			wantJUnit:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			checkAssertions(&tt.result)
+			if err := saveToJSONFile(filepath.Join(dir, resultFilename), &tt.result); err != nil {
+				t.Fatal(err)
+			}
+			failed, err := reportAssertions(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if failed != tt.wantFailed {
+				t.Errorf("got %v failed assertions, want %v", failed, tt.wantFailed)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(dir, junitFilename))
+			if !tt.wantJUnit {
+				if !os.IsNotExist(err) {
+					t.Errorf("expected no JUnit file, got error %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var suite junitTestSuite
+			if err := xml.Unmarshal(data, &suite); err != nil {
+				t.Fatal(err)
+			}
+			if suite.Tests != len(tt.result.Assertions) || suite.Failures != tt.wantFailed {
+				t.Errorf("got %v tests with %v failures, want %v with %v", suite.Tests, suite.Failures, len(tt.result.Assertions), tt.wantFailed)
+			}
+		})
+	}
+
+	if _, err := reportAssertions(t.TempDir()); err == nil {
+		t.Error("expected error for a missing result")
+	}
+}
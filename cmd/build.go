@@ -11,11 +11,13 @@ import (
 	"github.com/spf13/cobra"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
 )
 
 const (
 	ssimPlotFileName = "%v-%v-ssim.svg"
 	psnrPlotFileName = "%v-%v-psnr.svg"
+	vmafPlotFileName = "%v-%v-vmaf.svg"
 
 	rtpOutPlotFileName                      = "%v-%v-rtp-out.svg"
 	rtpInPlotFileName                       = "%v-%v-rtp-in.svg"
@@ -28,18 +30,41 @@ const (
 	qlogCongestionWindowPlotFileName        = "%v-%v-qlog-cc-window.svg"
 	ccTargetBitratePlotFileName             = "%v-%v-cc-target-bitrate.svg"
 	ccSRTTPlotFileName                      = "%v-%v-cc-srtt.svg"
+	latencyPlotFileName                     = "%v-%v-latency.svg"
+	lossRatePlotFileName                    = "%v-%v-loss-rate.svg"
+	jitterPlotFileName                      = "%v-%v-jitter.svg"
+	reorderExtentPlotFileName               = "%v-%v-reorder-extent.svg"
+	duplicatesPlotFileName                  = "%v-%v-duplicates.svg"
+	feedbackTimelinePlotFileName            = "%v-%v-feedback-timeline.svg"
+	feedbackIntervalPlotFileName            = "%v-%v-feedback-interval.svg"
+	feedbackOverheadPlotFileName            = "%v-%v-feedback-overhead.svg"
+	reportFractionLostPlotFileName          = "%v-%v-report-fraction-lost.svg"
+	qlogRTTPlotFileName                     = "%v-%v-qlog-rtt.svg"
+	qlogBytesInFlightPlotFileName           = "%v-%v-qlog-bytes-in-flight.svg"
+	qlogPacingRatePlotFileName              = "%v-%v-qlog-pacing-rate.svg"
+	qlogPacketsLostPlotFileName             = "%v-%v-qlog-packets-lost.svg"
+	utilizationPlotFileName                 = "%v-%v-utilization.svg"
+	queueingDelayPlotFileName               = "%v-%v-queueing-delay.svg"
+	throughputPlotFileName                  = "%v-%v-throughput.svg"
+	fairnessIndexPlotFileName               = "%v-%v-fairness-index.svg"
 )
 
 var (
-	resultsFilename string
-	templateDir     string
-	outputDir       string
+	resultsFilename  string
+	templateDir      string
+	outputDir        string
+	buildStoreDir    string
+	buildOverlay     string
+	buildInteractive bool
 )
 
 func init() {
 	buildCmd.Flags().StringVarP(&resultsFilename, "result", "r", "results.json", "filename of the results JSON file")
 	buildCmd.Flags().StringVarP(&templateDir, "templates", "t", "templates", "Template directory containing HTML template files")
 	buildCmd.Flags().StringVarP(&outputDir, "output", "o", "html", "Output directory for generated HTML files")
+	buildCmd.Flags().StringVarP(&buildStoreDir, "store", "s", "", "Result store directory to build trend pages from, empty to skip")
+	buildCmd.Flags().StringVar(&buildOverlay, "overlay", "*", "GLOB pattern of the implementations to overlay on the page of each testcase, empty to skip")
+	buildCmd.Flags().BoolVar(&buildInteractive, "interactive", false, "Add interactive plots of all series, which can be zoomed, panned and toggled, to the detail pages")
 
 	rootCmd.AddCommand(buildCmd)
 }
@@ -62,22 +87,41 @@ func buildHTML(inputFilename, outputDirname string) error {
 	}
 
 	templates = template.Must(template.ParseGlob(filepath.Join(templateDir, "*.html")))
+	if err = copyAssets(templateDir, outputDirname); err != nil {
+		return err
+	}
 
 	for i, t := range result {
-		for tc, r := range t {
+		for tc, rs := range t {
 			detailLink := filepath.Join(i, tc)
-			r.Config.DetailsLink = detailLink
-			err := buildResultDetailPage(r.Config, &r.Metrics, outputDirname, detailLink)
+			for _, r := range rs {
+				r.Config.DetailsLink = detailLink
+			}
+			err := buildResultDetailPage(rs, outputDirname, detailLink)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
-	return buildHomePage(&result, outputDirname)
+	var testcases []IndexTestCaseLink
+	if buildOverlay != "" {
+		if testcases, err = buildOverlayPages(result, buildOverlay, outputDirname); err != nil {
+			return err
+		}
+	}
+
+	var trends []IndexTrendLink
+	if buildStoreDir != "" {
+		if trends, err = buildTrendPages(buildStoreDir, outputDirname); err != nil {
+			return err
+		}
+	}
+
+	return buildHomePage(&result, testcases, trends, outputDirname)
 }
 
-func buildHomePage(input *AggregatedResults, outDir string) error {
+func buildHomePage(input *AggregatedResults, testcases []IndexTestCaseLink, trends []IndexTrendLink, outDir string) error {
 	if _, err := os.Stat(outDir); os.IsNotExist(err) {
 		err = os.Mkdir(outDir, os.ModePerm)
 		if err != nil {
@@ -92,15 +136,21 @@ func buildHomePage(input *AggregatedResults, outDir string) error {
 	defer index.Close()
 
 	var htmlInput IndexInput
+	htmlInput.Assets = assetsLink(".")
 	htmlInput.TableHeaders = input.getTableHeaders()
 	htmlInput.TableRows = input.getTableRows(htmlInput.valueHeaders())
+	htmlInput.TestCases = testcases
+	htmlInput.Trends = trends
 
 	return templates.ExecuteTemplate(index, "index.html", htmlInput)
 }
 
 type IndexInput struct {
+	Assets       string
 	TableHeaders []IndexTableHeader
 	TableRows    []IndexTableRow
+	TestCases    []IndexTestCaseLink
+	Trends       []IndexTrendLink
 }
 
 func (i IndexInput) valueHeaders() []string {
@@ -122,21 +172,94 @@ type IndexTableRow struct {
 }
 
 type IndexMetric struct {
-	Link    string
-	Metrics Metrics
+	Link        string
+	TestCase    string
+	Repetitions int
+
+	SSIM          Statistic
+	PSNR          Statistic
+	TargetBitrate Statistic
+	VMAF          Statistic
+
+	Utilization       Statistic
+	TimeAboveCapacity Statistic
+	ReactionTime      Statistic
+	ReconvergenceTime Statistic
+	Oscillation       Statistic
+	QueueingDelay     Statistic
+
+	// Assertions is the number of assertions checked over all repetitions.
+	Assertions       int
+	AssertionsPassed int
 }
 
-type detailsInput struct {
-	ConfigJSON string
+// assertionDetails summarizes the results of an assertion over all
+// repetitions.
+type assertionDetails struct {
+	Name     string
+	Passed   int
+	Total    int
+	Messages []string
+}
+
+// flowDetails summarizes a flow sharing the bottleneck over all repetitions.
+// Starved is the number of repetitions in which the flow starved first.
+type flowDetails struct {
+	ID      string
+	Share   Statistic
+	Starved int
+}
 
-	AverageSSIM          float64
-	AveragePSNR          float64
-	AverageTargetBitrate float64
+type detailsInput struct {
+	Assets      string
+	ConfigJSON  string
+	Queue       string
+	Repetitions int
+	Notes       []string
+	Assertions  []assertionDetails
+
+	SSIM          Statistic
+	PSNR          Statistic
+	TargetBitrate Statistic
+	VMAF          Statistic
+
+	DroppedFrames    Statistic
+	DuplicatedFrames Statistic
+	FrozenFrames     Statistic
+
+	FrameLatencyP50 Statistic
+	FrameLatencyP95 Statistic
+	FrameLatencyP99 Statistic
+
+	PacketLoss       Statistic
+	DuplicatePackets Statistic
+	ReorderedPackets Statistic
+	MaxReorderExtent Statistic
+	AverageJitter    Statistic
+
+	AverageFeedbackInterval Statistic
+	AverageFeedbackOverhead Statistic
+	NACKs                   Statistic
+	NACKedPackets           Statistic
+
+	QLOGLostPackets Statistic
+
+	Utilization       Statistic
+	TimeAboveCapacity Statistic
+	ReactionTime      Statistic
+	ReconvergenceTime Statistic
+	Oscillation       Statistic
+	QueueingDelay     Statistic
+
+	FairnessIndex Statistic
+	Flows         []flowDetails
 
 	SSIMPlotSVG string
 
 	PSNRPlotSVG string
 
+	VMAFPlotSVG string
+
 	RTPOutPlotSVG  string
 	RTPInPlotSVG   string
 	RTCPOutPlotSVG string
@@ -149,8 +272,32 @@ type detailsInput struct {
 
 	QLOGCongestionWindow string
 
+	QLOGRTT           string
+	QLOGBytesInFlight string
+	QLOGPacingRate    string
+	QLOGPacketsLost   string
+
 	CCTargetBitrate string
 	CCSRTT          string
+
+	UtilizationPlotSVG   string
+	QueueingDelayPlotSVG string
+
+	ThroughputPlotSVG    string
+	FairnessIndexPlotSVG string
+
+	Latency       string
+	LossRate      string
+	Jitter        string
+	ReorderExtent string
+	Duplicates    string
+
+	FeedbackTimeline   string
+	FeedbackInterval   string
+	FeedbackOverhead   string
+	ReportFractionLost string
+
+	InteractivePlots []InteractivePlot
 }
 
 func writeToHTML(p *plot.Plot, w, h font.Length) (template.HTML, error) {
@@ -174,22 +321,113 @@ func writePlot(p *plot.Plot, format string, w, h font.Length) (*bytes.Buffer, er
 	return buf, nil
 }
 
-func buildResultDetailPage(config Config, input *Metrics, outDir, link string) error {
+// buildResultDetailPage builds the detail page of the repetitions rs of a
+// run. The plots overlay all repetitions.
+func buildResultDetailPage(rs Repetitions, outDir, link string) error {
+	config := rs[0].Config
 	configJSON, err := json.Marshal(config)
 	if err != nil {
 		return err
 	}
 
 	details := detailsInput{
-		ConfigJSON: string(configJSON),
+		Assets:      assetsLink(link),
+		ConfigJSON:  string(configJSON),
+		Queue:       config.Queue.String(),
+		Repetitions: len(rs),
+		Notes:       rs.notes(),
+
+		SSIM: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageSSIM
+		}),
+		PSNR: rs.statistic(func(m *Metrics) float64 {
+			return m.AveragePSNR
+		}),
+		TargetBitrate: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageTargetBitrate
+		}),
+		VMAF: rs.filter(hasVMAF).statistic(func(m *Metrics) float64 {
+			return m.AverageVMAF
+		}),
+		DroppedFrames: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.DroppedFrames)
+		}),
+		DuplicatedFrames: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.DuplicatedFrames)
+		}),
+		FrozenFrames: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.FrozenFrames)
+		}),
+		FrameLatencyP50: rs.statistic(func(m *Metrics) float64 {
+			return m.FrameLatencyPercentiles.P50
+		}),
+		FrameLatencyP95: rs.statistic(func(m *Metrics) float64 {
+			return m.FrameLatencyPercentiles.P95
+		}),
+		FrameLatencyP99: rs.statistic(func(m *Metrics) float64 {
+			return m.FrameLatencyPercentiles.P99
+		}),
+		PacketLoss: rs.statistic(func(m *Metrics) float64 {
+			return m.PacketLoss
+		}),
+		DuplicatePackets: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.DuplicatePackets)
+		}),
+		ReorderedPackets: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.ReorderedPackets)
+		}),
+		MaxReorderExtent: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.MaxReorderExtent)
+		}),
+		AverageJitter: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageJitter
+		}),
+		AverageFeedbackInterval: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageFeedbackInterval
+		}),
+		AverageFeedbackOverhead: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageFeedbackOverhead
+		}),
+		NACKs: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.NACKs)
+		}),
+		NACKedPackets: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.NACKedPackets)
+		}),
+		QLOGLostPackets: rs.statistic(func(m *Metrics) float64 {
+			return float64(m.QLOGLostPackets)
+		}),
+		Utilization: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageUtilization
+		}),
+		TimeAboveCapacity: rs.statistic(func(m *Metrics) float64 {
+			return m.TimeAboveCapacity
+		}),
+		ReactionTime: rs.statistic(func(m *Metrics) float64 {
+			return m.ReactionTime
+		}),
+		ReconvergenceTime: rs.statistic(func(m *Metrics) float64 {
+			return m.ReconvergenceTime
+		}),
+		Oscillation: rs.statistic(func(m *Metrics) float64 {
+			return m.Oscillation
+		}),
+		QueueingDelay: rs.statistic(func(m *Metrics) float64 {
+			return m.AverageQueueingDelay
+		}),
+		FairnessIndex: rs.filter(hasFlows).statistic(func(m *Metrics) float64 {
+			return m.AverageFairnessIndex
+		}),
+		Flows:         getFlowDetails(rs.filter(hasFlows)),
+		Assertions:    rs.assertions(),
+		SSIMPlotSVG:   fmt.Sprintf(ssimPlotFileName, config.Implementation.Name, config.TestCase.Name),
+		PSNRPlotSVG:   fmt.Sprintf(psnrPlotFileName, config.Implementation.Name, config.TestCase.Name),
+		RTPOutPlotSVG: fmt.Sprintf(rtpOutPlotFileName, config.Implementation.Name, config.TestCase.Name),
+		RTPInPlotSVG:  fmt.Sprintf(rtpInPlotFileName, config.Implementation.Name, config.TestCase.Name),
+	}
 
-		AverageSSIM:          input.AverageSSIM,
-		AveragePSNR:          input.AveragePSNR,
-		AverageTargetBitrate: input.AverageTargetBitrate,
-		SSIMPlotSVG:          fmt.Sprintf(ssimPlotFileName, config.Implementation.Name, config.TestCase.Name),
-		PSNRPlotSVG:          fmt.Sprintf(psnrPlotFileName, config.Implementation.Name, config.TestCase.Name),
-		RTPOutPlotSVG:        fmt.Sprintf(rtpOutPlotFileName, config.Implementation.Name, config.TestCase.Name),
-		RTPInPlotSVG:         fmt.Sprintf(rtpInPlotFileName, config.Implementation.Name, config.TestCase.Name),
+	if buildInteractive {
+		details.InteractivePlots = interactivePlots(rs)
 	}
 
 	err = os.MkdirAll(filepath.Join(outDir, link), os.ModePerm)
@@ -203,32 +441,41 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 	}
 	defer index.Close()
 
-	ssimPlot, err := input.plotPerFrameVideoMetric("SSIM", input.PerFrameSSIM)
+	ssimPlot, err := plotPerFrameVideoMetric("SSIM", rs.series(func(m *Metrics) plotter.XYs { return m.PerFrameSSIM }))
 	if err != nil {
 		return err
 	}
 	ssimPlot.Save(width, height, filepath.Join(outDir, link, details.SSIMPlotSVG))
 
-	psnrPlot, err := input.plotPerFrameVideoMetric("PSNR", input.PerFramePSNR)
+	psnrPlot, err := plotPerFrameVideoMetric("PSNR", rs.series(func(m *Metrics) plotter.XYs { return m.PerFramePSNR }))
 	if err != nil {
 		return err
 	}
 	psnrPlot.Save(width, height, filepath.Join(outDir, link, details.PSNRPlotSVG))
 
-	rtpOutPlot, err := plotMetric("Sent RTP bytes", plot.DefaultTicks{}, input.SentRTP)
+	if vmaf := rs.series(func(m *Metrics) plotter.XYs { return m.PerFrameVMAF }); longest(vmaf) > 0 {
+		vmafPlot, err := plotPerFrameVideoMetric("VMAF", vmaf)
+		if err != nil {
+			return err
+		}
+		details.VMAFPlotSVG = fmt.Sprintf(vmafPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		vmafPlot.Save(width, height, filepath.Join(outDir, link, details.VMAFPlotSVG))
+	}
+
+	rtpOutPlot, err := plotMetric("Sent RTP bytes", plot.DefaultTicks{}, rs.series(func(m *Metrics) plotter.XYs { return m.SentRTP }))
 	if err != nil {
 		return err
 	}
 	rtpOutPlot.Save(width, height, filepath.Join(outDir, link, details.RTPOutPlotSVG))
 
-	rtpInPlot, err := plotMetric("Received RTP bytes", plot.DefaultTicks{}, input.ReceivedRTP)
+	rtpInPlot, err := plotMetric("Received RTP bytes", plot.DefaultTicks{}, rs.series(func(m *Metrics) plotter.XYs { return m.ReceivedRTP }))
 	if err != nil {
 		return err
 	}
 	rtpInPlot.Save(width, height, filepath.Join(outDir, link, details.RTPInPlotSVG))
 
-	if len(input.SentRTCP) > 0 {
-		rtcpOutPlot, err := plotMetric("Sent RTCP bytes", plot.DefaultTicks{}, input.SentRTCP)
+	if sentRTCP := rs.series(func(m *Metrics) plotter.XYs { return m.SentRTCP }); longest(sentRTCP) > 0 {
+		rtcpOutPlot, err := plotMetric("Sent RTCP bytes", plot.DefaultTicks{}, sentRTCP)
 		if err != nil {
 			return err
 		}
@@ -236,8 +483,8 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 		rtcpOutPlot.Save(width, height, filepath.Join(outDir, link, details.RTCPOutPlotSVG))
 	}
 
-	if len(input.ReceivedRTCP) > 0 {
-		rtcpInPlot, err := plotMetric("Received RTCP bytes", plot.DefaultTicks{}, input.ReceivedRTCP)
+	if receivedRTCP := rs.series(func(m *Metrics) plotter.XYs { return m.ReceivedRTCP }); longest(receivedRTCP) > 0 {
+		rtcpInPlot, err := plotMetric("Received RTCP bytes", plot.DefaultTicks{}, receivedRTCP)
 		if err != nil {
 			return err
 		}
@@ -245,32 +492,32 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 		rtcpInPlot.Save(width, height, filepath.Join(outDir, link, details.RTCPInPlotSVG))
 	}
 
-	if len(input.QLOGSenderPacketsSent) > 0 {
-		qspsPlot, err := plotMetric("QLOG bytes sent", plot.DefaultTicks{}, input.QLOGSenderPacketsSent)
+	if sent := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGSenderPacketsSent }); longest(sent) > 0 {
+		qspsPlot, err := plotMetric("QLOG bytes sent", plot.DefaultTicks{}, sent)
 		if err != nil {
 			return err
 		}
 		details.QLOGSenderPacketsSent = fmt.Sprintf(qlogSenderPacketSentsPlotFileName, config.Implementation.Name, config.TestCase.Name)
 		qspsPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGSenderPacketsSent))
 	}
-	if len(input.QLOGSenderPacketsReceived) > 0 {
-		qsprPlot, err := plotMetric("QLOG bytes received", plot.DefaultTicks{}, input.QLOGSenderPacketsReceived)
+	if received := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGSenderPacketsReceived }); longest(received) > 0 {
+		qsprPlot, err := plotMetric("QLOG bytes received", plot.DefaultTicks{}, received)
 		if err != nil {
 			return err
 		}
 		details.QLOGSenderPacketsReceived = fmt.Sprintf(qlogSenderPacketsReceivedPlotFileName, config.Implementation.Name, config.TestCase.Name)
 		qsprPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGSenderPacketsReceived))
 	}
-	if len(input.QLOGReceiverPacketsSent) > 0 {
-		qrpsPlot, err := plotMetric("QLOG bytes sent", plot.DefaultTicks{}, input.QLOGReceiverPacketsSent)
+	if sent := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGReceiverPacketsSent }); longest(sent) > 0 {
+		qrpsPlot, err := plotMetric("QLOG bytes sent", plot.DefaultTicks{}, sent)
 		if err != nil {
 			return err
 		}
 		details.QLOGReceiverPacketsSent = fmt.Sprintf(qlogReceiverPacketsSentPlotFileName, config.Implementation.Name, config.TestCase.Name)
 		qrpsPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGReceiverPacketsSent))
 	}
-	if len(input.QLOGReceiverPacketsReceived) > 0 {
-		qrprPlot, err := plotMetric("QLOG bytes received", plot.DefaultTicks{}, input.QLOGReceiverPacketsReceived)
+	if received := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGReceiverPacketsReceived }); longest(received) > 0 {
+		qrprPlot, err := plotMetric("QLOG bytes received", plot.DefaultTicks{}, received)
 		if err != nil {
 			return err
 		}
@@ -278,8 +525,8 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 		qrprPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGReceiverPacketsReceived))
 	}
 
-	if len(input.QLOGCongestionWindow) > 1 {
-		qccPlot, err := plotMetric("QLOG Congestion Window", secondsTicker{}, input.QLOGCongestionWindow)
+	if cwnd := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGCongestionWindow }); longest(cwnd) > 1 {
+		qccPlot, err := plotMetric("QLOG Congestion Window", secondsTicker{}, cwnd)
 		if err != nil {
 			return err
 		}
@@ -287,19 +534,68 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 		qccPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGCongestionWindow))
 	}
 
-	if len(input.CCTargetBitrate) > 0 {
-		maxX := input.CCTargetBitrate[len(input.CCTargetBitrate)-1].X
-		linkCapacity := getCapacityFromConfig(config, maxX)
-		input.LinkCapacity = rect(linkCapacity)
-		ccPlot, err := input.plotCCBitrate()
+	states := rs[0].Metrics.QLOGCongestionStates
+	if srtt := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGSmoothedRTT }); longest(srtt) > 0 {
+		rttPlot, err := plotWithCongestionStates(
+			"QLOG RTT", "ms", states,
+			[]string{"Smoothed", "Latest", "Min"},
+			srtt,
+			rs.series(func(m *Metrics) plotter.XYs { return m.QLOGLatestRTT }),
+			rs.series(func(m *Metrics) plotter.XYs { return m.QLOGMinRTT }),
+		)
+		if err != nil {
+			return err
+		}
+		details.QLOGRTT = fmt.Sprintf(qlogRTTPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		rttPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGRTT))
+	}
+	if inFlight := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGBytesInFlight }); longest(inFlight) > 0 {
+		inFlightPlot, err := plotWithCongestionStates(
+			"QLOG Bytes in Flight", "Bytes", states,
+			[]string{"Bytes in flight", "Congestion window"},
+			inFlight,
+			rs.series(func(m *Metrics) plotter.XYs { return m.QLOGCongestionWindow }),
+		)
+		if err != nil {
+			return err
+		}
+		details.QLOGBytesInFlight = fmt.Sprintf(qlogBytesInFlightPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		inFlightPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGBytesInFlight))
+	}
+	if pacingRate := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGPacingRate }); longest(pacingRate) > 0 {
+		pacingRatePlot, err := plotWithCongestionStates("QLOG Pacing Rate", "bit/s", states, nil, pacingRate)
+		if err != nil {
+			return err
+		}
+		details.QLOGPacingRate = fmt.Sprintf(qlogPacingRatePlotFileName, config.Implementation.Name, config.TestCase.Name)
+		pacingRatePlot.Save(width, height, filepath.Join(outDir, link, details.QLOGPacingRate))
+	}
+	if lost := rs.series(func(m *Metrics) plotter.XYs { return m.QLOGPacketsLost }); longest(lost) > 0 {
+		lostPlot, err := plotTimeSeries("QLOG Packets Lost", "packets/s", plot.DefaultTicks{}, lost)
+		if err != nil {
+			return err
+		}
+		details.QLOGPacketsLost = fmt.Sprintf(qlogPacketsLostPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		lostPlot.Save(width, height, filepath.Join(outDir, link, details.QLOGPacketsLost))
+	}
+
+	if targetBitrate := rs.series(func(m *Metrics) plotter.XYs { return m.CCTargetBitrate }); longest(targetBitrate) > 0 {
+		maxX := 0.0
+		for _, xys := range targetBitrate {
+			if len(xys) > 0 && xys[len(xys)-1].X > maxX {
+				maxX = xys[len(xys)-1].X
+			}
+		}
+		linkCapacity := rect(getCapacityFromConfig(config, maxX))
+		ccPlot, err := plotCCBitrate(targetBitrate, rs.series(func(m *Metrics) plotter.XYs { return m.CCRateTransmitted }), linkCapacity)
 		if err != nil {
 			return err
 		}
 		details.CCTargetBitrate = fmt.Sprintf(ccTargetBitratePlotFileName, config.Implementation.Name, config.TestCase.Name)
 		ccPlot.Save(width, height, filepath.Join(outDir, link, details.CCTargetBitrate))
 	}
-	if len(input.CCSRTT) > 0 {
-		ccrttPlot, err := input.plotSRTT()
+	if srtt := rs.series(func(m *Metrics) plotter.XYs { return m.CCSRTT }); longest(srtt) > 0 {
+		ccrttPlot, err := plotSRTT(srtt)
 		if err != nil {
 			return err
 		}
@@ -307,5 +603,158 @@ func buildResultDetailPage(config Config, input *Metrics, outDir, link string) e
 		ccrttPlot.Save(width, height, filepath.Join(outDir, link, details.CCSRTT))
 	}
 
+	if utilization := rs.series(func(m *Metrics) plotter.XYs { return m.Utilization }); longest(utilization) > 0 {
+		utilizationPlot, err := plotTimeSeries("Link Utilization", "received/capacity", plot.DefaultTicks{}, utilization)
+		if err != nil {
+			return err
+		}
+		details.UtilizationPlotSVG = fmt.Sprintf(utilizationPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		utilizationPlot.Save(width, height, filepath.Join(outDir, link, details.UtilizationPlotSVG))
+	}
+	if queueingDelay := rs.series(func(m *Metrics) plotter.XYs { return m.QueueingDelay }); longest(queueingDelay) > 0 {
+		queueingDelayPlot, err := plotTimeSeries("Queueing Delay", "ms", secondsTicker{}, queueingDelay)
+		if err != nil {
+			return err
+		}
+		details.QueueingDelayPlotSVG = fmt.Sprintf(queueingDelayPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		queueingDelayPlot.Save(width, height, filepath.Join(outDir, link, details.QueueingDelayPlotSVG))
+	}
+
+	if len(rs[0].Metrics.FlowThroughput) > 0 {
+		throughputPlot, err := plotStackedThroughput(rs[0].Metrics.FlowThroughput, rs[0].Metrics.LinkCapacity)
+		if err != nil {
+			return err
+		}
+		details.ThroughputPlotSVG = fmt.Sprintf(throughputPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		throughputPlot.Save(width, height, filepath.Join(outDir, link, details.ThroughputPlotSVG))
+	}
+	if index := rs.series(func(m *Metrics) plotter.XYs { return m.FairnessIndex }); longest(index) > 0 {
+		indexPlot, err := plotTimeSeries("Jain's Fairness Index", "index", plot.DefaultTicks{}, index)
+		if err != nil {
+			return err
+		}
+		details.FairnessIndexPlotSVG = fmt.Sprintf(fairnessIndexPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		indexPlot.Save(width, height, filepath.Join(outDir, link, details.FairnessIndexPlotSVG))
+	}
+
+	if packets := rs.series(func(m *Metrics) plotter.XYs { return m.PacketLatency }); longest(packets) > 0 {
+		latencyPlot, err := plotLatency(packets, rs.series(func(m *Metrics) plotter.XYs { return m.FrameLatency }))
+		if err != nil {
+			return err
+		}
+		details.Latency = fmt.Sprintf(latencyPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		latencyPlot.Save(width, height, filepath.Join(outDir, link, details.Latency))
+	}
+
+	if lossRate := rs.series(func(m *Metrics) plotter.XYs { return m.LossRate }); longest(lossRate) > 0 {
+		lossRatePlot, err := plotTimeSeries("RTP Loss Rate", "lost/sent", plot.DefaultTicks{}, lossRate)
+		if err != nil {
+			return err
+		}
+		details.LossRate = fmt.Sprintf(lossRatePlotFileName, config.Implementation.Name, config.TestCase.Name)
+		lossRatePlot.Save(width, height, filepath.Join(outDir, link, details.LossRate))
+	}
+	if jitter := rs.series(func(m *Metrics) plotter.XYs { return m.Jitter }); longest(jitter) > 0 {
+		jitterPlot, err := plotTimeSeries("RTP Interarrival Jitter", "ms", secondsTicker{}, jitter)
+		if err != nil {
+			return err
+		}
+		details.Jitter = fmt.Sprintf(jitterPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		jitterPlot.Save(width, height, filepath.Join(outDir, link, details.Jitter))
+	}
+	if extent := rs.series(func(m *Metrics) plotter.XYs { return m.ReorderExtent }); longest(extent) > 0 {
+		extentPlot, err := plotTimeSeries("RTP Reordering Extent", "packets", secondsTicker{}, extent)
+		if err != nil {
+			return err
+		}
+		details.ReorderExtent = fmt.Sprintf(reorderExtentPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		extentPlot.Save(width, height, filepath.Join(outDir, link, details.ReorderExtent))
+	}
+	if duplicates := rs.series(func(m *Metrics) plotter.XYs { return m.Duplicates }); longest(duplicates) > 0 {
+		duplicatesPlot, err := plotTimeSeries("RTP Duplicates", "packets/s", plot.DefaultTicks{}, duplicates)
+		if err != nil {
+			return err
+		}
+		details.Duplicates = fmt.Sprintf(duplicatesPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		duplicatesPlot.Save(width, height, filepath.Join(outDir, link, details.Duplicates))
+	}
+
+	var timelines []map[string]plotter.XYs
+	for _, r := range rs {
+		if len(r.Metrics.FeedbackTimeline) > 0 {
+			timelines = append(timelines, r.Metrics.FeedbackTimeline)
+		}
+	}
+	if len(timelines) > 0 {
+		timelinePlot, err := plotFeedbackTimeline(timelines)
+		if err != nil {
+			return err
+		}
+		details.FeedbackTimeline = fmt.Sprintf(feedbackTimelinePlotFileName, config.Implementation.Name, config.TestCase.Name)
+		timelinePlot.Save(width, height, filepath.Join(outDir, link, details.FeedbackTimeline))
+	}
+	if interval := rs.series(func(m *Metrics) plotter.XYs { return m.FeedbackInterval }); longest(interval) > 0 {
+		intervalPlot, err := plotTimeSeries("RTCP Feedback Interval", "ms", secondsTicker{}, interval)
+		if err != nil {
+			return err
+		}
+		details.FeedbackInterval = fmt.Sprintf(feedbackIntervalPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		intervalPlot.Save(width, height, filepath.Join(outDir, link, details.FeedbackInterval))
+	}
+	if overhead := rs.series(func(m *Metrics) plotter.XYs { return m.FeedbackOverhead }); longest(overhead) > 0 {
+		overheadPlot, err := plotTimeSeries("RTCP Feedback Overhead", "feedback/media bytes", plot.DefaultTicks{}, overhead)
+		if err != nil {
+			return err
+		}
+		details.FeedbackOverhead = fmt.Sprintf(feedbackOverheadPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		overheadPlot.Save(width, height, filepath.Join(outDir, link, details.FeedbackOverhead))
+	}
+	if fractionLost := rs.series(func(m *Metrics) plotter.XYs { return m.ReportFractionLost }); longest(fractionLost) > 0 {
+		fractionLostPlot, err := plotTimeSeries("RTCP Report Fraction Lost", "fraction lost", secondsTicker{}, fractionLost)
+		if err != nil {
+			return err
+		}
+		details.ReportFractionLost = fmt.Sprintf(reportFractionLostPlotFileName, config.Implementation.Name, config.TestCase.Name)
+		fractionLostPlot.Save(width, height, filepath.Join(outDir, link, details.ReportFractionLost))
+	}
+
 	return templates.ExecuteTemplate(index, "detail.html", details)
 }
+
+// longest returns the length of the longest of series.
+func longest(series []plotter.XYs) int {
+	l := 0
+	for _, xys := range series {
+		if len(xys) > l {
+			l = len(xys)
+		}
+	}
+	return l
+}
+
+// getFlowDetails summarizes the throughput share of every flow over the
+// repetitions and counts how often each flow starved first.
+func getFlowDetails(rs Repetitions) []flowDetails {
+	ids := map[string]plotter.XYs{}
+	for _, r := range rs {
+		for id := range r.Metrics.ThroughputShare {
+			ids[id] = nil
+		}
+	}
+	var result []flowDetails
+	for _, id := range flowIDs(ids) {
+		d := flowDetails{
+			ID: id,
+			Share: rs.statistic(func(m *Metrics) float64 {
+				return m.ThroughputShare[id]
+			}),
+		}
+		for _, r := range rs {
+			if r.Metrics.StarvingFlow == id {
+				d.Starved++
+			}
+		}
+		result = append(result, d)
+	}
+	return result
+}
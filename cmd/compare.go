@@ -0,0 +1,325 @@
+package cmd
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareBaselineFilename  string
+	compareCandidateFilename string
+	compareReportFilename    string
+	compareHTMLFilename      string
+	compareTemplateDir       string
+	compareDefaultThreshold  string
+	compareThresholds        map[string]string
+)
+
+func init() {
+	compareCmd.Flags().StringVarP(&compareBaselineFilename, "baseline", "b", "", "Aggregated results JSON file to compare against")
+	compareCmd.Flags().StringVarP(&compareCandidateFilename, "candidate", "c", "results.json", "Aggregated results JSON file to compare")
+	compareCmd.Flags().StringVarP(&compareReportFilename, "output", "o", "compare.md", "Output filename of the markdown report, - for stdout")
+	compareCmd.Flags().StringVar(&compareHTMLFilename, "html", "compare.html", "Output filename of the HTML report, empty to skip")
+	compareCmd.Flags().StringVarP(&compareTemplateDir, "templates", "t", "templates", "Template directory containing HTML template files")
+	compareCmd.Flags().StringVar(&compareDefaultThreshold, "default-threshold", "5%", "Threshold of metrics without explicit threshold, relative to the baseline if suffixed with %, absolute in the unit of the metric otherwise")
+	compareCmd.Flags().StringToStringVar(&compareThresholds, "threshold", nil, "Thresholds per metric as name=threshold, e.g. ssim=1%,frame_latency_p95=20")
+	compareCmd.MarkFlagRequired("baseline")
+
+	rootCmd.AddCommand(compareCmd)
+}
+
+var compareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "Compare aggregated results and report regressions",
+	// Regressions are reported as error, which is no usage error.
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		thresholds, err := parseThresholds(compareDefaultThreshold, compareThresholds)
+		if err != nil {
+			return err
+		}
+		var baseline, candidate AggregatedResults
+		if err = parseJSONFile(compareBaselineFilename, &baseline); err != nil {
+			return err
+		}
+		if err = parseJSONFile(compareCandidateFilename, &candidate); err != nil {
+			return err
+		}
+		c := compareResults(baseline, candidate, thresholds)
+		c.Baseline = compareBaselineFilename
+		c.Candidate = compareCandidateFilename
+
+		if compareReportFilename == "-" {
+			err = c.writeReport(os.Stdout)
+		} else {
+			err = writeFile(compareReportFilename, c.writeReport)
+		}
+		if err != nil {
+			return err
+		}
+		if compareHTMLFilename != "" {
+			t, err := template.ParseFiles(filepath.Join(compareTemplateDir, "compare.html"))
+			if err != nil {
+				return err
+			}
+			// The report is a single file, so the stylesheet is inlined.
+			style, err := ioutil.ReadFile(filepath.Join(compareTemplateDir, assetsDir, "report.css"))
+			if err != nil {
+				return err
+			}
+			c.Style = template.CSS(style)
+			err = writeFile(compareHTMLFilename, func(w io.Writer) error {
+				return t.Execute(w, c)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if c.Regressions > 0 {
+			return fmt.Errorf("found %v regressions", c.Regressions)
+		}
+		return nil
+	},
+}
+
+func writeFile(filename string, write func(io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// threshold is the change of a metric in the worse direction which is
+// tolerated. Relative thresholds are given in percent of the baseline.
+type threshold struct {
+	Value    float64
+	Relative bool
+}
+
+func parseThreshold(s string) (threshold, error) {
+	t := threshold{Relative: strings.HasSuffix(s, "%")}
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return threshold{}, fmt.Errorf("invalid threshold %q: %w", s, err)
+	}
+	t.Value = v
+	return t, nil
+}
+
+func (t threshold) String() string {
+	if t.Relative {
+		return fmt.Sprintf("%v%%", t.Value)
+	}
+	return fmt.Sprintf("%v", t.Value)
+}
+
+// thresholds maps metric names to their threshold, the empty name holds the
+// default threshold.
+type thresholds map[string]threshold
+
+func parseThresholds(defaultThreshold string, perMetric map[string]string) (thresholds, error) {
+	result := thresholds{}
+	t, err := parseThreshold(defaultThreshold)
+	if err != nil {
+		return nil, err
+	}
+	result[""] = t
+	for name, s := range perMetric {
+		if _, ok := lookupMetric(name); !ok {
+			return nil, fmt.Errorf("unknown metric: %v", name)
+		}
+		if result[name], err = parseThreshold(s); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (t thresholds) of(name string) threshold {
+	if v, ok := t[name]; ok {
+		return v
+	}
+	return t[""]
+}
+
+// metricComparison compares a metric of the baseline and the candidate. Delta
+// is the difference of the means, RelativeDelta the difference in percent of
+// the baseline mean. The relative change is undefined for a baseline mean of
+// 0, so metrics with relative thresholds are not comparable then.
+type metricComparison struct {
+	Metric        metricDefinition
+	Baseline      Statistic
+	Candidate     Statistic
+	Delta         float64
+	RelativeDelta float64
+	Threshold     threshold
+	Regression    bool
+	Improvement   bool
+	NotComparable bool
+}
+
+// Status summarizes the comparison for reports.
+func (m metricComparison) Status() string {
+	switch {
+	case m.Regression:
+		return "REGRESSION"
+	case m.Improvement:
+		return "improved"
+	case m.NotComparable:
+		return "not comparable"
+	default:
+		return ""
+	}
+}
+
+// FormatDelta formats the absolute and relative change.
+func (m metricComparison) FormatDelta() string {
+	if m.Baseline.Mean == 0 && m.Delta != 0 {
+		return fmt.Sprintf("%+.3f (n/a)", m.Delta)
+	}
+	return fmt.Sprintf("%+.3f (%+.1f%%)", m.Delta, m.RelativeDelta)
+}
+
+func compareMetric(d metricDefinition, baseline, candidate Repetitions, t threshold) (metricComparison, bool) {
+	c := metricComparison{
+		Metric:    d,
+		Baseline:  d.statistic(baseline),
+		Candidate: d.statistic(candidate),
+		Threshold: t,
+	}
+	if c.Baseline.N == 0 || c.Candidate.N == 0 {
+		return c, false
+	}
+	c.Delta = c.Candidate.Mean - c.Baseline.Mean
+	if c.Baseline.Mean != 0 {
+		c.RelativeDelta = 100 * c.Delta / math.Abs(c.Baseline.Mean)
+	}
+
+	change := math.Abs(c.Delta)
+	if t.Relative {
+		if c.Baseline.Mean == 0 && c.Delta != 0 {
+			c.NotComparable = true
+			return c, true
+		}
+		change = math.Abs(c.RelativeDelta)
+	}
+	worse := (c.Delta < 0) == d.HigherIsBetter
+	if c.Delta != 0 && change > t.Value {
+		c.Regression = worse
+		c.Improvement = !worse
+	}
+	return c, true
+}
+
+// resultComparison compares the results of an implementation and testcase.
+// Missing names the side which has no results, if any. Missing results of the
+// candidate count as a regression, as the runs most likely failed.
+type resultComparison struct {
+	Implementation string
+	TestCase       string
+	Missing        string
+	Metrics        []metricComparison
+	Regressions    int
+}
+
+type comparison struct {
+	Style       template.CSS
+	Baseline    string
+	Candidate   string
+	Results     []resultComparison
+	Regressions int
+}
+
+// compareResults matches the results of baseline and candidate by
+// implementation and testcase and compares all metrics measured in both.
+func compareResults(baseline, candidate AggregatedResults, t thresholds) comparison {
+	type key struct{ implementation, testcase string }
+	keys := map[key]bool{}
+	for _, results := range []AggregatedResults{baseline, candidate} {
+		for implementation, testcases := range results {
+			for testcase := range testcases {
+				keys[key{implementation, testcase}] = true
+			}
+		}
+	}
+
+	var c comparison
+	for k := range keys {
+		r := resultComparison{
+			Implementation: k.implementation,
+			TestCase:       k.testcase,
+		}
+		baselineRuns := baseline[k.implementation][k.testcase]
+		candidateRuns := candidate[k.implementation][k.testcase]
+		switch {
+		case len(baselineRuns) == 0:
+			r.Missing = "baseline"
+		case len(candidateRuns) == 0:
+			r.Missing = "candidate"
+			r.Regressions++
+		default:
+			for _, d := range metricDefinitions {
+				m, ok := compareMetric(d, baselineRuns, candidateRuns, t.of(d.Name))
+				if !ok {
+					continue
+				}
+				if m.Regression {
+					r.Regressions++
+				}
+				r.Metrics = append(r.Metrics, m)
+			}
+		}
+		c.Regressions += r.Regressions
+		c.Results = append(c.Results, r)
+	}
+	sort.Slice(c.Results, func(i, j int) bool {
+		if c.Results[i].Implementation != c.Results[j].Implementation {
+			return c.Results[i].Implementation < c.Results[j].Implementation
+		}
+		return c.Results[i].TestCase < c.Results[j].TestCase
+	})
+	return c
+}
+
+// writeReport writes the comparison as markdown to w.
+func (c comparison) writeReport(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Comparison of %v and %v\n\n", c.Candidate, c.Baseline)
+	fmt.Fprintf(&b, "Found %v regressions.\n", c.Regressions)
+	for _, r := range c.Results {
+		fmt.Fprintf(&b, "\n## %v / %v\n\n", r.Implementation, r.TestCase)
+		if r.Missing != "" {
+			fmt.Fprintf(&b, "No results in %v.\n", r.Missing)
+			if r.Regressions > 0 {
+				fmt.Fprintln(&b, "\nCounted as REGRESSION.")
+			}
+			continue
+		}
+		fmt.Fprintln(&b, "| Metric | Baseline | Candidate | Delta | Threshold | Status |")
+		fmt.Fprintln(&b, "|---|---|---|---|---|---|")
+		for _, m := range r.Metrics {
+			name := m.Metric.Name
+			if m.Metric.Unit != "" {
+				name = fmt.Sprintf("%v (%v)", name, m.Metric.Unit)
+			}
+			fmt.Fprintf(&b, "| %v | %v | %v | %v | %v | %v |\n", name, m.Baseline, m.Candidate, m.FormatDelta(), m.Threshold, m.Status())
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
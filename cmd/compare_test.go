@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestParseThresholds(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		defaultArg string
+		perMetric  map[string]string
+		want       thresholds
+		wantErr    bool
+	}{
+		{
+			name:       "relative default",
+			defaultArg: "5%",
+			want:       thresholds{"": {Value: 5, Relative: true}},
+		},
+		{
+			name:       "per metric",
+			defaultArg: "1",
+			perMetric:  map[string]string{"ssim": "1%", "frame_latency_p95": "20"},
+			want: thresholds{
+				"":                  {Value: 1},
+				"ssim":              {Value: 1, Relative: true},
+				"frame_latency_p95": {Value: 20},
+			},
+		},
+		{
+			name:       "invalid default",
+			defaultArg: "five",
+			wantErr:    true,
+		},
+		{
+			name:       "invalid metric threshold",
+			defaultArg: "5%",
+			perMetric:  map[string]string{"ssim": "%"},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown metric",
+			defaultArg: "5%",
+			perMetric:  map[string]string{"bitrate": "1%"},
+			wantErr:    true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseThresholds(tt.defaultArg, tt.perMetric)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareResults(t *testing.T) {
+	results := func(ms ...Metrics) AggregatedResults {
+		rs := make(Repetitions, len(ms))
+		for i := range ms {
+			rs[i] = &Result{Metrics: ms[i]}
+		}
+		return AggregatedResults{"impl": {"tc": rs}}
+	}
+	// series marks the metrics calculated from it as measured.
+	series := plotter.XYs{{X: 0, Y: 1}}
+	for _, tt := range []struct {
+		name            string
+		thresholds      map[string]string
+		baseline        AggregatedResults
+		candidate       AggregatedResults
+		metric          string
+		wantMissing     string
+		wantRegression  bool
+		wantImprovement bool
+		wantComparable  bool
+		wantRegressions int
+	}{
+		{
+			name:           "relative change within threshold",
+			baseline:       results(Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}),
+			candidate:      results(Metrics{AverageSSIM: 0.88, PerFrameSSIM: series}),
+			metric:         "ssim",
+			wantComparable: true,
+		},
+		{
+			name:            "relative regression",
+			baseline:        results(Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}, Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}),
+			candidate:       results(Metrics{AverageSSIM: 0.8, PerFrameSSIM: series}, Metrics{AverageSSIM: 0.8, PerFrameSSIM: series}),
+			metric:          "ssim",
+			wantRegression:  true,
+			wantComparable:  true,
+			wantRegressions: 1,
+		},
+		{
+			name:            "improvement",
+			baseline:        results(Metrics{AverageSSIM: 0.8, PerFrameSSIM: series}),
+			candidate:       results(Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}),
+			metric:          "ssim",
+			wantImprovement: true,
+			wantComparable:  true,
+		},
+		{
+			name:           "absolute change within threshold",
+			thresholds:     map[string]string{"frame_latency_p95": "20"},
+			baseline:       results(Metrics{FrameLatencyPercentiles: Percentiles{P95: 100}, FrameLatency: series}),
+			candidate:      results(Metrics{FrameLatencyPercentiles: Percentiles{P95: 115}, FrameLatency: series}),
+			metric:         "frame_latency_p95",
+			wantComparable: true,
+		},
+		{
+			name:            "absolute regression",
+			thresholds:      map[string]string{"frame_latency_p95": "20"},
+			baseline:        results(Metrics{FrameLatencyPercentiles: Percentiles{P95: 100}, FrameLatency: series}),
+			candidate:       results(Metrics{FrameLatencyPercentiles: Percentiles{P95: 125}, FrameLatency: series}),
+			metric:          "frame_latency_p95",
+			wantRegression:  true,
+			wantComparable:  true,
+			wantRegressions: 1,
+		},
+		{
+			name:      "relative threshold with zero baseline",
+			baseline:  results(Metrics{DroppedFrames: 0, FramesAligned: true}),
+			candidate: results(Metrics{DroppedFrames: 3, FramesAligned: true}),
+			metric:    "dropped_frames",
+		},
+		{
+			name:            "absolute threshold with zero baseline",
+			thresholds:      map[string]string{"dropped_frames": "2"},
+			baseline:        results(Metrics{DroppedFrames: 0, FramesAligned: true}),
+			candidate:       results(Metrics{DroppedFrames: 3, FramesAligned: true}),
+			metric:          "dropped_frames",
+			wantRegression:  true,
+			wantComparable:  true,
+			wantRegressions: 1,
+		},
+		{
+			name:        "missing baseline",
+			baseline:    AggregatedResults{},
+			candidate:   results(Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}),
+			wantMissing: "baseline",
+		},
+		{
+			name:            "missing candidate",
+			baseline:        results(Metrics{AverageSSIM: 0.9, PerFrameSSIM: series}),
+			candidate:       AggregatedResults{"impl": {}},
+			wantMissing:     "candidate",
+			wantRegressions: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := parseThresholds("5%", tt.thresholds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := compareResults(tt.baseline, tt.candidate, ts)
+			if c.Regressions != tt.wantRegressions {
+				t.Errorf("got %v regressions, want %v", c.Regressions, tt.wantRegressions)
+			}
+			if len(c.Results) != 1 {
+				t.Fatalf("got %v results, want 1", len(c.Results))
+			}
+			r := c.Results[0]
+			if r.Implementation != "impl" || r.TestCase != "tc" {
+				t.Errorf("got result of %v/%v, want impl/tc", r.Implementation, r.TestCase)
+			}
+			if r.Missing != tt.wantMissing {
+				t.Fatalf("got missing %q, want %q", r.Missing, tt.wantMissing)
+			}
+			if tt.metric == "" {
+				if len(r.Metrics) > 0 {
+					t.Errorf("unexpected metrics: %+v", r.Metrics)
+				}
+				return
+			}
+			var m *metricComparison
+			for i := range r.Metrics {
+				if r.Metrics[i].Metric.Name == tt.metric {
+					m = &r.Metrics[i]
+				}
+			}
+			if m == nil {
+				t.Fatalf("metric %v not compared", tt.metric)
+			}
+			if m.Regression != tt.wantRegression || m.Improvement != tt.wantImprovement || m.NotComparable == tt.wantComparable {
+				t.Errorf("got %+v, want regression=%v, improvement=%v, comparable=%v", m, tt.wantRegression, tt.wantImprovement, tt.wantComparable)
+			}
+		})
+	}
+}
+
+func TestCompareResultsSkipsUnmeasuredMetrics(t *testing.T) {
+	baseline := AggregatedResults{"impl": {"tc": {{Metrics: Metrics{AverageVMAF: 90, PerFrameVMAF: plotter.XYs{{Y: 90}}}}}}}
+	candidate := AggregatedResults{"impl": {"tc": {{Metrics: Metrics{AverageSSIM: 0.9}}}}}
+	c := compareResults(baseline, candidate, thresholds{"": {Value: 5, Relative: true}})
+	for _, m := range c.Results[0].Metrics {
+		if m.Metric.Name == "vmaf" {
+			t.Errorf("vmaf is compared although it is missing in the candidate: %+v", m)
+		}
+	}
+}
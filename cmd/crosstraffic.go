@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+const (
+	crossTrafficTCP            = "tcp"
+	crossTrafficUDP            = "udp"
+	crossTrafficImplementation = "implementation"
+
+	crossTrafficDir            = "cross_traffic"
+	crossTrafficIperfLog       = "iperf.json"
+	crossTrafficIperfClientLog = "iperf_client.json"
+	defaultIperfImage          = "networkstatic/iperf3"
+	crossTrafficFirstPort      = 5201
+)
+
+// resolveCrossTraffic validates the cross traffic flows of t and looks up the
+// implementations used by implementation flows.
+func resolveCrossTraffic(t *TestCase, is Implementations) error {
+	ids := map[string]bool{}
+	for i := range t.CrossTraffic {
+		f := &t.CrossTraffic[i]
+		if f.ID == "" {
+			f.ID = fmt.Sprintf("flow-%v", i)
+		}
+		if ids[f.ID] {
+			return fmt.Errorf("duplicate cross traffic flow id: %v", f.ID)
+		}
+		ids[f.ID] = true
+		if f.Port == 0 {
+			f.Port = crossTrafficFirstPort + i
+		}
+		if f.Stop.Duration != 0 && f.Stop.Duration <= f.Start.Duration {
+			return fmt.Errorf("cross traffic flow %v stops before it starts", f.ID)
+		}
+		switch f.Type {
+		case crossTrafficTCP, crossTrafficUDP:
+			if f.Image == "" {
+				f.Image = defaultIperfImage
+			}
+		case crossTrafficImplementation:
+			impl, ok := is[f.Implementation]
+			if !ok {
+				return fmt.Errorf("cross traffic flow %v: implementation not found: %v", f.ID, f.Implementation)
+			}
+			impl.Name = f.Implementation
+			f.Endpoints = &impl
+		default:
+			return fmt.Errorf("cross traffic flow %v: unknown type: %q", f.ID, f.Type)
+		}
+	}
+	return nil
+}
+
+// crossTrafficController starts and stops the cross traffic flows of a test
+// run. The flows run in containers sharing the network namespace of the
+// sender and the receiver, so that they share the bottleneck with the media
+// flow. Every container is limited to nanoCPUs, if it is greater than zero.
+type crossTrafficController struct {
+	testbed  *testbed
+	flows    []CrossTrafficFlow
+	dir      string
+	videos   string
+	input    string
+	nanoCPUs int64
+}
+
+// run starts all flows at their start offset and stops them at their stop
+// offset or when ctx is done. It returns after all flows were stopped and
+// their logs were collected.
+func (c *crossTrafficController) run(ctx context.Context) error {
+	start := time.Now()
+	var wg sync.WaitGroup
+	errs := make(chan error, len(c.flows))
+	for _, f := range c.flows {
+		wg.Add(1)
+		go func(f CrossTrafficFlow) {
+			defer wg.Done()
+			if err := c.runFlow(ctx, start, f); err != nil {
+				errs <- fmt.Errorf("flow %v: %w", f.ID, err)
+			}
+		}(f)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+func (c *crossTrafficController) runFlow(ctx context.Context, start time.Time, f CrossTrafficFlow) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(time.Until(start.Add(f.Start.Duration))):
+	}
+
+	dir, err := filepath.Abs(filepath.Join(c.dir, crossTrafficDir, f.ID))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var server, client containerSpec
+	switch f.Type {
+	case crossTrafficTCP, crossTrafficUDP:
+		server, client = c.iperfSpecs(f)
+	case crossTrafficImplementation:
+		server, client = c.implementationSpecs(f, dir)
+	}
+	server.networkOf = "receiver"
+	client.networkOf = "sender"
+	server.nanoCPUs = c.nanoCPUs
+	client.nanoCPUs = c.nanoCPUs
+
+	serverRole, clientRole := crossTrafficRole(f, "receiver"), crossTrafficRole(f, "sender")
+	// Use a separate context for cleaning up, ctx is done when the test
+	// run ends.
+	cleanup := context.Background()
+	defer func() {
+		if err := c.testbed.remove(cleanup, serverRole, clientRole); err != nil {
+			log.Printf("WARNING: cross traffic flow %v: %v\n", f.ID, err)
+		}
+	}()
+
+	log.Printf("starting cross traffic flow %v\n", f.ID)
+	if err = c.testbed.create(ctx, serverRole, server); err != nil {
+		return err
+	}
+	if err = c.testbed.create(ctx, clientRole, client); err != nil {
+		return err
+	}
+	if err = c.testbed.start(ctx, serverRole); err != nil {
+		return err
+	}
+	if err = c.testbed.start(ctx, clientRole); err != nil {
+		return err
+	}
+
+	var stop <-chan time.Time
+	if f.Stop.Duration > 0 {
+		stop = time.After(time.Until(start.Add(f.Stop.Duration)))
+	}
+	select {
+	case <-ctx.Done():
+	case <-stop:
+	}
+	log.Printf("stopping cross traffic flow %v\n", f.ID)
+	if err = c.testbed.stop(cleanup, clientRole, serverRole); err != nil {
+		return err
+	}
+
+	if f.Type == crossTrafficImplementation {
+		return nil
+	}
+	// The throughput is measured by the server, the client only knows the
+	// rate it sent at.
+	if err = c.saveLogs(cleanup, serverRole, filepath.Join(dir, crossTrafficIperfLog)); err != nil {
+		return err
+	}
+	return c.saveLogs(cleanup, clientRole, filepath.Join(dir, crossTrafficIperfClientLog))
+}
+
+// saveLogs writes the output of the container of role to filename.
+func (c *crossTrafficController) saveLogs(ctx context.Context, role, filename string) error {
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return c.testbed.logs(ctx, role, out, c.testbed.output)
+}
+
+func (c *crossTrafficController) iperfSpecs(f CrossTrafficFlow) (server, client containerSpec) {
+	server = containerSpec{
+		image: f.Image,
+		cmd: []string{
+			"-s",
+			"-p", fmt.Sprintf("%v", f.Port),
+			"-i", "1",
+			"--json",
+		},
+	}
+
+	duration := 0
+	if f.Stop.Duration > 0 {
+		duration = int(math.Ceil((f.Stop.Duration - f.Start.Duration).Seconds()))
+	}
+	client = containerSpec{
+		image: f.Image,
+		cmd: []string{
+			"-c", "receiver",
+			"-p", fmt.Sprintf("%v", f.Port),
+			"-t", fmt.Sprintf("%v", duration),
+			"-i", "1",
+			"--json",
+		},
+	}
+	switch f.Type {
+	case crossTrafficTCP:
+		if f.CongestionControl != "" {
+			client.cmd = append(client.cmd, "-C", f.CongestionControl)
+		}
+	case crossTrafficUDP:
+		client.cmd = append(client.cmd, "-u", "-b", fmt.Sprintf("%v", f.Bitrate))
+	}
+	return server, client
+}
+
+func (c *crossTrafficController) implementationSpecs(f CrossTrafficFlow, dir string) (server, client containerSpec) {
+	server = containerSpec{
+		image: f.Endpoints.Receiver.Image,
+		env: []string{
+			"ROLE=receiver",
+			"QLOGDIR=/logs/qlog",
+			"RTPLOGDIR=/logs/rtp",
+			"LOG_FILE=/logs/qrt.log",
+			"STREAMLOGFILE=/logs/stream.log",
+			"DESTINATION=/logs/out.mkv",
+			// The receiver shares the network namespace with the main
+			// receiver, so it has to listen on the port of the flow.
+			fmt.Sprintf("RECEIVER=:%v", f.Port),
+			"RECEIVER_PARAMS=" + strings.TrimSpace(f.Endpoints.Receiver.Params+" "+f.ReceiverParams),
+		},
+		binds: []string{
+			fmt.Sprintf("%v:/logs", filepath.Join(dir, "receiver_logs")),
+		},
+	}
+	client = containerSpec{
+		image: f.Endpoints.Sender.Image,
+		env: []string{
+			"ROLE=sender",
+			"QLOGDIR=/logs/qlog",
+			"RTPLOGDIR=/logs/rtp",
+			"CCLOGFILE=/logs/cc.log",
+			"LOG_FILE=/logs/qrt.log",
+			"STREAMLOGFILE=/logs/stream.log",
+			fmt.Sprintf("RECEIVER=receiver:%v", f.Port),
+			"VIDEOS=" + c.videos,
+			"SENDER_PARAMS=" + strings.TrimSpace(f.Endpoints.Sender.Params+" "+f.SenderParams),
+		},
+		binds: []string{
+			fmt.Sprintf("%v:/logs", filepath.Join(dir, "sender_logs")),
+			fmt.Sprintf("%v:/input:ro", c.input),
+		},
+	}
+	return server, client
+}
+
+func crossTrafficRole(f CrossTrafficFlow, role string) string {
+	return fmt.Sprintf("cross-traffic-%v-%v", f.ID, role)
+}
+
+// iperfResult is the subset of the iperf3 JSON output of the server used to
+// calculate the throughput of a flow.
+type iperfResult struct {
+	Intervals []struct {
+		Sum struct {
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Bytes float64 `json:"bytes"`
+		} `json:"sum"`
+	} `json:"intervals"`
+}
+
+// getCrossTrafficThroughput returns the bytes per second of the flow f
+// measured in dir. The time is shifted by the start offset of the flow.
+func getCrossTrafficThroughput(dir string, f CrossTrafficFlow) (plotter.XYs, error) {
+	flowDir := filepath.Join(dir, crossTrafficDir, f.ID)
+	offset := float64(f.Start.Milliseconds())
+
+	var table plotter.XYs
+	switch f.Type {
+	case crossTrafficImplementation:
+		records, err := readRTPLog(filepath.Join(flowDir, receiverRTPInLogFile))
+		if err != nil {
+			return nil, err
+		}
+		table = rtpBytes(records)
+	default:
+		var result iperfResult
+		if err := parseJSONFile(filepath.Join(flowDir, crossTrafficIperfLog), &result); err != nil {
+			return nil, err
+		}
+		for _, i := range result.Intervals {
+			table = append(table, plotter.XY{
+				X: i.Sum.Start * 1000,
+				Y: i.Sum.Bytes,
+			})
+		}
+	}
+
+	for i := range table {
+		table[i].X += offset
+	}
+	return binToSeconds(table), nil
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestResolveCrossTraffic(t *testing.T) {
+	is := Implementations{
+		"impl": Implementation{
+			Sender:   Endpoint{Image: "sender"},
+			Receiver: Endpoint{Image: "receiver"},
+		},
+	}
+	for _, tt := range []struct {
+		name    string
+		flows   []CrossTrafficFlow
+		want    []CrossTrafficFlow
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			flows: []CrossTrafficFlow{
+				{Type: crossTrafficTCP},
+				{ID: "udp", Type: crossTrafficUDP, Port: 6000, Image: "iperf"},
+			},
+			want: []CrossTrafficFlow{
+				{ID: "flow-0", Type: crossTrafficTCP, Port: crossTrafficFirstPort, Image: defaultIperfImage},
+				{ID: "udp", Type: crossTrafficUDP, Port: 6000, Image: "iperf"},
+			},
+		},
+		{
+			name:  "implementation",
+			flows: []CrossTrafficFlow{{ID: "rtp", Type: crossTrafficImplementation, Implementation: "impl"}},
+			want: []CrossTrafficFlow{{
+				ID:             "rtp",
+				Type:           crossTrafficImplementation,
+				Port:           crossTrafficFirstPort,
+				Implementation: "impl",
+				Endpoints: &Implementation{
+					Sender:   Endpoint{Image: "sender"},
+					Receiver: Endpoint{Image: "receiver"},
+					Name:     "impl",
+				},
+			}},
+		},
+		{
+			name:    "unknown implementation",
+			flows:   []CrossTrafficFlow{{Type: crossTrafficImplementation, Implementation: "other"}},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate id",
+			flows:   []CrossTrafficFlow{{ID: "a", Type: crossTrafficTCP}, {ID: "a", Type: crossTrafficUDP}},
+			wantErr: true,
+		},
+		{
+			name:    "stop before start",
+			flows:   []CrossTrafficFlow{{Type: crossTrafficTCP, Start: ms(2000), Stop: ms(1000)}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			flows:   []CrossTrafficFlow{{Type: "quic"}},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := TestCase{CrossTraffic: tt.flows}
+			err := resolveCrossTraffic(&tc, is)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", tc.CrossTraffic)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(tc.CrossTraffic, tt.want) {
+				t.Errorf("got %+v, want %+v", tc.CrossTraffic, tt.want)
+			}
+		})
+	}
+}
+
+func TestIperfSpecs(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flow   CrossTrafficFlow
+		server string
+		client string
+	}{
+		{
+			name:   "tcp",
+			flow:   CrossTrafficFlow{Type: crossTrafficTCP, Image: "iperf", Port: 5201, CongestionControl: "bbr"},
+			server: "-s -p 5201 -i 1 --json",
+			client: "-c receiver -p 5201 -t 0 -i 1 --json -C bbr",
+		},
+		{
+			name:   "udp",
+			flow:   CrossTrafficFlow{Type: crossTrafficUDP, Image: "iperf", Port: 5202, Bitrate: 1000000, Start: ms(1000), Stop: ms(2500)},
+			server: "-s -p 5202 -i 1 --json",
+			client: "-c receiver -p 5202 -t 2 -i 1 --json -u -b 1000000",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &crossTrafficController{}
+			server, client := c.iperfSpecs(tt.flow)
+			if server.image != "iperf" || client.image != "iperf" {
+				t.Errorf("unexpected images: %v, %v", server.image, client.image)
+			}
+			if want := strings.Fields(tt.server); !reflect.DeepEqual(server.cmd, want) {
+				t.Errorf("server: got %q, want %q", server.cmd, want)
+			}
+			if want := strings.Fields(tt.client); !reflect.DeepEqual(client.cmd, want) {
+				t.Errorf("client: got %q, want %q", client.cmd, want)
+			}
+		})
+	}
+}
+
+func TestGetCrossTrafficThroughput(t *testing.T) {
+	dir := t.TempDir()
+	f := CrossTrafficFlow{ID: "tcp", Type: crossTrafficTCP, Start: Duration{time.Second}}
+	flowDir := filepath.Join(dir, crossTrafficDir, f.ID)
+	if err := os.MkdirAll(flowDir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	log := `{"intervals": [
+		{"sum": {"start": 0, "end": 1, "bytes": 1000}},
+		{"sum": {"start": 1, "end": 2, "bytes": 2000}},
+		{"sum": {"start": 2, "end": 2.5, "bytes": 500}}
+	]}`
+	if err := os.WriteFile(filepath.Join(flowDir, crossTrafficIperfLog), []byte(log), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := getCrossTrafficThroughput(dir, f)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 1000}, {X: 2, Y: 2000}, {X: 3, Y: 500}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	labelRun  = "rtq-runner.run"
+	labelRole = "rtq-runner.role"
+
+	containerStopTimeout = 10 * time.Second
+)
+
+// execFunc runs a command in a container.
+type execFunc func(cmd ...string) error
+
+// containerSpec describes a container of a test run. Containers with an empty
+// networkOf are attached to the network of the test run, using their role
+// as hostname. Otherwise they share the network namespace of the container
+// with the role networkOf. A nanoCPUs value greater than zero limits the
+// CPU time available to the container.
+type containerSpec struct {
+	image     string
+	cmd       []string
+	env       []string
+	binds     []string
+	capAdd    []string
+	networkOf string
+	nanoCPUs  int64
+}
+
+// testbed manages the network and containers of a single test run. All
+// names are prefixed with the ID of the test run, so that test runs do not
+// interfere with each other. The output of commands executed in containers
+// is written to output.
+type testbed struct {
+	cli     *client.Client
+	id      string
+	network string
+	output  io.Writer
+
+	mutex      sync.Mutex
+	containers map[string]string
+	// sharing are the roles of containers sharing the network namespace of
+	// another container.
+	sharing map[string]bool
+}
+
+func newTestbed(cli *client.Client, id string, output io.Writer) *testbed {
+	return &testbed{
+		cli:        cli,
+		id:         id,
+		output:     output,
+		containers: map[string]string{},
+		sharing:    map[string]bool{},
+	}
+}
+
+// newRunID returns a unique ID for a test run of c, which is valid as docker
+// container name.
+func newRunID(c *Config) string {
+	return fmt.Sprintf("rtq-%v-%v-%v", c.Implementation.Name, c.TestCase.Name, strconv.FormatInt(time.Now().UnixNano(), 36))
+}
+
+func (t *testbed) name(role string) string {
+	return fmt.Sprintf("%v-%v", t.id, role)
+}
+
+func (t *testbed) labels(role string) map[string]string {
+	return map[string]string{
+		labelRun:  t.id,
+		labelRole: role,
+	}
+}
+
+func (t *testbed) createNetwork(ctx context.Context) error {
+	resp, err := t.cli.NetworkCreate(ctx, t.id, types.NetworkCreate{
+		CheckDuplicate: true,
+		Labels:         t.labels(""),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create network: %w", err)
+	}
+	t.network = resp.ID
+	return nil
+}
+
+func (t *testbed) containerID(role string) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	id, ok := t.containers[role]
+	if !ok {
+		return "", fmt.Errorf("unknown container: %v", role)
+	}
+	return id, nil
+}
+
+// create creates the container for role. The image is pulled, if it is not
+// available locally.
+func (t *testbed) create(ctx context.Context, role string, spec containerSpec) error {
+	if err := t.pullImage(ctx, spec.image); err != nil {
+		return err
+	}
+
+	config := &container.Config{
+		Image:  spec.image,
+		Cmd:    spec.cmd,
+		Env:    spec.env,
+		Labels: t.labels(role),
+	}
+	hostConfig := &container.HostConfig{
+		Binds:  spec.binds,
+		CapAdd: spec.capAdd,
+		Resources: container.Resources{
+			NanoCPUs: spec.nanoCPUs,
+		},
+	}
+	var networkConfig *network.NetworkingConfig
+	if spec.networkOf != "" {
+		id, err := t.containerID(spec.networkOf)
+		if err != nil {
+			return err
+		}
+		hostConfig.NetworkMode = container.NetworkMode("container:" + id)
+	} else {
+		config.Hostname = role
+		hostConfig.NetworkMode = container.NetworkMode(t.network)
+		networkConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				t.network: {
+					NetworkID: t.network,
+					Aliases:   []string{role},
+				},
+			},
+		}
+	}
+
+	resp, err := t.cli.ContainerCreate(ctx, config, hostConfig, networkConfig, nil, t.name(role))
+	if err != nil {
+		return fmt.Errorf("failed to create container %v: %w", role, err)
+	}
+	for _, w := range resp.Warnings {
+		log.Printf("WARNING: container %v: %v\n", role, w)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.containers[role] = resp.ID
+	t.sharing[role] = spec.networkOf != ""
+	return nil
+}
+
+func (t *testbed) pullImage(ctx context.Context, image string) error {
+	_, _, err := t.cli.ImageInspectWithRaw(ctx, image)
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
+		return err
+	}
+	log.Printf("pulling image %v\n", image)
+	progress, err := t.cli.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %v: %w", image, err)
+	}
+	defer progress.Close()
+	_, err = io.Copy(ioutil.Discard, progress)
+	return err
+}
+
+// start starts the containers of roles and waits until they are ready. A
+// container is ready when it started, or if its image defines a health check,
+// when it is healthy.
+func (t *testbed) start(ctx context.Context, roles ...string) error {
+	waiting := map[string]string{}
+	for _, role := range roles {
+		id, err := t.containerID(role)
+		if err != nil {
+			return err
+		}
+		inspect, err := t.cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		waiting[id] = "start"
+		if hc := inspect.Config.Healthcheck; hc != nil && len(hc.Test) > 0 && hc.Test[0] != "NONE" {
+			waiting[id] = "health_status: healthy"
+		}
+	}
+
+	// The subscription to the events is established asynchronously, replay
+	// the events since now to not miss containers becoming ready before.
+	since := strconv.FormatInt(time.Now().Unix(), 10)
+	eventCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	msgs, errs := t.cli.Events(eventCtx, types.EventsOptions{
+		Since: since,
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("label", fmt.Sprintf("%v=%v", labelRun, t.id)),
+		),
+	})
+
+	for _, role := range roles {
+		id, _ := t.containerID(role)
+		if err := t.cli.ContainerStart(ctx, id, types.ContainerStartOptions{}); err != nil {
+			return fmt.Errorf("failed to start container %v: %w", role, err)
+		}
+	}
+
+	for len(waiting) > 0 {
+		select {
+		case msg := <-msgs:
+			if action, ok := waiting[msg.Actor.ID]; ok && msg.Action == action {
+				log.Printf("container %v is ready\n", msg.Actor.Attributes["name"])
+				delete(waiting, msg.Actor.ID)
+			}
+		case err := <-errs:
+			return fmt.Errorf("failed to receive container events: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// logs streams the output of the container of role to stdout and stderr until
+// the container stops.
+func (t *testbed) logs(ctx context.Context, role string, stdout, stderr io.Writer) error {
+	id, err := t.containerID(role)
+	if err != nil {
+		return err
+	}
+	rc, err := t.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = stdcopy.StdCopy(stdout, stderr, rc)
+	return err
+}
+
+// wait blocks until the first of the containers of roles exited and returns
+// its role.
+func (t *testbed) wait(ctx context.Context, roles ...string) (string, error) {
+	type exit struct {
+		role string
+		err  error
+	}
+	exited := make(chan exit, len(roles))
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, role := range roles {
+		id, err := t.containerID(role)
+		if err != nil {
+			return "", err
+		}
+		go func(role, id string) {
+			statusCh, errCh := t.cli.ContainerWait(waitCtx, id, container.WaitConditionNotRunning)
+			select {
+			case status := <-statusCh:
+				log.Printf("container %v exited with status %v\n", role, status.StatusCode)
+				exited <- exit{role: role}
+			case err := <-errCh:
+				exited <- exit{role: role, err: err}
+			}
+		}(role, id)
+	}
+	select {
+	case e := <-exited:
+		return e.role, e.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (t *testbed) stop(ctx context.Context, roles ...string) error {
+	timeout := containerStopTimeout
+	for _, role := range roles {
+		id, err := t.containerID(role)
+		if err != nil {
+			return err
+		}
+		if err = t.cli.ContainerStop(ctx, id, &timeout); err != nil {
+			return fmt.Errorf("failed to stop container %v: %w", role, err)
+		}
+	}
+	return nil
+}
+
+// remove force removes the containers of roles. Roles without a container
+// are skipped.
+func (t *testbed) remove(ctx context.Context, roles ...string) error {
+	for _, role := range roles {
+		id, err := t.containerID(role)
+		if err != nil {
+			continue
+		}
+		if err = t.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true}); err != nil {
+			return fmt.Errorf("failed to remove container %v: %w", role, err)
+		}
+		t.mutex.Lock()
+		delete(t.containers, role)
+		delete(t.sharing, role)
+		t.mutex.Unlock()
+	}
+	return nil
+}
+
+// exec returns an execFunc running commands in the container of role.
+func (t *testbed) exec(ctx context.Context, role string) execFunc {
+	return func(cmd ...string) error {
+		id, err := t.containerID(role)
+		if err != nil {
+			return err
+		}
+		resp, err := t.cli.ContainerExecCreate(ctx, id, types.ExecConfig{
+			AttachStdout: true,
+			AttachStderr: true,
+			Cmd:          cmd,
+		})
+		if err != nil {
+			return err
+		}
+		attach, err := t.cli.ContainerExecAttach(ctx, resp.ID, types.ExecStartCheck{})
+		if err != nil {
+			return err
+		}
+		defer attach.Close()
+		if _, err = stdcopy.StdCopy(t.output, t.output, attach.Reader); err != nil {
+			return err
+		}
+		inspect, err := t.cli.ContainerExecInspect(ctx, resp.ID)
+		if err != nil {
+			return err
+		}
+		if inspect.ExitCode != 0 {
+			return fmt.Errorf("%v in container %v exited with code %v", cmd, role, inspect.ExitCode)
+		}
+		return nil
+	}
+}
+
+// close removes all remaining containers and the network of the test run.
+func (t *testbed) close() {
+	ctx := context.Background()
+	t.mutex.Lock()
+	var sharing, others []string
+	for role := range t.containers {
+		if t.sharing[role] {
+			sharing = append(sharing, role)
+		} else {
+			others = append(others, role)
+		}
+	}
+	t.mutex.Unlock()
+
+	// Containers sharing the network namespace of another container have to
+	// be removed first.
+	for _, role := range append(sharing, others...) {
+		if err := t.remove(ctx, role); err != nil {
+			log.Printf("WARNING: %v\n", err)
+		}
+	}
+	if t.network != "" {
+		if err := t.cli.NetworkRemove(ctx, t.network); err != nil {
+			log.Printf("WARNING: failed to remove network %v: %v\n", t.id, err)
+		}
+	}
+}
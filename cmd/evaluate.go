@@ -1,9 +1,7 @@
 package cmd
 
 import (
-	"bufio"
 	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,7 +14,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/mengelbart/qlog"
 	"github.com/spf13/cobra"
 	"gonum.org/v1/plot/plotter"
 )
@@ -24,6 +21,7 @@ import (
 const (
 	ssimLogFile            = "ssim.log"
 	psnrLogFile            = "psnr.log"
+	vmafLogFile            = "vmaf.log"
 	senderRTPOutLogFile    = "sender_logs/rtp/rtp_out.log"
 	receiverRTPInLogFile   = "receiver_logs/rtp/rtp_in.log"
 	senderRTCPInLogFile    = "sender_logs/rtp/rtcp_in.log"
@@ -35,10 +33,16 @@ const (
 
 var (
 	resultsOutputFilename string
+	evalFlowLogs          []string
+	evalJUnitFilename     string
+	evalFailOnAssertion   bool
 )
 
 func init() {
 	evalCmd.Flags().StringVarP(&resultsOutputFilename, "output", "o", "result.json", "Results output filename")
+	evalCmd.Flags().StringVar(&evalJUnitFilename, "junit", "", "Output filename for the assertion results as JUnit XML, empty to skip")
+	evalCmd.Flags().BoolVar(&evalFailOnAssertion, "fail-on-assertion", false, "Exit with an error if an assertion of the testcase failed")
+	evalCmd.Flags().StringArrayVar(&evalFlowLogs, "flow", nil, "Additional RTP log or qlog GLOB of a flow sharing the bottleneck as role:id=path, role is sender or receiver")
 
 	rootCmd.AddCommand(evalCmd)
 }
@@ -47,158 +51,234 @@ var evalCmd = &cobra.Command{
 	Use:   "eval",
 	Short: "Evaluate results of a previous test run",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return eval(resultsOutputFilename)
+		var flows []FlowLog
+		for _, f := range evalFlowLogs {
+			flow, err := parseFlowLog(f)
+			if err != nil {
+				return err
+			}
+			flows = append(flows, flow)
+		}
+		if err := eval(".", resultsOutputFilename, flows); err != nil {
+			return err
+		}
+		if evalJUnitFilename == "" && !evalFailOnAssertion {
+			return nil
+		}
+		var result Result
+		if err := parseJSONFile(resultsOutputFilename, &result); err != nil {
+			return err
+		}
+		if evalJUnitFilename != "" {
+			if err := writeJUnit(evalJUnitFilename, &result); err != nil {
+				return err
+			}
+		}
+		if failed := result.failedAssertions(); evalFailOnAssertion && failed > 0 {
+			return fmt.Errorf("%v of %v assertions failed", failed, len(result.Assertions))
+		}
+		return nil
 	},
 }
 
-func eval(outFilename string) error {
+// eval evaluates the logs of the test run in dir and writes the result to
+// outFilename. The flows are evaluated for fairness together with the media
+// flow and the cross traffic of the run.
+func eval(dir, outFilename string, flows []FlowLog) error {
 	result := &Result{}
 
-	err := parseJSONFile("config.json", &result.Config)
+	err := parseJSONFile(filepath.Join(dir, configFilename), &result.Config)
 	if err != nil {
 		return err
 	}
 
-	if err = calculateVideoMetrics(
-		fmt.Sprintf("input/%v", result.Config.TestCase.VideoFile.Name),
-		"output/out.mkv",
-	); err != nil {
+	inputVideo := filepath.Join(inputDir, result.Config.TestCase.VideoFile.Name)
+	outputVideo := filepath.Join(dir, "output/out.mkv")
+	vmaf := hasLibvmaf()
+	if !vmaf {
+		result.Notes = append(result.Notes, "VMAF skipped: ffmpeg is not built with libvmaf")
+	}
+	alignment, err := alignVideos(inputVideo, outputVideo)
+	if err != nil {
+		log.Printf("failed to align frames: %v\n", err)
+		result.Notes = append(result.Notes, "Frame alignment failed, frames are compared by index")
+	} else {
+		result.Metrics.DroppedFrames, result.Metrics.DuplicatedFrames, result.Metrics.FrozenFrames = alignment.count()
+		result.Metrics.FramesAligned = true
+	}
+	if err = calculateVideoMetrics(inputVideo, outputVideo, dir, vmaf, alignment); err != nil {
 		log.Printf("failed to calculate video metrics: %v\n", err)
 	}
 
-	if _, err = os.Stat(ssimLogFile); err == nil {
-		result.Metrics.PerFrameSSIM, err = getXYsFromCSV(ssimLogFile, ' ', ssimValueGetter)
+	ssimLog := filepath.Join(dir, ssimLogFile)
+	psnrLog := filepath.Join(dir, psnrLogFile)
+	vmafLog := filepath.Join(dir, vmafLogFile)
+	senderRTPOutLog := filepath.Join(dir, senderRTPOutLogFile)
+	receiverRTPInLog := filepath.Join(dir, receiverRTPInLogFile)
+	senderRTCPInLog := filepath.Join(dir, senderRTCPInLogFile)
+	receiverRTCPOutLog := filepath.Join(dir, receiverRTCPOutLogFile)
+	receiverQLOGGlob := filepath.Join(dir, receiverQLOGFileGLOB)
+	senderQLOGGlob := filepath.Join(dir, senderQLOGFileGLOB)
+	senderCCLog := filepath.Join(dir, senderCCLogFile)
+
+	if _, err = os.Stat(ssimLog); err == nil {
+		result.Metrics.PerFrameSSIM, err = getXYsFromCSV(ssimLog, ' ', ssimValueGetter)
 		if err != nil {
 			return fmt.Errorf("failed to get ssim metrics table: %w", err)
 		}
 		result.Metrics.AverageSSIM = math.Round(averageMapValues(result.Metrics.PerFrameSSIM)*100) / 100
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", ssimLogFile, err)
+		fmt.Printf("%v not found: %v\n", ssimLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", ssimLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", ssimLog, err)
 	}
 
-	if _, err = os.Stat(psnrLogFile); err == nil {
-		result.Metrics.PerFramePSNR, err = getXYsFromCSV(psnrLogFile, ' ', psnrValueGetter)
+	if _, err = os.Stat(psnrLog); err == nil {
+		result.Metrics.PerFramePSNR, err = getXYsFromCSV(psnrLog, ' ', psnrValueGetter)
 		if err != nil {
 			return fmt.Errorf("failed to get psnr metrics table: %w", err)
 		}
 		result.Metrics.AveragePSNR = math.Round(averageMapValues(result.Metrics.PerFramePSNR)*100) / 100
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", psnrLogFile, err)
+		fmt.Printf("%v not found: %v\n", psnrLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", psnrLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", psnrLog, err)
 	}
 
-	if _, err = os.Stat(senderRTPOutLogFile); err == nil {
-		g := csvValueGetter{timeColumn: 2, valueColumn: 8}
-		var sentRTPTable plotter.XYs
-		if sentRTPTable, err = getXYsFromCSV(senderRTPOutLogFile, '\t', g.get); err != nil {
-			return fmt.Errorf("failed to get rtp out metrics table: %w", err)
+	if vmaf {
+		if _, err = os.Stat(vmafLog); err == nil {
+			result.Metrics.PerFrameVMAF, err = getVMAF(vmafLog)
+			if err != nil {
+				return fmt.Errorf("failed to get vmaf metrics table: %w", err)
+			}
+			result.Metrics.AverageVMAF = math.Round(averageMapValues(result.Metrics.PerFrameVMAF)*100) / 100
+		} else if os.IsNotExist(err) {
+			fmt.Printf("%v not found: %v\n", vmafLog, err)
+		} else {
+			return fmt.Errorf("failed to stat %v: %w", vmafLog, err)
 		}
-		result.Metrics.SentRTP = binToSeconds(sentRTPTable)
-	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", senderRTPOutLogFile, err)
-	} else {
-		return fmt.Errorf("failed to stat %v: %w", senderRTPOutLogFile, err)
 	}
 
-	if _, err = os.Stat(receiverRTPInLogFile); err == nil {
-		g := csvValueGetter{timeColumn: 2, valueColumn: 8}
-		var receivedRTPTable plotter.XYs
-		if receivedRTPTable, err = getXYsFromCSV(receiverRTPInLogFile, '\t', g.get); err != nil {
-			return fmt.Errorf("failed to get rtp in metrics table: %w", err)
+	var sentRTPRecords []rtpRecord
+	if _, err = os.Stat(senderRTPOutLog); err == nil {
+		if sentRTPRecords, err = readRTPLog(senderRTPOutLog); err != nil {
+			return fmt.Errorf("failed to get rtp out records: %w", err)
 		}
-		result.Metrics.ReceivedRTP = binToSeconds(receivedRTPTable)
+		result.Metrics.SentRTP = binToSeconds(rtpBytes(sentRTPRecords))
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", receiverRTPInLogFile, err)
+		fmt.Printf("%v not found: %v\n", senderRTPOutLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", receiverRTPInLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", senderRTPOutLog, err)
 	}
 
-	if _, err = os.Stat(receiverRTCPOutLogFile); err == nil {
-		g := csvValueGetter{timeColumn: 2, valueColumn: 3}
-		var sentRTCPTable plotter.XYs
-		if sentRTCPTable, err = getXYsFromCSV(receiverRTCPOutLogFile, '\t', g.get); err != nil {
+	var receivedRTPRecords []rtpRecord
+	if _, err = os.Stat(receiverRTPInLog); err == nil {
+		if receivedRTPRecords, err = readRTPLog(receiverRTPInLog); err != nil {
+			return fmt.Errorf("failed to get rtp in records: %w", err)
+		}
+		result.Metrics.ReceivedRTP = binToSeconds(rtpBytes(receivedRTPRecords))
+	} else if os.IsNotExist(err) {
+		fmt.Printf("%v not found: %v\n", receiverRTPInLog, err)
+	} else {
+		return fmt.Errorf("failed to stat %v: %w", receiverRTPInLog, err)
+	}
+
+	if len(sentRTPRecords) > 0 && len(receivedRTPRecords) > 0 {
+		result.Metrics.PacketLatency, result.Metrics.FrameLatency = getLatency(
+			sentRTPRecords,
+			receivedRTPRecords,
+			minForwardDelay(result.Config.TestCase),
+		)
+		result.Metrics.PacketLatencyPercentiles = newPercentiles(result.Metrics.PacketLatency)
+		result.Metrics.FrameLatencyPercentiles = newPercentiles(result.Metrics.FrameLatency)
+
+		stats := getRTPStreamStats(sentRTPRecords, receivedRTPRecords)
+		result.Metrics.LossRate = stats.lossRate
+		result.Metrics.PacketLoss = stats.packetLoss
+		result.Metrics.Duplicates = stats.duplicates
+		result.Metrics.DuplicatePackets = stats.duplicatePackets
+		result.Metrics.ReorderExtent = stats.reorderExtent
+		result.Metrics.ReorderedPackets = stats.reorderedPackets
+		result.Metrics.MaxReorderExtent = stats.maxReorderExtent
+		result.Metrics.Jitter = stats.jitter
+		result.Metrics.AverageJitter = stats.averageJitter
+	}
+
+	if _, err = os.Stat(receiverRTCPOutLog); err == nil {
+		var sentRTCPRecords []rtcpRecord
+		if sentRTCPRecords, err = readRTCPLog(receiverRTCPOutLog); err != nil {
 			return err
 		}
-		result.Metrics.SentRTCP = binToSeconds(sentRTCPTable)
+		result.Metrics.SentRTCP = binToSeconds(rtcpBytes(sentRTCPRecords))
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", receiverRTCPOutLogFile, err)
+		fmt.Printf("%v not found: %v\n", receiverRTCPOutLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", receiverRTCPOutLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", receiverRTCPOutLog, err)
 	}
 
-	if _, err = os.Stat(senderRTCPInLogFile); err == nil {
-		g := csvValueGetter{timeColumn: 2, valueColumn: 3}
-		var receivedRTCPTable plotter.XYs
-		if receivedRTCPTable, err = getXYsFromCSV(senderRTCPInLogFile, '\t', g.get); err != nil {
+	if _, err = os.Stat(senderRTCPInLog); err == nil {
+		var receivedRTCPRecords []rtcpRecord
+		if receivedRTCPRecords, err = readRTCPLog(senderRTCPInLog); err != nil {
 			return err
 		}
-		result.Metrics.ReceivedRTCP = binToSeconds(receivedRTCPTable)
+		result.Metrics.ReceivedRTCP = binToSeconds(rtcpBytes(receivedRTCPRecords))
+		if len(receivedRTCPRecords) > 0 && !rtcpPacketsDecoded(receivedRTCPRecords) {
+			result.Notes = append(result.Notes, "RTCP feedback skipped: the RTCP log does not contain decodable packets")
+		}
+
+		stats := getRTCPFeedbackStats(receivedRTCPRecords)
+		result.Metrics.FeedbackTimeline = stats.timeline
+		result.Metrics.FeedbackInterval = stats.interval
+		result.Metrics.AverageFeedbackInterval = stats.averageInterval
+		result.Metrics.ReportFractionLost = stats.fractionLost
+		result.Metrics.NACKs = stats.nacks
+		result.Metrics.NACKedPackets = stats.nackedPackets
+		result.Metrics.FeedbackOverhead, result.Metrics.AverageFeedbackOverhead = feedbackOverhead(result.Metrics.ReceivedRTCP, result.Metrics.SentRTP)
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", senderRTCPInLogFile, err)
+		fmt.Printf("%v not found: %v\n", senderRTCPInLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", senderRTCPInLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", senderRTCPInLog, err)
 	}
 
-	files, err := filepath.Glob(senderQLOGFileGLOB)
+	senderConnections, err := readQLOGConnections(senderQLOGGlob)
 	if err != nil {
-		return fmt.Errorf("failed to GLOB files: %v, %w", senderQLOGFileGLOB, err)
+		return err
 	}
-	if len(files) > 0 {
-		if len(files) != 1 {
-			return fmt.Errorf("found invalid number of qlog files: %v", len(files))
-		}
-		q := qlogDataGetter{path: files[0], metric: qlogPacketSentEventName}
-		var qlogSenderPacketsSent plotter.XYs
-		if qlogSenderPacketsSent, err = q.get(); err != nil {
-			return fmt.Errorf("failed to read QLOG File %v: %w", files[0], err)
-		}
-		result.Metrics.QLOGSenderPacketsSent = binToSeconds(qlogSenderPacketsSent)
-
-		q = qlogDataGetter{path: files[0], metric: qlogPacketReceivedEventName}
-		var qlogSenderPacketsReceived plotter.XYs
-		if qlogSenderPacketsReceived, err = q.get(); err != nil {
-			return fmt.Errorf("failed to read QLOG File %v: %w", files[0], err)
-		}
-		result.Metrics.QLOGSenderPacketsReceived = binToSeconds(qlogSenderPacketsReceived)
-
-		q = qlogDataGetter{path: files[0], metric: qlogMetricsUpdatedEventName}
-		var qlogCongestionWindow plotter.XYs
-		if qlogCongestionWindow, err = q.get(); err != nil {
-			return fmt.Errorf("failed to read QLOG File %v: %w", files[0], err)
+	if len(senderConnections) > 0 {
+		sender := mergeQLOGConnections(senderConnections)
+		result.Metrics.QLOGSenderPacketsSent = sender.PacketsSent
+		result.Metrics.QLOGSenderPacketsReceived = sender.PacketsReceived
+		result.Metrics.QLOGCongestionWindow = rect(sender.CongestionWindow)
+		result.Metrics.QLOGSmoothedRTT = sender.SmoothedRTT
+		result.Metrics.QLOGMinRTT = sender.MinRTT
+		result.Metrics.QLOGLatestRTT = sender.LatestRTT
+		result.Metrics.QLOGBytesInFlight = sender.BytesInFlight
+		result.Metrics.QLOGPacingRate = sender.PacingRate
+		result.Metrics.QLOGPacketsLost = sender.PacketsLost
+		result.Metrics.QLOGLostPackets = sender.LostPackets
+		result.Metrics.QLOGCongestionStates = sender.CongestionStates
+		if len(senderConnections) > 1 {
+			result.Metrics.QLOGSenderConnections = senderConnections
 		}
-		result.Metrics.QLOGCongestionWindow = rect(qlogCongestionWindow)
 	}
 
-	files, err = filepath.Glob(receiverQLOGFileGLOB)
+	receiverConnections, err := readQLOGConnections(receiverQLOGGlob)
 	if err != nil {
-		return fmt.Errorf("failed to GLOB files: %v, %w", receiverQLOGFileGLOB, err)
+		return err
 	}
-	if len(files) > 0 {
-		if len(files) != 1 {
-			return fmt.Errorf("found invalid number of qlog files: %v", len(files))
-		}
-		q := qlogDataGetter{path: files[0], metric: qlogPacketSentEventName}
-		var qlogReceiverPacketsSent plotter.XYs
-		if qlogReceiverPacketsSent, err = q.get(); err != nil {
-			return fmt.Errorf("failed to read QLOG File %v: %w", files[0], err)
+	if len(receiverConnections) > 0 {
+		receiver := mergeQLOGConnections(receiverConnections)
+		result.Metrics.QLOGReceiverPacketsSent = receiver.PacketsSent
+		result.Metrics.QLOGReceiverPacketsReceived = receiver.PacketsReceived
+		if len(receiverConnections) > 1 {
+			result.Metrics.QLOGReceiverConnections = receiverConnections
 		}
-		result.Metrics.QLOGReceiverPacketsSent = binToSeconds(qlogReceiverPacketsSent)
-
-		q = qlogDataGetter{path: files[0], metric: qlogPacketReceivedEventName}
-		var qlogReceiverPacketsReceived plotter.XYs
-		if qlogReceiverPacketsReceived, err = q.get(); err != nil {
-			return fmt.Errorf("failed to read QLOG File %v: %w", files[0], err)
-		}
-		result.Metrics.QLOGReceiverPacketsReceived = binToSeconds(qlogReceiverPacketsReceived)
 	}
 
-	if _, err = os.Stat(senderCCLogFile); err == nil {
+	if _, err = os.Stat(senderCCLog); err == nil {
 		g := csvValueGetter{timeColumn: 0, valueColumn: 1}
 		var ccTargetBitrateTable plotter.XYs
-		if ccTargetBitrateTable, err = getXYsFromCSV(senderCCLogFile, ',', g.get); err != nil {
+		if ccTargetBitrateTable, err = getXYsFromCSV(senderCCLog, ',', g.get); err != nil {
 			return err
 		}
 		result.Metrics.CCTargetBitrate = ccTargetBitrateTable
@@ -206,7 +286,7 @@ func eval(outFilename string) error {
 
 		g = csvValueGetter{timeColumn: 0, valueColumn: 13}
 		var ccRateTransmitted plotter.XYs
-		if ccRateTransmitted, err = getXYsFromCSV(senderCCLogFile, ',', g.get); err != nil {
+		if ccRateTransmitted, err = getXYsFromCSV(senderCCLog, ',', g.get); err != nil {
 			log.Printf("failed to CC rate transmitted: %v\n", err)
 		} else {
 			result.Metrics.CCRateTransmitted = ccRateTransmitted
@@ -214,35 +294,103 @@ func eval(outFilename string) error {
 
 		g = csvValueGetter{timeColumn: 0, valueColumn: 5}
 		var ccSRTT plotter.XYs
-		if ccSRTT, err = getXYsFromCSV("sender_logs/cc.log", ',', g.get); err != nil {
+		if ccSRTT, err = getXYsFromCSV(senderCCLog, ',', g.get); err != nil {
 			return err
 		}
 		result.Metrics.CCSRTT = ccSRTT
 
 	} else if os.IsNotExist(err) {
-		fmt.Printf("%v not found: %v\n", senderCCLogFile, err)
+		fmt.Printf("%v not found: %v\n", senderCCLog, err)
 	} else {
-		return fmt.Errorf("failed to stat %v: %w", senderCCLogFile, err)
+		return fmt.Errorf("failed to stat %v: %w", senderCCLog, err)
+	}
+
+	cc := evaluateCC(result.Config, result.Metrics.CCTargetBitrate, result.Metrics.ReceivedRTP, result.Metrics.PacketLatency)
+	result.Metrics.LinkCapacity = cc.linkCapacity
+	result.Metrics.Utilization = cc.utilization
+	result.Metrics.AverageUtilization = cc.averageUtilization
+	result.Metrics.PhaseUtilization = cc.phaseUtilization
+	result.Metrics.TimeAboveCapacity = cc.timeAboveCapacity
+	result.Metrics.CapacityChanges = cc.capacityChanges
+	result.Metrics.ReactionTime = cc.reactionTime
+	result.Metrics.ReconvergenceTime = cc.reconvergenceTime
+	result.Metrics.Oscillation = cc.oscillation
+	result.Metrics.QueueingDelay = cc.queueingDelay
+	result.Metrics.AverageQueueingDelay = cc.averageQueueingDelay
+
+	for _, f := range result.Config.TestCase.CrossTraffic {
+		throughput, err := getCrossTrafficThroughput(dir, f)
+		if err != nil {
+			log.Printf("failed to get throughput of cross traffic flow %v: %v\n", f.ID, err)
+			continue
+		}
+		if result.Metrics.CrossTrafficThroughput == nil {
+			result.Metrics.CrossTrafficThroughput = map[string]plotter.XYs{}
+		}
+		result.Metrics.CrossTrafficThroughput[f.ID] = throughput
+	}
+
+	throughput, notes, err := flowThroughput(append(defaultFlowLogs(dir, result.Config), flows...))
+	if err != nil {
+		return err
+	}
+	result.Notes = append(result.Notes, notes...)
+	for id, t := range result.Metrics.CrossTrafficThroughput {
+		if _, ok := throughput[id]; !ok {
+			throughput[id] = t
+		}
+	}
+	if len(throughput) > 1 {
+		f := getFairness(result.Config.TestCase.Phases, throughput)
+		result.Metrics.FlowThroughput = throughput
+		result.Metrics.FairnessIndex = f.index
+		result.Metrics.AverageFairnessIndex = f.averageIndex
+		result.Metrics.PhaseFairnessIndex = f.phaseIndex
+		result.Metrics.ThroughputShare = f.share
+		result.Metrics.StarvingFlow = f.starvingFlow
+		result.Metrics.StarvationTime = f.starvationTime
+	}
+
+	checkAssertions(result)
+	for _, a := range result.Assertions {
+		if !a.Passed {
+			log.Printf("assertion %v failed: %v\n", a.Assertion, a.Message)
+		}
 	}
 
 	return saveToJSONFile(outFilename, result)
 }
 
+// linkSchedule returns the link configurations of the testcase of c over
+// time.
+func linkSchedule(c Config) []tcStep {
+	tc := trafficController{
+		phases: c.TestCase.Phases,
+	}
+	if c.TestCase.Trace != nil {
+		var err error
+		if tc.trace, err = loadTrace(*c.TestCase.Trace); err != nil {
+			log.Printf("failed to load trace, using phases for link capacity: %v\n", err)
+		}
+	}
+	return tc.schedule()
+}
+
+// getCapacityFromConfig returns the forward bitrate of the link in kbit/s at
+// every change and at maxX.
 func getCapacityFromConfig(c Config, maxX float64) plotter.XYs {
 	var result plotter.XYs
-	x := float64(0)
-	var lastPhase tcPhase
-	for _, phase := range c.TestCase.Phases {
+	var last tcConfig
+	for _, step := range linkSchedule(c) {
 		result = append(result, plotter.XY{
-			X: x,
-			Y: float64(phase.Config.Bitrate) / 1000.0,
+			X: float64(step.at.Milliseconds()),
+			Y: float64(step.link.forward.Bitrate) / 1000.0,
 		})
-		x += float64(phase.Duration.Milliseconds())
-		lastPhase = phase
+		last = step.link.forward
 	}
 	result = append(result, plotter.XY{
 		X: maxX,
-		Y: float64(lastPhase.Config.Bitrate) / 1000.0,
+		Y: float64(last.Bitrate) / 1000.0,
 	})
 	return result
 }
@@ -341,7 +489,7 @@ func binToSeconds(table plotter.XYs) plotter.XYs {
 	if len(table) <= 0 {
 		return table
 	}
-	bins := int(math.Ceil(float64(table[len(table)-1].X) / 1000.0))
+	bins := int(math.Floor(float64(table[len(table)-1].X)/1000.0)) + 1
 	result := make(plotter.XYs, bins)
 
 	for i := 0; i < bins; i++ {
@@ -397,101 +545,124 @@ func averageMapValues(table plotter.XYs) float64 {
 	return sum / float64(len(table))
 }
 
-func calculateVideoMetrics(inputFile, outputFile string) error {
-	ffmpegLog := "ffmpeg.log"
+// calculateVideoMetrics compares outputFile to inputFile and writes the SSIM
+// and PSNR logs to dir. If vmaf is set, the VMAF log is written, too. If
+// alignment is not nil, every received frame is compared to its matching
+// source frame instead of the source frame with the same index.
+func calculateVideoMetrics(inputFile, outputFile, dir string, vmaf bool, alignment *frameAlignment) error {
+	inputFile, err := filepath.Abs(inputFile)
+	if err != nil {
+		return err
+	}
+	outputFile, err = filepath.Abs(outputFile)
+	if err != nil {
+		return err
+	}
+	ffmpegLog := filepath.Join(dir, "ffmpeg.log")
 	ffmpegLogFile, err := os.Create(ffmpegLog)
 	if err != nil {
 		return err
 	}
-	ffmpeg := exec.Command(
-		"ffmpeg",
-		"-i",
-		inputFile,
-		"-i",
-		outputFile,
-		"-lavfi",
-		"ssim=ssim.log;[0:v][1:v]psnr=psnr.log",
-		"-f",
-		"null",
-		"-",
-	)
+	defer ffmpegLogFile.Close()
+	// Remove the VMAF log of an earlier evaluation, so that it is not read
+	// if ffmpeg fails or VMAF is not calculated this time.
+	if err = os.Remove(filepath.Join(dir, vmafLogFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// Both inputs get the same timestamps, so that the filters compare the
+	// frames in order.
+	filters := []string{
+		fmt.Sprintf("ssim=%v", ssimLogFile),
+		fmt.Sprintf("psnr=%v", psnrLogFile),
+	}
+	if vmaf {
+		filters = append(filters, fmt.Sprintf("libvmaf=log_fmt=json:log_path=%v", vmafLogFile))
+	}
+	var refs, dists string
+	for i, f := range filters {
+		refs += fmt.Sprintf("[ref%v]", i)
+		dists += fmt.Sprintf("[dist%v]", i)
+		// libvmaf expects the distorted video as first input.
+		filters[i] = fmt.Sprintf("[dist%v][ref%v]%v", i, i, f)
+	}
+	graph := strings.Join(append([]string{
+		fmt.Sprintf("[0:v]settb=AVTB,setpts=N,split=%v%v", len(filters), refs),
+		fmt.Sprintf("[1:v]settb=AVTB,setpts=N,split=%v%v", len(filters), dists),
+	}, filters...), ";")
+
+	reference := []string{"-i", inputFile}
+	if alignment != nil {
+		reference = []string{
+			"-f", "rawvideo",
+			"-pix_fmt", "yuv420p",
+			"-s", fmt.Sprintf("%vx%v", alignment.source.width, alignment.source.height),
+			"-r", alignment.source.frameRate,
+			"-i", "pipe:0",
+		}
+	}
+	args := append(reference, "-i", outputFile, "-lavfi", graph, "-f", "null", "-")
+	ffmpeg := exec.Command("ffmpeg", args...)
+	ffmpeg.Dir = dir
 	ffmpeg.Stdout = ffmpegLogFile
 	ffmpeg.Stderr = ffmpegLogFile
-	return ffmpeg.Run()
-}
-
-type qlogDataGetter struct {
-	path   string
-	metric string
-}
+	if alignment == nil {
+		return ffmpeg.Run()
+	}
 
-func (q *qlogDataGetter) get() (plotter.XYs, error) {
-	qlogFile, err := os.Open(q.path)
+	stdin, err := ffmpeg.StdinPipe()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	scanner := bufio.NewScanner(qlogFile)
-	scanner.Split(bufio.ScanLines)
-	var bs []byte
-	for scanner.Scan() {
-		b := scanner.Bytes()
-		x := map[string]interface{}{}
-		if err = json.Unmarshal(b, &x); err == nil {
-			bs = append(bs, append(b, []byte("\n")...)...)
-		}
+	if err = ffmpeg.Start(); err != nil {
+		return err
+	}
+	writeErr := alignment.writeAlignedSource(inputFile, stdin)
+	stdin.Close()
+	if err = ffmpeg.Wait(); err != nil {
+		return err
 	}
+	return writeErr
+}
 
-	var qlogData qlog.QLOGFileNDJSON
-	err = qlogData.UnmarshalNDJSON(bs)
+// hasLibvmaf reports whether the installed ffmpeg provides the libvmaf
+// filter.
+func hasLibvmaf() bool {
+	out, err := exec.Command("ffmpeg", "-hide_banner", "-filters").Output()
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal qlog file: %w", err)
+		return false
 	}
-
-	var table plotter.XYs
-	for _, r := range qlogData.Trace.Events.Events {
-		if r.Name == q.metric {
-			v, err := qlogGetters[q.metric](r)
-			if err == nil {
-				table = append(table, v)
-			}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[1] == "libvmaf" {
+			return true
 		}
 	}
-	return table, nil
-}
-
-const (
-	qlogPacketSentEventName     = "transport:packet_sent"
-	qlogPacketReceivedEventName = "transport:packet_received"
-	qlogMetricsUpdatedEventName = "recovery:metrics_updated"
-)
-
-var qlogGetters = map[string]func(r qlog.EventWrapper) (plotter.XY, error){
-	qlogPacketSentEventName:     qlogPacketSentGetter,
-	qlogPacketReceivedEventName: qlogPacketReceivedGetter,
-	qlogMetricsUpdatedEventName: qlogCongestionWindowGetter,
+	return false
 }
 
-func qlogPacketSentGetter(r qlog.EventWrapper) (plotter.XY, error) {
-	return plotter.XY{
-		X: r.RelativeTime,
-		Y: float64(r.Data.PacketSent.Raw.Length),
-	}, nil
-}
-
-func qlogPacketReceivedGetter(r qlog.EventWrapper) (plotter.XY, error) {
-	return plotter.XY{
-		X: r.RelativeTime,
-		Y: float64(r.Data.PacketReceived.Raw.Length),
-	}, nil
+// vmafLog is the subset of the libvmaf JSON log used to get the VMAF score
+// per frame.
+type vmafLog struct {
+	Frames []struct {
+		FrameNum int `json:"frameNum"`
+		Metrics  struct {
+			VMAF float64 `json:"vmaf"`
+		} `json:"metrics"`
+	} `json:"frames"`
 }
 
-func qlogCongestionWindowGetter(r qlog.EventWrapper) (plotter.XY, error) {
-	if r.Data.CongestionWindow <= 0 {
-		return plotter.XY{}, fmt.Errorf("value not given")
+func getVMAF(filename string) (plotter.XYs, error) {
+	var vmaf vmafLog
+	if err := parseJSONFile(filename, &vmaf); err != nil {
+		return nil, err
 	}
-	return plotter.XY{
-		X: r.RelativeTime,
-		Y: float64(r.Data.CongestionWindow),
-	}, nil
+	result := make(plotter.XYs, len(vmaf.Frames))
+	for i, f := range vmaf.Frames {
+		result[i] = plotter.XY{
+			X: float64(f.FrameNum),
+			Y: f.Metrics.VMAF,
+		}
+	}
+	return result, nil
 }
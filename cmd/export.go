@@ -0,0 +1,525 @@
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// pgfplotsFormat is the figure format written as a pgfplots axis with the
+// coordinates of the series. The other formats are written by gonum/plot.
+const pgfplotsFormat = "pgfplots"
+
+var figureFormats = []string{"pdf", "png", "svg", "eps", "tex", pgfplotsFormat}
+
+var (
+	exportResultsFilename string
+	exportStyleFilename   string
+	exportOutputDir       string
+	exportImplementation  string
+	exportTestcase        string
+)
+
+func init() {
+	exportFiguresCmd.Flags().StringVarP(&exportResultsFilename, "result", "r", "results.json", "filename of the results JSON file")
+	exportFiguresCmd.Flags().StringVarP(&exportStyleFilename, "style", "s", "figure-style.json", "Style file defining the figures and their appearance")
+	exportFiguresCmd.Flags().StringVarP(&exportOutputDir, "output", "o", "figures", "Output directory for the figures")
+	exportFiguresCmd.Flags().StringVarP(&exportImplementation, "implementation", "i", "*", "GLOB pattern of the implementations to export figures of")
+	exportFiguresCmd.Flags().StringVarP(&exportTestcase, "testcase", "c", "*", "GLOB pattern of the testcases to export figures of")
+
+	rootCmd.AddCommand(exportFiguresCmd)
+}
+
+var exportFiguresCmd = &cobra.Command{
+	Use:          "export-figures",
+	Short:        "Export figures of results for publications as defined by a style file",
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var results AggregatedResults
+		if err := parseJSONFile(exportResultsFilename, &results); err != nil {
+			return err
+		}
+		var style FigureStyle
+		if err := parseJSONFile(exportStyleFilename, &style); err != nil {
+			return fmt.Errorf("failed to read style file %v: %w", exportStyleFilename, err)
+		}
+		if err := style.validate(); err != nil {
+			return fmt.Errorf("invalid style file %v: %w", exportStyleFilename, err)
+		}
+		return exportFigures(results, style, exportImplementation, exportTestcase, exportOutputDir)
+	},
+}
+
+// FigureStyle defines the figures to export and their appearance. Lengths
+// are given in centimeters, font sizes and line widths in points. Figures
+// inherit the dimensions of the style unless they set their own.
+type FigureStyle struct {
+	Width     float64      `json:"width"`
+	Height    float64      `json:"height"`
+	Font      FontStyle    `json:"font"`
+	LineWidth float64      `json:"line_width"`
+	Formats   []string     `json:"formats"`
+	Figures   []FigureSpec `json:"figures"`
+}
+
+// FontStyle selects the variant of the Liberation typeface, one of Serif,
+// Sans or Mono, and its size.
+type FontStyle struct {
+	Variant string  `json:"variant"`
+	Size    float64 `json:"size"`
+}
+
+// AxisStyle overrides the label and the range of an axis. TickSpacing sets
+// the distance between two ticks, the ticks are chosen automatically if it is
+// 0.
+type AxisStyle struct {
+	Label       *string  `json:"label,omitempty"`
+	Min         *float64 `json:"min,omitempty"`
+	Max         *float64 `json:"max,omitempty"`
+	TickSpacing float64  `json:"tick_spacing,omitempty"`
+}
+
+// FigureSpec is a figure of the series named by their JSON keys in Metrics.
+// Maps of series are selected by their key to include all entries or by
+// key/id. Labels and Colors are keyed by the same names, colors are given as
+// #rrggbb or #rrggbbaa. Repetition selects a single repetition counting from
+// 1, all repetitions are drawn if it is 0. The x values of time series are
+// given in seconds.
+type FigureSpec struct {
+	Name       string            `json:"name"`
+	Title      string            `json:"title"`
+	Width      float64           `json:"width,omitempty"`
+	Height     float64           `json:"height,omitempty"`
+	X          AxisStyle         `json:"x"`
+	Y          AxisStyle         `json:"y"`
+	Series     []string          `json:"series"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Colors     map[string]string `json:"colors,omitempty"`
+	Repetition int               `json:"repetition,omitempty"`
+	HideLegend bool              `json:"hide_legend,omitempty"`
+}
+
+func (s FigureStyle) validate() error {
+	if len(s.Figures) == 0 {
+		return fmt.Errorf("no figures defined")
+	}
+	if len(s.Formats) == 0 {
+		return fmt.Errorf("no formats defined")
+	}
+	for _, f := range s.Formats {
+		if !containsString(figureFormats, f) {
+			return fmt.Errorf("unknown format %v, expected one of %v", f, strings.Join(figureFormats, ", "))
+		}
+	}
+	names := map[string]bool{}
+	for _, f := range s.Figures {
+		if f.Name == "" {
+			return fmt.Errorf("figure without name")
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate figure %v", f.Name)
+		}
+		names[f.Name] = true
+		if len(f.Series) == 0 {
+			return fmt.Errorf("figure %v has no series", f.Name)
+		}
+		for _, s := range f.Series {
+			if _, ok := seriesDefinition(s); !ok {
+				return fmt.Errorf("figure %v: unknown series %v", f.Name, s)
+			}
+		}
+		for name, c := range f.Colors {
+			if _, err := parseColor(c); err != nil {
+				return fmt.Errorf("figure %v: invalid color of %v: %w", f.Name, name, err)
+			}
+		}
+		if f.Repetition < 0 {
+			return fmt.Errorf("figure %v: invalid repetition %v", f.Name, f.Repetition)
+		}
+	}
+	return nil
+}
+
+// dimensions returns the width and height of the figure f.
+func (s FigureStyle) dimensions(f FigureSpec) (vg.Length, vg.Length) {
+	w, h := s.Width, s.Height
+	if f.Width > 0 {
+		w = f.Width
+	}
+	if f.Height > 0 {
+		h = f.Height
+	}
+	return vg.Length(w) * vg.Centimeter, vg.Length(h) * vg.Centimeter
+}
+
+func (s FigureStyle) font() font.Font {
+	size := s.Font.Size
+	if size <= 0 {
+		size = 10
+	}
+	f := font.From(plot.DefaultFont, vg.Points(size))
+	if s.Font.Variant != "" {
+		f.Variant = font.Variant(s.Font.Variant)
+	}
+	return f
+}
+
+// seriesDefinition returns the interactive plot definition which contains the
+// series name or the map of series it belongs to.
+func seriesDefinition(name string) (interactivePlotDefinition, bool) {
+	key := strings.Split(name, "/")[0]
+	for _, d := range interactivePlotDefinitions {
+		if containsString(d.Series, key) {
+			return d, true
+		}
+	}
+	return interactivePlotDefinition{}, false
+}
+
+func containsString(s []string, e string) bool {
+	for _, x := range s {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
+
+// parseColor parses a color given as #rrggbb or #rrggbbaa.
+func parseColor(s string) (color.Color, error) {
+	if !strings.HasPrefix(s, "#") || (len(s) != 7 && len(s) != 9) {
+		return nil, fmt.Errorf("expected #rrggbb or #rrggbbaa: %v", s)
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 7 {
+		v = v<<8 | 0xff
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// figureSeries is a series of a figure with the points of every selected
+// repetition.
+type figureSeries struct {
+	Label       string
+	Color       color.Color
+	Repetitions [][][2]float64
+}
+
+// exportFigures writes the figures of style for all implementations and
+// testcases of results matching the patterns to outDir.
+func exportFigures(results AggregatedResults, style FigureStyle, implementationPattern, testcasePattern, outDir string) error {
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+	exported := 0
+	for _, i := range sortedKeys(results) {
+		ok, err := path.Match(implementationPattern, i)
+		if err != nil {
+			return fmt.Errorf("invalid implementation pattern %v: %w", implementationPattern, err)
+		}
+		if !ok {
+			continue
+		}
+		for tc, rs := range results[i] {
+			ok, err := path.Match(testcasePattern, tc)
+			if err != nil {
+				return fmt.Errorf("invalid testcase pattern %v: %w", testcasePattern, err)
+			}
+			if !ok || len(rs) == 0 {
+				continue
+			}
+			for _, f := range style.Figures {
+				n, err := exportFigure(rs, style, f, filepath.Join(outDir, fmt.Sprintf("%v-%v-%v", i, tc, f.Name)))
+				if err != nil {
+					return fmt.Errorf("failed to export figure %v of %v/%v: %w", f.Name, i, tc, err)
+				}
+				exported += n
+			}
+		}
+	}
+	if exported == 0 {
+		return fmt.Errorf("no figures exported for implementations %v and testcases %v", implementationPattern, testcasePattern)
+	}
+	return nil
+}
+
+func sortedKeys(results AggregatedResults) []string {
+	var keys []string
+	for k := range results {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportFigure writes the figure f of the repetitions rs to filename with the
+// extension of every format of style. It returns the number of written files,
+// which is 0 if none of the series of f was measured.
+func exportFigure(rs Repetitions, style FigureStyle, f FigureSpec, filename string) (int, error) {
+	series, def := figureData(rs, f)
+	if len(series) == 0 {
+		log.Printf("skipping figure %v, none of its series were measured\n", filename)
+		return 0, nil
+	}
+	w, h := style.dimensions(f)
+	for _, format := range style.Formats {
+		if format == pgfplotsFormat {
+			err := writeFile(filename+"-pgfplots.tex", func(out io.Writer) error {
+				return writePGFPlots(out, style, f, def, series, w, h)
+			})
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		p, err := plotFigure(style, f, def, series)
+		if err != nil {
+			return 0, err
+		}
+		if err := p.Save(w, h, filename+"."+format); err != nil {
+			return 0, err
+		}
+	}
+	return len(style.Formats), nil
+}
+
+// figureData collects the series of f measured in rs and returns them with
+// the definition of the first series, which sets the default axis labels.
+func figureData(rs Repetitions, f FigureSpec) ([]figureSeries, interactivePlotDefinition) {
+	measured := make([]map[string]plotter.XYs, len(rs))
+	for i, r := range rs {
+		measured[i] = metricsSeries(&r.Metrics)
+	}
+	if f.Repetition > 0 {
+		if f.Repetition > len(rs) {
+			return nil, interactivePlotDefinition{}
+		}
+		measured = measured[f.Repetition-1 : f.Repetition]
+	}
+
+	var first interactivePlotDefinition
+	var series []figureSeries
+	for _, selected := range f.Series {
+		def, _ := seriesDefinition(selected)
+		names := map[string]bool{}
+		for _, m := range measured {
+			for name := range m {
+				if name == selected || (!strings.Contains(selected, "/") && strings.HasPrefix(name, selected+"/")) {
+					names[name] = true
+				}
+			}
+		}
+		var sorted []string
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			s := figureSeries{
+				Label: name,
+				Color: flowColors[len(series)%len(flowColors)],
+			}
+			for _, key := range []string{selected, name} {
+				if l, ok := f.Labels[key]; ok {
+					s.Label = l
+				}
+				if c, ok := f.Colors[key]; ok {
+					s.Color, _ = parseColor(c)
+				}
+			}
+			for _, m := range measured {
+				if xys, ok := m[name]; ok {
+					s.Repetitions = append(s.Repetitions, interactivePoints(xys, def.XScale))
+				}
+			}
+			if len(series) == 0 {
+				first = def
+			}
+			series = append(series, s)
+		}
+	}
+	return series, first
+}
+
+// plotFigure plots the series of the figure f in the style of style.
+func plotFigure(style FigureStyle, f FigureSpec, def interactivePlotDefinition, series []figureSeries) (*plot.Plot, error) {
+	fnt := style.font()
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = f.Title
+	p.Title.TextStyle.Font = fnt
+	p.X.Label.TextStyle.Font = fnt
+	p.Y.Label.TextStyle.Font = fnt
+	p.X.Tick.Label.Font = fnt
+	p.Y.Tick.Label.Font = fnt
+	p.Legend.TextStyle.Font = fnt
+	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
+
+	for _, s := range series {
+		for i, points := range s.Repetitions {
+			if len(points) == 0 {
+				continue
+			}
+			xys := make(plotter.XYs, len(points))
+			for j, point := range points {
+				xys[j] = plotter.XY{X: point[0], Y: point[1]}
+			}
+			l, err := plotter.NewLine(xys)
+			if err != nil {
+				return nil, err
+			}
+			l.Color = s.Color
+			if style.LineWidth > 0 {
+				l.Width = vg.Points(style.LineWidth)
+			}
+			p.Add(l)
+			if i == 0 && !f.HideLegend {
+				p.Legend.Add(s.Label, l)
+			}
+		}
+	}
+
+	// The axis ranges are set after adding the lines, which extend them to
+	// the range of their data.
+	applyAxisStyle(&p.X, f.X, def.XLabel)
+	applyAxisStyle(&p.Y, f.Y, def.YLabel)
+	return p, nil
+}
+
+func applyAxisStyle(a *plot.Axis, s AxisStyle, label string) {
+	a.Label.Text = label
+	if s.Label != nil {
+		a.Label.Text = *s.Label
+	}
+	if s.Min != nil {
+		a.Min = *s.Min
+	}
+	if s.Max != nil {
+		a.Max = *s.Max
+	}
+	if s.TickSpacing > 0 {
+		a.Tick.Marker = spacingTicker{spacing: s.TickSpacing}
+	}
+}
+
+// spacingTicker places a tick at every multiple of spacing.
+type spacingTicker struct {
+	spacing float64
+}
+
+func (t spacingTicker) Ticks(min, max float64) []plot.Tick {
+	if (max-min)/t.spacing > 1000 {
+		return plot.DefaultTicks{}.Ticks(min, max)
+	}
+	decimals := 0
+	if s := strconv.FormatFloat(t.spacing, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	var ticks []plot.Tick
+	for k := math.Ceil(min / t.spacing); k*t.spacing <= max; k++ {
+		v := k * t.spacing
+		ticks = append(ticks, plot.Tick{Value: v, Label: strconv.FormatFloat(v, 'f', decimals, 64)})
+	}
+	return ticks
+}
+
+// writePGFPlots writes the figure f as pgfplots axis with the coordinates of
+// its series to w.
+func writePGFPlots(w io.Writer, style FigureStyle, f FigureSpec, def interactivePlotDefinition, series []figureSeries, width, height vg.Length) error {
+	var b strings.Builder
+	size := style.font().Size.Points()
+	fmt.Fprintf(&b, "%% %v, exported by rtq-runner export-figures\n", f.Name)
+	fmt.Fprintf(&b, "\\begin{tikzpicture}[font=\\fontsize{%.4gpt}{%.4gpt}\\selectfont]\n", size, 1.2*size)
+	b.WriteString("\\begin{axis}[\n")
+	fmt.Fprintf(&b, "  width=%.2fcm,\n  height=%.2fcm,\n", float64(width/vg.Centimeter), float64(height/vg.Centimeter))
+	if f.Title != "" {
+		fmt.Fprintf(&b, "  title={%v},\n", texEscape(f.Title))
+	}
+	for _, a := range []struct {
+		name  string
+		style AxisStyle
+		label string
+	}{
+		{"x", f.X, def.XLabel},
+		{"y", f.Y, def.YLabel},
+	} {
+		label := a.label
+		if a.style.Label != nil {
+			label = *a.style.Label
+		}
+		fmt.Fprintf(&b, "  %vlabel={%v},\n", a.name, texEscape(label))
+		if a.style.Min != nil {
+			fmt.Fprintf(&b, "  %vmin=%v,\n", a.name, *a.style.Min)
+		}
+		if a.style.Max != nil {
+			fmt.Fprintf(&b, "  %vmax=%v,\n", a.name, *a.style.Max)
+		}
+		if a.style.TickSpacing > 0 {
+			fmt.Fprintf(&b, "  %vtick distance=%v,\n", a.name, a.style.TickSpacing)
+		}
+	}
+	b.WriteString("  grid=major,\n")
+	if style.LineWidth > 0 {
+		fmt.Fprintf(&b, "  every axis plot/.append style={line width=%vpt},\n", style.LineWidth)
+	}
+	b.WriteString("]\n")
+
+	for _, s := range series {
+		c := color.NRGBAModel.Convert(s.Color).(color.NRGBA)
+		options := fmt.Sprintf("color={rgb,255:red,%v;green,%v;blue,%v}, no markers", c.R, c.G, c.B)
+		if c.A < 255 {
+			options += fmt.Sprintf(", opacity=%.2f", float64(c.A)/255)
+		}
+		for i, points := range s.Repetitions {
+			o := options
+			if i > 0 || f.HideLegend {
+				o += ", forget plot"
+			}
+			fmt.Fprintf(&b, "\\addplot[%v] coordinates {\n", o)
+			for _, p := range points {
+				fmt.Fprintf(&b, "(%v,%v)\n", p[0], p[1])
+			}
+			b.WriteString("};\n")
+			if i == 0 && !f.HideLegend {
+				fmt.Fprintf(&b, "\\addlegendentry{%v}\n", texEscape(s.Label))
+			}
+		}
+	}
+	b.WriteString("\\end{axis}\n\\end{tikzpicture}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var texReplacer = strings.NewReplacer(
+	"\\", "\\textbackslash{}",
+	"_", "\\_",
+	"#", "\\#",
+	"%", "\\%",
+	"&", "\\&",
+	"$", "\\$",
+	"{", "\\{",
+	"}", "\\}",
+	"^", "\\^{}",
+	"~", "\\~{}",
+)
+
+func texEscape(s string) string {
+	return texReplacer.Replace(s)
+}
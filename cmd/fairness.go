@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+const (
+	flowRoleSender   = "sender"
+	flowRoleReceiver = "receiver"
+
+	// mediaFlowID is the ID of the media flow of the implementation under
+	// test.
+	mediaFlowID = "media"
+
+	// starvationThreshold is the fraction of its fair share below which an
+	// active flow counts as starving.
+	starvationThreshold = 0.1
+)
+
+// FlowLog is an RTP or qlog log of a flow sharing the bottleneck, written by
+// the sender or the receiver of the flow. Paths ending in .qlog are read as
+// qlog and may be glob patterns, all other paths are read as RTP logs. Offset
+// is the start of the log in milliseconds after the start of the run.
+type FlowLog struct {
+	Role   string
+	ID     string
+	Path   string
+	Offset float64
+}
+
+// parseFlowLog parses a flow log given as role:id=path.
+func parseFlowLog(s string) (FlowLog, error) {
+	key, path := s, ""
+	if i := strings.Index(s, "="); i >= 0 {
+		key, path = s[:i], s[i+1:]
+	}
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) != 2 || parts[1] == "" || path == "" {
+		return FlowLog{}, fmt.Errorf("invalid flow log %q, expected role:id=path", s)
+	}
+	if parts[0] != flowRoleSender && parts[0] != flowRoleReceiver {
+		return FlowLog{}, fmt.Errorf("invalid role of flow log %q: %v", s, parts[0])
+	}
+	return FlowLog{
+		Role: parts[0],
+		ID:   parts[1],
+		Path: path,
+	}, nil
+}
+
+// defaultFlowLogs returns the logs of the media flow and of the
+// implementation cross traffic flows of the run in dir.
+func defaultFlowLogs(dir string, c Config) []FlowLog {
+	logs := []FlowLog{
+		{Role: flowRoleReceiver, ID: mediaFlowID, Path: filepath.Join(dir, receiverRTPInLogFile)},
+		{Role: flowRoleReceiver, ID: mediaFlowID, Path: filepath.Join(dir, receiverQLOGFileGLOB)},
+		{Role: flowRoleSender, ID: mediaFlowID, Path: filepath.Join(dir, senderRTPOutLogFile)},
+		{Role: flowRoleSender, ID: mediaFlowID, Path: filepath.Join(dir, senderQLOGFileGLOB)},
+	}
+	for _, f := range c.TestCase.CrossTraffic {
+		if f.Type != crossTrafficImplementation {
+			continue
+		}
+		flowDir := filepath.Join(dir, crossTrafficDir, f.ID)
+		offset := float64(f.Start.Milliseconds())
+		logs = append(logs,
+			FlowLog{Role: flowRoleReceiver, ID: f.ID, Path: filepath.Join(flowDir, receiverRTPInLogFile), Offset: offset},
+			FlowLog{Role: flowRoleReceiver, ID: f.ID, Path: filepath.Join(flowDir, receiverQLOGFileGLOB), Offset: offset},
+			FlowLog{Role: flowRoleSender, ID: f.ID, Path: filepath.Join(flowDir, senderRTPOutLogFile), Offset: offset},
+			FlowLog{Role: flowRoleSender, ID: f.ID, Path: filepath.Join(flowDir, senderQLOGFileGLOB), Offset: offset},
+		)
+	}
+	return logs
+}
+
+// flowThroughput reads the bytes per second of every flow. The logs of the
+// receiver of a flow are preferred over the logs of its sender, as they show
+// what passed the bottleneck, RTP logs are preferred over qlogs. Logs which
+// do not exist are skipped. Logs which cannot be read are skipped, too, and
+// reported in the returned notes.
+func flowThroughput(logs []FlowLog) (map[string]plotter.XYs, []string, error) {
+	type flowKey struct {
+		role string
+		id   string
+	}
+	byKey := map[flowKey]plotter.XYs{}
+	var notes []string
+	for _, l := range logs {
+		k := flowKey{role: l.Role, id: l.ID}
+		if len(byKey[k]) > 0 {
+			continue
+		}
+		var table plotter.XYs
+		if filepath.Ext(l.Path) == ".qlog" {
+			connections, err := readQLOGConnections(l.Path)
+			if err != nil {
+				notes = append(notes, fmt.Sprintf("Throughput of flow %v: skipped %v: %v", l.ID, l.Path, err))
+				continue
+			}
+			if len(connections) == 0 {
+				continue
+			}
+			merged := mergeQLOGConnections(connections)
+			table = merged.sent
+			if l.Role == flowRoleReceiver {
+				table = merged.received
+			}
+		} else {
+			files, err := filepath.Glob(l.Path)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to GLOB files: %v, %w", l.Path, err)
+			}
+			for _, f := range files {
+				records, err := readRTPLog(f)
+				if err != nil {
+					notes = append(notes, fmt.Sprintf("Throughput of flow %v: skipped %v: %v", l.ID, f, err))
+					continue
+				}
+				table = append(table, rtpBytes(records)...)
+			}
+			sort.SliceStable(table, func(i, j int) bool {
+				return table[i].X < table[j].X
+			})
+		}
+		if len(table) == 0 {
+			continue
+		}
+		shifted := make(plotter.XYs, len(table))
+		for i, xy := range table {
+			shifted[i] = plotter.XY{X: xy.X + l.Offset, Y: xy.Y}
+		}
+		byKey[k] = binToSeconds(shifted)
+	}
+
+	result := map[string]plotter.XYs{}
+	for k, xys := range byKey {
+		if k.role == flowRoleReceiver || len(byKey[flowKey{role: flowRoleReceiver, id: k.id}]) == 0 {
+			result[k.id] = xys
+		}
+	}
+	return result, notes, nil
+}
+
+// fairness holds the fairness metrics of the flows sharing the bottleneck.
+type fairness struct {
+	index          plotter.XYs
+	averageIndex   float64
+	phaseIndex     []float64
+	share          map[string]float64
+	starvingFlow   string
+	starvationTime float64
+}
+
+// getFairness computes Jain's fairness index of the flows per second. A flow
+// is active from the first to the last second it transmitted data, only
+// active flows are considered.
+func getFairness(phases []tcPhase, flows map[string]plotter.XYs) fairness {
+	f := fairness{share: map[string]float64{}}
+	ids := flowIDs(flows)
+
+	type activity struct{ first, last int }
+	active := map[string]activity{}
+	seconds := 0
+	total := 0.0
+	for _, id := range ids {
+		a := activity{first: -1, last: -1}
+		for i, xy := range flows[id] {
+			if xy.Y > 0 {
+				if a.first < 0 {
+					a.first = i
+				}
+				a.last = i
+			}
+			f.share[id] += xy.Y
+			total += xy.Y
+		}
+		active[id] = a
+		if len(flows[id]) > seconds {
+			seconds = len(flows[id])
+		}
+	}
+	if total > 0 {
+		for id := range f.share {
+			f.share[id] /= total
+		}
+	}
+
+	for s := 0; s < seconds; s++ {
+		var sum, squares float64
+		n := 0
+		for _, id := range ids {
+			if a := active[id]; a.first < 0 || s < a.first || s > a.last {
+				continue
+			}
+			x := 0.0
+			if s < len(flows[id]) {
+				x = flows[id][s].Y
+			}
+			sum += x
+			squares += x * x
+			n++
+		}
+		if n < 2 || squares == 0 {
+			continue
+		}
+		f.index = append(f.index, plotter.XY{X: float64(s), Y: sum * sum / (float64(n) * squares)})
+
+		if f.starvingFlow != "" {
+			continue
+		}
+		fairShare := sum / float64(n)
+		for _, id := range ids {
+			if a := active[id]; a.first < 0 || s < a.first || s > a.last {
+				continue
+			}
+			x := 0.0
+			if s < len(flows[id]) {
+				x = flows[id][s].Y
+			}
+			if x < starvationThreshold*fairShare {
+				f.starvingFlow = id
+				f.starvationTime = float64(s)
+				break
+			}
+		}
+	}
+	f.averageIndex = averageMapValues(f.index)
+	f.phaseIndex = phaseAverages(phases, f.index)
+	return f
+}
+
+// flowIDs returns the IDs of flows sorted by name with the media flow first.
+func flowIDs(flows map[string]plotter.XYs) []string {
+	ids := make([]string, 0, len(flows))
+	for id := range flows {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i] == mediaFlowID || ids[j] == mediaFlowID {
+			return ids[i] == mediaFlowID
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
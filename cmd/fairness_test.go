@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestParseFlowLog(t *testing.T) {
+	for _, tt := range []struct {
+		input   string
+		want    FlowLog
+		wantErr bool
+	}{
+		{input: "sender:media=logs/rtp_out.log", want: FlowLog{Role: flowRoleSender, ID: "media", Path: "logs/rtp_out.log"}},
+		{input: "receiver:tcp-1=a=b.log", want: FlowLog{Role: flowRoleReceiver, ID: "tcp-1", Path: "a=b.log"}},
+		{input: "sender:media", wantErr: true},
+		{input: "sender=rtp.log", wantErr: true},
+		{input: "sender:=rtp.log", wantErr: true},
+		{input: "router:media=rtp.log", wantErr: true},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseFlowLog(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlowIDs(t *testing.T) {
+	got := flowIDs(map[string]plotter.XYs{"udp": nil, mediaFlowID: nil, "b": nil, "a": nil})
+	if want := []string{mediaFlowID, "a", "b", "udp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFlowThroughput(t *testing.T) {
+	// rtpRow returns a row of an RTP log of a packet of size bytes sent or
+	// received at ms.
+	rtpRow := func(ms, seq, size string) string {
+		return strings.Join([]string{"a", "96", ms, "1", seq, "0", "false", "b", size}, "\t")
+	}
+	received := writeTestFile(t, "rtp_in.log", strings.Join([]string{
+		rtpRow("100", "1", "1000"),
+		rtpRow("1500", "2", "500"),
+	}, "\n"))
+	sent := writeTestFile(t, "rtp_out.log", strings.Join([]string{
+		rtpRow("0", "1", "1000"),
+		rtpRow("10", "2", "500"),
+	}, "\n"))
+	missing := filepath.Join(t.TempDir(), "missing.log")
+
+	got, notes, err := flowThroughput([]FlowLog{
+		{Role: flowRoleReceiver, ID: mediaFlowID, Path: received},
+		{Role: flowRoleSender, ID: mediaFlowID, Path: sent},
+		{Role: flowRoleReceiver, ID: "late", Path: missing},
+		{Role: flowRoleSender, ID: "late", Path: sent, Offset: 2000},
+		{Role: flowRoleReceiver, ID: "none", Path: missing},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) > 0 {
+		t.Errorf("unexpected notes: %v", notes)
+	}
+	want := map[string]plotter.XYs{
+		mediaFlowID: {{X: 0, Y: 1000}, {X: 1, Y: 500}},
+		"late":      {{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 1500}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetFairness(t *testing.T) {
+	perSecond := func(ys ...float64) plotter.XYs {
+		xys := make(plotter.XYs, len(ys))
+		for i, y := range ys {
+			xys[i] = plotter.XY{X: float64(i), Y: y}
+		}
+		return xys
+	}
+	for _, tt := range []struct {
+		name           string
+		phases         []tcPhase
+		flows          map[string]plotter.XYs
+		wantIndex      plotter.XYs
+		wantPhaseIndex []float64
+		wantShare      map[string]float64
+		wantStarving   string
+		wantStarvation float64
+	}{
+		{
+			name:      "no flows",
+			wantShare: map[string]float64{},
+		},
+		{
+			name:      "single flow",
+			flows:     map[string]plotter.XYs{mediaFlowID: perSecond(1, 1)},
+			wantShare: map[string]float64{mediaFlowID: 1},
+		},
+		{
+			name:      "equal flows",
+			flows:     map[string]plotter.XYs{mediaFlowID: perSecond(2, 2), "tcp": perSecond(2, 2)},
+			wantIndex: perSecond(1, 1),
+			wantShare: map[string]float64{mediaFlowID: 0.5, "tcp": 0.5},
+		},
+		{
+			name:      "unequal flows",
+			flows:     map[string]plotter.XYs{mediaFlowID: perSecond(3, 3), "tcp": perSecond(1, 1)},
+			wantIndex: perSecond(0.8, 0.8),
+			wantShare: map[string]float64{mediaFlowID: 0.75, "tcp": 0.25},
+		},
+		{
+			name: "inactive flows are not considered",
+			flows: map[string]plotter.XYs{
+				mediaFlowID: perSecond(0, 4, 4),
+				"tcp":       perSecond(4, 4),
+			},
+			wantIndex: plotter.XYs{{X: 1, Y: 1}},
+			wantShare: map[string]float64{mediaFlowID: 0.5, "tcp": 0.5},
+		},
+		{
+			name:           "starvation",
+			flows:          map[string]plotter.XYs{mediaFlowID: perSecond(10, 10), "udp": perSecond(1, 0.5)},
+			wantIndex:      perSecond(11.0*11/(2*101), 10.5*10.5/(2*100.25)),
+			wantShare:      map[string]float64{mediaFlowID: 20 / 21.5, "udp": 1.5 / 21.5},
+			wantStarving:   "udp",
+			wantStarvation: 1,
+		},
+		{
+			name: "phases",
+			phases: []tcPhase{
+				{Duration: Duration{time.Second}},
+				{},
+			},
+			flows:          map[string]plotter.XYs{mediaFlowID: perSecond(2, 3, 3), "tcp": perSecond(2, 1, 1)},
+			wantIndex:      perSecond(1, 0.8, 0.8),
+			wantPhaseIndex: []float64{1, 0.8},
+			wantShare:      map[string]float64{mediaFlowID: 8.0 / 12, "tcp": 4.0 / 12},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getFairness(tt.phases, tt.flows)
+			if !reflect.DeepEqual(got.index, tt.wantIndex) {
+				t.Errorf("index: got %v, want %v", got.index, tt.wantIndex)
+			}
+			if !reflect.DeepEqual(got.phaseIndex, tt.wantPhaseIndex) {
+				t.Errorf("phase index: got %v, want %v", got.phaseIndex, tt.wantPhaseIndex)
+			}
+			if !reflect.DeepEqual(got.share, tt.wantShare) {
+				t.Errorf("share: got %v, want %v", got.share, tt.wantShare)
+			}
+			if got.starvingFlow != tt.wantStarving || got.starvationTime != tt.wantStarvation {
+				t.Errorf("starvation: got %q at %v, want %q at %v", got.starvingFlow, got.starvationTime, tt.wantStarving, tt.wantStarvation)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// assetsDir is the directory of the stylesheets and scripts of the report,
+// relative to the template directory and the output directory.
+const assetsDir = "assets"
+
+// InteractivePlot is a plot rendered client-side from the points of its
+// series.
+type InteractivePlot struct {
+	Title  string              `json:"title"`
+	XLabel string              `json:"x_label"`
+	YLabel string              `json:"y_label"`
+	Series []InteractiveSeries `json:"series"`
+}
+
+// InteractiveSeries is a named series of [x, y] points.
+type InteractiveSeries struct {
+	Name   string       `json:"name"`
+	Points [][2]float64 `json:"points"`
+}
+
+// interactivePlotDefinition groups series of Metrics, named by their JSON
+// keys, which share the axes of a plot. Maps of series are selected by the
+// key of the map. XScale converts the x values to the unit of XLabel.
+type interactivePlotDefinition struct {
+	Title  string
+	XLabel string
+	YLabel string
+	XScale float64
+	Series []string
+}
+
+var interactivePlotDefinitions = []interactivePlotDefinition{
+	{"SSIM per Frame", "Frames", "SSIM", 1, []string{"per_frame_ssim"}},
+	{"PSNR per Frame", "Frames", "dB", 1, []string{"per_frame_psnr"}},
+	{"VMAF per Frame", "Frames", "VMAF", 1, []string{"per_frame_vmaf"}},
+	{"CC Target Bitrate", "s", "kbit/s", 0.001, []string{"cc_target_bitrate", "cc_rate_transmitted", "link_capacity"}},
+	{"CC RTT", "s", "s", 0.001, []string{"cc_srtt"}},
+	{"RTP and RTCP Bytes", "s", "Bytes", 1, []string{"sent_rtp", "received_rtp", "sent_rtcp", "received_rtcp"}},
+	{"Link Utilization", "s", "received/capacity", 1, []string{"utilization"}},
+	{"Queueing Delay", "s", "ms", 0.001, []string{"queueing_delay"}},
+	{"One-way Delay", "s", "ms", 0.001, []string{"packet_latency", "frame_latency"}},
+	{"RTP Loss Rate", "s", "lost/sent", 1, []string{"loss_rate"}},
+	{"RTP Duplicates", "s", "packets/s", 1, []string{"duplicates"}},
+	{"RTP Reordering Extent", "s", "packets", 0.001, []string{"reorder_extent"}},
+	{"RTP Interarrival Jitter", "s", "ms", 0.001, []string{"jitter"}},
+	{"RTCP Feedback Timeline", "s", "Bytes", 0.001, []string{"feedback_timeline"}},
+	{"RTCP Feedback Interval", "s", "ms", 0.001, []string{"feedback_interval"}},
+	{"RTCP Feedback Overhead", "s", "feedback/media bytes", 1, []string{"feedback_overhead"}},
+	{"RTCP Report Fraction Lost", "s", "fraction lost", 0.001, []string{"report_fraction_lost"}},
+	{"QLOG Bytes", "s", "Bytes", 1, []string{"qlog_sender_packets_sent", "qlog_sender_packets_received", "qlog_receiver_packets_sent", "qlog_receiver_packets_received"}},
+	{"QLOG RTT", "s", "ms", 0.001, []string{"qlog_smoothed_rtt", "qlog_latest_rtt", "qlog_min_rtt"}},
+	{"QLOG Bytes in Flight", "s", "Bytes", 0.001, []string{"qlog_bytes_in_flight", "qlog_congestion_window"}},
+	{"QLOG Pacing Rate", "s", "bit/s", 0.001, []string{"qlog_pacing_rate"}},
+	{"QLOG Packets Lost", "s", "packets/s", 1, []string{"qlog_packets_lost"}},
+	{"Throughput", "s", "Bytes/s", 1, []string{"flow_throughput", "cross_traffic_throughput"}},
+	{"Jain's Fairness Index", "s", "index", 1, []string{"fairness_index"}},
+}
+
+// metricsSeries returns the non-empty series of m by their JSON keys. The
+// series of maps are named key/id.
+func metricsSeries(m *Metrics) map[string]plotter.XYs {
+	series := map[string]plotter.XYs{}
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0]
+		switch s := v.Field(i).Interface().(type) {
+		case plotter.XYs:
+			if len(s) > 0 {
+				series[name] = s
+			}
+		case map[string]plotter.XYs:
+			for id, xys := range s {
+				if len(xys) > 0 {
+					series[name+"/"+id] = xys
+				}
+			}
+		}
+	}
+	return series
+}
+
+// interactivePlots returns the plots of all series of the repetitions rs.
+// Series which are not part of interactivePlotDefinitions get a plot of
+// their own. The throughput of a cross traffic flow is only plotted if it is
+// not part of the flow throughput, which includes the cross traffic flows.
+func interactivePlots(rs Repetitions) []InteractivePlot {
+	series := make([]map[string]plotter.XYs, len(rs))
+	names := map[string]bool{}
+	for i, r := range rs {
+		series[i] = metricsSeries(&r.Metrics)
+		for id := range r.Metrics.FlowThroughput {
+			delete(series[i], "cross_traffic_throughput/"+id)
+		}
+		for name := range series[i] {
+			names[name] = true
+		}
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	definitions := append([]interactivePlotDefinition{}, interactivePlotDefinitions...)
+	used := map[string]bool{}
+	for _, d := range definitions {
+		for _, key := range d.Series {
+			used[key] = true
+		}
+	}
+	for _, name := range sorted {
+		key := strings.Split(name, "/")[0]
+		if !used[key] {
+			definitions = append(definitions, interactivePlotDefinition{name, "", "", 1, []string{key}})
+			used[key] = true
+		}
+	}
+
+	var plots []InteractivePlot
+	for _, d := range definitions {
+		p := InteractivePlot{
+			Title:  d.Title,
+			XLabel: d.XLabel,
+			YLabel: d.YLabel,
+		}
+		for _, key := range d.Series {
+			for _, name := range sorted {
+				if name != key && !strings.HasPrefix(name, key+"/") {
+					continue
+				}
+				for i := range rs {
+					xys, ok := series[i][name]
+					if !ok {
+						continue
+					}
+					label := name
+					if len(rs) > 1 {
+						label = fmt.Sprintf("%v #%v", name, i+1)
+					}
+					p.Series = append(p.Series, InteractiveSeries{
+						Name:   label,
+						Points: interactivePoints(xys, d.XScale),
+					})
+				}
+			}
+		}
+		if len(p.Series) > 0 {
+			plots = append(plots, p)
+		}
+	}
+	return plots
+}
+
+// interactivePoints scales the x values of xys by xScale and drops points
+// which can not be encoded as JSON.
+func interactivePoints(xys plotter.XYs, xScale float64) [][2]float64 {
+	points := make([][2]float64, 0, len(xys))
+	for _, xy := range xys {
+		x, y := xy.X*xScale, xy.Y
+		if math.IsNaN(x) || math.IsInf(x, 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+			continue
+		}
+		points = append(points, [2]float64{x, y})
+	}
+	return points
+}
+
+// assetsLink returns the path of the assets directory relative to the page
+// at link.
+func assetsLink(link string) string {
+	rel, err := filepath.Rel(link, assetsDir)
+	if err != nil {
+		return assetsDir
+	}
+	return filepath.ToSlash(rel)
+}
+
+// copyAssets copies the assets of the template directory to the output
+// directory.
+func copyAssets(templateDir, outDir string) error {
+	src := filepath.Join(templateDir, assetsDir)
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("failed to read assets: %w", err)
+	}
+	dst := filepath.Join(outDir, assetsDir)
+	if err := os.MkdirAll(dst, os.ModePerm); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if err := copyFile(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestInteractivePoints(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		xys    plotter.XYs
+		xScale float64
+		want   [][2]float64
+	}{
+		{
+			name:   "empty",
+			xScale: 1,
+			want:   [][2]float64{},
+		},
+		{
+			name:   "scaled",
+			xys:    plotter.XYs{{X: 0, Y: 1}, {X: 1500, Y: 2}},
+			xScale: 0.001,
+			want:   [][2]float64{{0, 1}, {1.5, 2}},
+		},
+		{
+			name: "invalid values",
+			xys: plotter.XYs{
+				{X: 0, Y: math.NaN()},
+				{X: 1, Y: math.Inf(1)},
+				{X: math.NaN(), Y: 1},
+				{X: 2, Y: 3},
+			},
+			xScale: 1,
+			want:   [][2]float64{{2, 3}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := interactivePoints(tt.xys, tt.xScale); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricsSeries(t *testing.T) {
+	got := metricsSeries(&Metrics{
+		PerFrameSSIM:           plotter.XYs{{X: 0, Y: 1}},
+		CCSRTT:                 plotter.XYs{},
+		CrossTrafficThroughput: map[string]plotter.XYs{"tcp": {{X: 0, Y: 2}}, "idle": nil},
+	})
+	want := map[string]plotter.XYs{
+		"per_frame_ssim":               {{X: 0, Y: 1}},
+		"cross_traffic_throughput/tcp": {{X: 0, Y: 2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInteractivePlots(t *testing.T) {
+	throughput := plotter.XYs{{X: 0, Y: 1000}}
+	rs := Repetitions{
+		{Metrics: Metrics{
+			CCSRTT:                 plotter.XYs{{X: 1000, Y: 0.05}},
+			FlowThroughput:         map[string]plotter.XYs{mediaFlowID: throughput, "tcp": throughput},
+			CrossTrafficThroughput: map[string]plotter.XYs{"tcp": throughput},
+		}},
+		{Metrics: Metrics{
+			CCSRTT:                 plotter.XYs{{X: 2000, Y: 0.1}},
+			CrossTrafficThroughput: map[string]plotter.XYs{"udp": throughput},
+		}},
+	}
+	plots := map[string][]string{}
+	for _, p := range interactivePlots(rs) {
+		for _, s := range p.Series {
+			plots[p.Title] = append(plots[p.Title], s.Name)
+		}
+	}
+	want := map[string][]string{
+		"CC RTT": {"cc_srtt #1", "cc_srtt #2"},
+		"Throughput": {
+			"flow_throughput/" + mediaFlowID + " #1",
+			"flow_throughput/tcp #1",
+			"cross_traffic_throughput/udp #2",
+		},
+	}
+	if !reflect.DeepEqual(plots, want) {
+		t.Errorf("got %v, want %v", plots, want)
+	}
+}
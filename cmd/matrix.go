@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	resultFilename       = "result.json"
+	containerLogFilename = "containers.log"
+	junitFilename        = "junit.xml"
+)
+
+// matrixRun is a single repetition of an implementation/testcase combination
+// of a matrix run.
+type matrixRun struct {
+	implementation Implementation
+	testcase       TestCase
+	repetition     int
+}
+
+func (m matrixRun) dir(outDir string) string {
+	return filepath.Join(outDir, m.implementation.Name, m.testcase.Name, strconv.Itoa(m.repetition))
+}
+
+// selectMatrix returns all combinations of implementations and testcases
+// whose names match the given glob patterns, sorted by implementation and
+// testcase name. Every combination is repeated repetitions times.
+func selectMatrix(is Implementations, ts TestCases, implementationPattern, testcasePattern string, repetitions int) ([]matrixRun, error) {
+	var implementations []string
+	for name := range is {
+		ok, err := path.Match(implementationPattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid implementation pattern %q: %w", implementationPattern, err)
+		}
+		if ok {
+			implementations = append(implementations, name)
+		}
+	}
+	var testcases []string
+	for name := range ts {
+		ok, err := path.Match(testcasePattern, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid testcase pattern %q: %w", testcasePattern, err)
+		}
+		if ok {
+			testcases = append(testcases, name)
+		}
+	}
+	if len(implementations) == 0 {
+		return nil, fmt.Errorf("no implementation matches %q", implementationPattern)
+	}
+	if len(testcases) == 0 {
+		return nil, fmt.Errorf("no testcase matches %q", testcasePattern)
+	}
+	sort.Strings(implementations)
+	sort.Strings(testcases)
+
+	var runs []matrixRun
+	for _, in := range implementations {
+		i := is[in]
+		i.Name = in
+		for _, tn := range testcases {
+			t := ts[tn]
+			t.Name = tn
+			if err := resolveCrossTraffic(&t, is); err != nil {
+				return nil, err
+			}
+			if err := validateAssertions(t); err != nil {
+				return nil, err
+			}
+			for r := 0; r < repetitions; r++ {
+				runs = append(runs, matrixRun{
+					implementation: i,
+					testcase:       t,
+					repetition:     r,
+				})
+			}
+		}
+	}
+	return runs, nil
+}
+
+// runAll executes all runs with up to parallel runs at the same time and
+// evaluates them after the last run finished, so that the evaluation does not
+// compete with running experiments for CPU time. Each run gets its own
+// directory below outDir, which is aggregated into aggregateFilename. The
+// assertion results of every run are written as JUnit XML to its directory.
+// Neither failing runs nor failed assertions stop the remaining runs, but
+// both are returned as error.
+func runAll(runs []matrixRun, outDir, aggregateFilename string, parallel int, cpusPerRun float64) error {
+	parallel = cpuBudget(parallel, cpusPerRun)
+	cpus := 0.0
+	if parallel > 1 {
+		cpus = cpusPerRun
+	}
+
+	// Every index of failed is only accessed by the worker handling the run.
+	failed := make([]bool, len(runs))
+	fail := func(i int, err error) {
+		log.Printf("run %v x %v, repetition %v failed: %v\n", runs[i].implementation.Name, runs[i].testcase.Name, runs[i].repetition, err)
+		failed[i] = true
+	}
+
+	parallelize(len(runs), parallel, func(i int) {
+		r := runs[i]
+		log.Printf("starting run %v/%v: %v x %v, repetition %v\n", i+1, len(runs), r.implementation.Name, r.testcase.Name, r.repetition)
+		if err := runOne(r, outDir, cpus, parallel > 1); err != nil {
+			fail(i, err)
+		}
+	})
+	failedAssertions := make([]int, len(runs))
+	parallelize(len(runs), parallel, func(i int) {
+		if failed[i] {
+			return
+		}
+		dir := runs[i].dir(outDir)
+		if err := eval(dir, filepath.Join(dir, resultFilename), nil); err != nil {
+			fail(i, err)
+			return
+		}
+		var err error
+		if failedAssertions[i], err = reportAssertions(dir); err != nil {
+			fail(i, err)
+		}
+	})
+
+	if err := aggregate(outDir, aggregateFilename, ""); err != nil {
+		return err
+	}
+	count, assertions := 0, 0
+	for i, f := range failed {
+		if f {
+			count++
+		}
+		assertions += failedAssertions[i]
+	}
+	var errs []string
+	if count > 0 {
+		errs = append(errs, fmt.Sprintf("%v of %v runs failed", count, len(runs)))
+	}
+	if assertions > 0 {
+		errs = append(errs, fmt.Sprintf("%v assertions failed", assertions))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// reportAssertions writes the assertion results of the run in dir as JUnit XML
+// to the same directory and returns the number of failed assertions.
+func reportAssertions(dir string) (int, error) {
+	var result Result
+	if err := parseJSONFile(filepath.Join(dir, resultFilename), &result); err != nil {
+		return 0, err
+	}
+	if len(result.Config.TestCase.Assertions) == 0 {
+		return 0, nil
+	}
+	if err := writeJUnit(filepath.Join(dir, junitFilename), &result); err != nil {
+		return 0, err
+	}
+	return result.failedAssertions(), nil
+}
+
+// runOne executes r with a CPU limit of cpus. If quiet is set, the output of
+// the containers is written to a file in the directory of the run instead of
+// stdout.
+func runOne(r matrixRun, outDir string, cpus float64, quiet bool) error {
+	dir := r.dir(outDir)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if quiet {
+		f, err := os.Create(filepath.Join(dir, containerLogFilename))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		output = f
+	}
+
+	return run(&Config{
+		Date:           time.Unix(runDate, 0),
+		Implementation: r.implementation,
+		TestCase:       r.testcase,
+		Timeout:        timeout,
+		Repetition:     r.repetition,
+		CPUs:           cpus,
+	}, dir, output)
+}
+
+// cpuBudget returns the number of runs which can be executed in parallel
+// without oversubscribing the CPUs of the host, when each run uses
+// cpusPerRun CPUs. It is at most parallel and at least one.
+func cpuBudget(parallel int, cpusPerRun float64) int {
+	if parallel <= 1 || cpusPerRun <= 0 {
+		return 1
+	}
+	budget := int(float64(runtime.NumCPU()) / cpusPerRun)
+	if budget < 1 {
+		budget = 1
+	}
+	if parallel > budget {
+		log.Printf("WARNING: %v parallel runs with %v CPUs each exceed the %v available CPUs, running %v runs in parallel\n", parallel, cpusPerRun, runtime.NumCPU(), budget)
+		return budget
+	}
+	return parallel
+}
+
+// parallelize calls f for every index below n with at most workers calls
+// running at the same time.
+func parallelize(n, workers int, f func(i int)) {
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				f(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
@@ -0,0 +1,85 @@
+package cmd
+
+// metricDefinition describes a scalar metric of a result. measured reports
+// whether a run has the data the metric is calculated from, runs without it
+// are neither compared nor pass assertions on the metric.
+// Metrics which are also measured per phase of the testcase set perPhase.
+type metricDefinition struct {
+	Name           string
+	Unit           string
+	HigherIsBetter bool
+
+	value    func(*Metrics) float64
+	measured func(*Metrics) bool
+	perPhase func(*Metrics) []float64
+}
+
+// statistic summarizes the metric over the repetitions it was measured in.
+func (d metricDefinition) statistic(rs Repetitions) Statistic {
+	if d.measured != nil {
+		rs = rs.filter(d.measured)
+	}
+	return rs.statistic(d.value)
+}
+
+// metricDefinitions are the scalar metrics which are compared between
+// results and can be asserted by testcases.
+var metricDefinitions = []metricDefinition{
+	{Name: "ssim", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AverageSSIM }, measured: func(m *Metrics) bool { return len(m.PerFrameSSIM) > 0 }},
+	{Name: "psnr", Unit: "dB", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AveragePSNR }, measured: func(m *Metrics) bool { return len(m.PerFramePSNR) > 0 }},
+	{Name: "vmaf", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AverageVMAF }, measured: hasVMAF},
+	{Name: "target_bitrate", Unit: "kbit/s", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AverageTargetBitrate }, measured: hasTargetBitrate},
+	{Name: "dropped_frames", Unit: "frames", value: func(m *Metrics) float64 { return float64(m.DroppedFrames) }, measured: func(m *Metrics) bool { return m.FramesAligned }},
+	{Name: "frozen_frames", Unit: "frames", value: func(m *Metrics) float64 { return float64(m.FrozenFrames) }, measured: func(m *Metrics) bool { return m.FramesAligned }},
+	{Name: "frame_latency_p50", Unit: "ms", value: func(m *Metrics) float64 { return m.FrameLatencyPercentiles.P50 }, measured: hasFrameLatency},
+	{Name: "frame_latency_p95", Unit: "ms", value: func(m *Metrics) float64 { return m.FrameLatencyPercentiles.P95 }, measured: hasFrameLatency},
+	{Name: "frame_latency_p99", Unit: "ms", value: func(m *Metrics) float64 { return m.FrameLatencyPercentiles.P99 }, measured: hasFrameLatency},
+	{Name: "packet_latency_p95", Unit: "ms", value: func(m *Metrics) float64 { return m.PacketLatencyPercentiles.P95 }, measured: func(m *Metrics) bool { return len(m.PacketLatency) > 0 }},
+	{Name: "packet_loss", value: func(m *Metrics) float64 { return m.PacketLoss }, measured: hasRTP},
+	{Name: "jitter", Unit: "ms", value: func(m *Metrics) float64 { return m.AverageJitter }, measured: hasRTP},
+	{Name: "utilization", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AverageUtilization }, measured: func(m *Metrics) bool { return len(m.Utilization) > 0 }, perPhase: func(m *Metrics) []float64 { return m.PhaseUtilization }},
+	{Name: "time_above_capacity", Unit: "s", value: func(m *Metrics) float64 { return m.TimeAboveCapacity }, measured: hasCapacityAndTargetBitrate},
+	{Name: "reaction_time", Unit: "ms", value: func(m *Metrics) float64 { return m.ReactionTime }, measured: func(m *Metrics) bool { return hasAdaptation(m, true) }},
+	{Name: "reconvergence_time", Unit: "ms", value: func(m *Metrics) float64 { return m.ReconvergenceTime }, measured: func(m *Metrics) bool { return hasAdaptation(m, false) }},
+	{Name: "oscillation", Unit: "kbit/s", value: func(m *Metrics) float64 { return m.Oscillation }, measured: hasCapacityAndTargetBitrate},
+	{Name: "queueing_delay", Unit: "ms", value: func(m *Metrics) float64 { return m.AverageQueueingDelay }, measured: func(m *Metrics) bool { return len(m.QueueingDelay) > 0 }},
+	{Name: "fairness_index", HigherIsBetter: true, value: func(m *Metrics) float64 { return m.AverageFairnessIndex }, measured: hasFlows, perPhase: func(m *Metrics) []float64 { return m.PhaseFairnessIndex }},
+}
+
+func hasTargetBitrate(m *Metrics) bool {
+	return len(m.CCTargetBitrate) > 0
+}
+
+func hasFrameLatency(m *Metrics) bool {
+	return len(m.FrameLatency) > 0
+}
+
+// hasRTP reports whether RTP packets were sent and received, which is
+// required for the RTP stream statistics.
+func hasRTP(m *Metrics) bool {
+	return len(m.SentRTP) > 0 && len(m.ReceivedRTP) > 0
+}
+
+func hasCapacityAndTargetBitrate(m *Metrics) bool {
+	return len(m.LinkCapacity) > 0 && hasTargetBitrate(m)
+}
+
+// hasAdaptation reports whether the target bitrate adapted to at least one
+// capacity drop or increase.
+func hasAdaptation(m *Metrics, drop bool) bool {
+	for _, c := range m.CapacityChanges {
+		if c.drop() == drop && c.Adaptation >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func lookupMetric(name string) (metricDefinition, bool) {
+	for _, d := range metricDefinitions {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return metricDefinition{}, false
+}
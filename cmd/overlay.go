@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	overlayDir = "testcases"
+
+	overlayTargetBitratePlotFileName = "%v-overlay-cc-target-bitrate.svg"
+	overlayReceivedRTPPlotFileName   = "%v-overlay-rtp-in.svg"
+	overlaySRTTPlotFileName          = "%v-overlay-cc-srtt.svg"
+	overlaySSIMPlotFileName          = "%v-overlay-ssim.svg"
+)
+
+// IndexTestCaseLink links the overlay page of a testcase from the index page.
+type IndexTestCaseLink struct {
+	TestCase        string
+	Implementations []string
+	Link            string
+}
+
+// overlayRow summarizes the repetitions of an implementation on the overlay
+// page of a testcase.
+type overlayRow struct {
+	Implementation string
+	Link           string
+	Repetitions    int
+
+	SSIM          Statistic
+	TargetBitrate Statistic
+	Utilization   Statistic
+}
+
+type overlayInput struct {
+	Assets   string
+	TestCase string
+	Rows     []overlayRow
+
+	TargetBitratePlotSVG string
+	ReceivedRTPPlotSVG   string
+	SRTTPlotSVG          string
+	SSIMPlotSVG          string
+}
+
+// buildOverlayPages builds a page for every testcase of results, which
+// overlays the series of all implementations matching pattern. Testcases with
+// less than two matching implementations are skipped.
+func buildOverlayPages(results AggregatedResults, pattern, outDir string) ([]IndexTestCaseLink, error) {
+	testcases, err := overlayTestCases(results, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []IndexTestCaseLink
+	for tc, impls := range testcases {
+		link := filepath.Join(overlayDir, tc)
+		names, err := buildOverlayPage(tc, impls, outDir, link)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, IndexTestCaseLink{
+			TestCase:        tc,
+			Implementations: names,
+			Link:            link,
+		})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].TestCase < links[j].TestCase
+	})
+	return links, nil
+}
+
+// overlayTestCases groups the results of the implementations matching pattern
+// by testcase. Testcases with less than two implementations are dropped.
+func overlayTestCases(results AggregatedResults, pattern string) (map[string]map[string]Repetitions, error) {
+	testcases := map[string]map[string]Repetitions{}
+	for i, t := range results {
+		ok, err := path.Match(pattern, i)
+		if err != nil {
+			return nil, fmt.Errorf("invalid overlay pattern %v: %w", pattern, err)
+		}
+		if !ok {
+			continue
+		}
+		for tc, rs := range t {
+			if len(rs) == 0 {
+				continue
+			}
+			if _, ok := testcases[tc]; !ok {
+				testcases[tc] = map[string]Repetitions{}
+			}
+			testcases[tc][i] = rs
+		}
+	}
+	for tc, impls := range testcases {
+		if len(impls) < 2 {
+			delete(testcases, tc)
+		}
+	}
+	return testcases, nil
+}
+
+// buildOverlayPage builds the overlay page of testcase and returns the sorted
+// names of the overlaid implementations.
+func buildOverlayPage(testcase string, impls map[string]Repetitions, outDir, link string) ([]string, error) {
+	var names []string
+	for i := range impls {
+		names = append(names, i)
+	}
+	sort.Strings(names)
+
+	input := overlayInput{
+		Assets:               assetsLink(link),
+		TestCase:             testcase,
+		TargetBitratePlotSVG: fmt.Sprintf(overlayTargetBitratePlotFileName, testcase),
+		ReceivedRTPPlotSVG:   fmt.Sprintf(overlayReceivedRTPPlotFileName, testcase),
+		SRTTPlotSVG:          fmt.Sprintf(overlaySRTTPlotFileName, testcase),
+		SSIMPlotSVG:          fmt.Sprintf(overlaySSIMPlotFileName, testcase),
+	}
+	var targetBitrate, receivedRTP, srtt, ssim [][]plotter.XYs
+	maxX := 0.0
+	for _, i := range names {
+		rs := impls[i]
+		input.Rows = append(input.Rows, overlayRow{
+			Implementation: i,
+			Link:           filepath.Join("..", "..", i, testcase),
+			Repetitions:    len(rs),
+			SSIM: rs.statistic(func(m *Metrics) float64 {
+				return m.AverageSSIM
+			}),
+			TargetBitrate: rs.statistic(func(m *Metrics) float64 {
+				return m.AverageTargetBitrate
+			}),
+			Utilization: rs.statistic(func(m *Metrics) float64 {
+				return m.AverageUtilization
+			}),
+		})
+
+		target := rs.series(func(m *Metrics) plotter.XYs { return m.CCTargetBitrate })
+		for _, xys := range target {
+			if len(xys) > 0 && xys[len(xys)-1].X > maxX {
+				maxX = xys[len(xys)-1].X
+			}
+		}
+		targetBitrate = append(targetBitrate, target)
+		receivedRTP = append(receivedRTP, rs.series(func(m *Metrics) plotter.XYs { return kbitPerSecond(m.ReceivedRTP) }))
+		srtt = append(srtt, rs.series(func(m *Metrics) plotter.XYs { return m.CCSRTT }))
+		ssim = append(ssim, rs.series(func(m *Metrics) plotter.XYs { return m.PerFrameSSIM }))
+	}
+	for _, s := range receivedRTP {
+		for _, xys := range s {
+			if len(xys) > 0 && xys[len(xys)-1].X > maxX {
+				maxX = xys[len(xys)-1].X
+			}
+		}
+	}
+	linkCapacity := rect(getCapacityFromConfig(impls[names[0]][0].Config, maxX))
+
+	if err := os.MkdirAll(filepath.Join(outDir, link), os.ModePerm); err != nil {
+		return nil, err
+	}
+	for _, o := range []struct {
+		title, unit, xLabel, filename string
+		ticker                        plot.Ticker
+		series                        [][]plotter.XYs
+		capacity                      plotter.XYs
+	}{
+		{"CC Target Bitrate", "kbit/s", "s", input.TargetBitratePlotSVG, secondsTicker{}, targetBitrate, linkCapacity},
+		{"Received RTP Rate", "kbit/s", "s", input.ReceivedRTPPlotSVG, secondsTicker{}, receivedRTP, linkCapacity},
+		{"CC RTT", "s", "s", input.SRTTPlotSVG, secondsTicker{}, srtt, nil},
+		{"SSIM per Frame", "SSIM", "Frames", input.SSIMPlotSVG, plot.DefaultTicks{}, ssim, nil},
+	} {
+		p, err := plotOverlay(o.title, o.unit, o.xLabel, o.ticker, names, o.series, o.capacity)
+		if err != nil {
+			return nil, err
+		}
+		if err = p.Save(width, 2*height, filepath.Join(outDir, link, o.filename)); err != nil {
+			return nil, err
+		}
+	}
+
+	index, err := os.Create(filepath.Join(outDir, link, "index.html"))
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+	return names, templates.ExecuteTemplate(index, "overlay.html", input)
+}
+
+// kbitPerSecond converts a series of bytes per second over seconds to kbit/s
+// over milliseconds, to share the axes of the CC logs.
+func kbitPerSecond(xys plotter.XYs) plotter.XYs {
+	result := make(plotter.XYs, len(xys))
+	for i, xy := range xys {
+		result[i] = plotter.XY{X: xy.X * 1000, Y: xy.Y * 8 / 1000}
+	}
+	return result
+}
+
+// plotOverlay plots the repetitions of the series of every implementation in
+// names in its own color and draws linkCapacity, if given, above them.
+func plotOverlay(title, unit, xLabel string, ticker plot.Ticker, names []string, series [][]plotter.XYs, linkCapacity plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = title
+	p.X.Label.Text = xLabel
+	p.Y.Label.Text = unit
+	p.X.Tick.Marker = ticker
+	p.Legend.TextStyle.Font = font.From(plot.DefaultFont, 6)
+	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
+
+	for i, s := range series {
+		l, err := addRepetitions(p, s, flowColors[i%len(flowColors)])
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			p.Legend.Add(names[i], l)
+		}
+	}
+
+	if len(linkCapacity) > 0 {
+		capacityLine, err := plotter.NewLine(linkCapacity)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(capacityLine)
+		p.Legend.Add("Link Capacity", capacityLine)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestOverlayTestCases(t *testing.T) {
+	run := Repetitions{&Result{}}
+	results := AggregatedResults{
+		"scream":     {"shared": run, "single": run, "empty": {}},
+		"gcc":        {"shared": run, "empty": run},
+		"scream-udp": {"shared": run, "single": run},
+	}
+	for _, tt := range []struct {
+		pattern string
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			pattern: "*",
+			want: map[string][]string{
+				"shared": {"gcc", "scream", "scream-udp"},
+				"single": {"scream", "scream-udp"},
+			},
+		},
+		{
+			pattern: "scream*",
+			want: map[string][]string{
+				"shared": {"scream", "scream-udp"},
+				"single": {"scream", "scream-udp"},
+			},
+		},
+		{
+			pattern: "gcc",
+			want:    map[string][]string{},
+		},
+		{
+			pattern: "[",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.pattern, func(t *testing.T) {
+			testcases, err := overlayTestCases(results, tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", testcases)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := map[string][]string{}
+			for tc, impls := range testcases {
+				names := []string{}
+				for i := range impls {
+					names = append(names, i)
+				}
+				sort.Strings(names)
+				got[tc] = names
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKbitPerSecond(t *testing.T) {
+	got := kbitPerSecond(plotter.XYs{{X: 0, Y: 125000}, {X: 2, Y: 1000}})
+	want := plotter.XYs{{X: 0, Y: 1000}, {X: 2000, Y: 8}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := kbitPerSecond(nil); len(got) != 0 {
+		t.Errorf("got %v for an empty series", got)
+	}
+}
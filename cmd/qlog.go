@@ -0,0 +1,353 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+const (
+	qlogPacketSentEventName             = "transport:packet_sent"
+	qlogPacketReceivedEventName         = "transport:packet_received"
+	qlogMetricsUpdatedEventName         = "recovery:metrics_updated"
+	qlogPacketLostEventName             = "recovery:packet_lost"
+	qlogCongestionStateUpdatedEventName = "recovery:congestion_state_updated"
+
+	qlogMaxLineSize = 16 * 1024 * 1024
+)
+
+// qlogHeader is the first line of an NDJSON qlog file.
+type qlogHeader struct {
+	Trace struct {
+		CommonFields struct {
+			ODCID string `json:"ODCID"`
+			// ReferenceTime is given in milliseconds since the epoch.
+			ReferenceTime float64 `json:"reference_time"`
+		} `json:"common_fields"`
+	} `json:"trace"`
+}
+
+// qlogEvent is a single event of an NDJSON qlog file. The time is relative to
+// the reference time of the trace in milliseconds.
+type qlogEvent struct {
+	Time float64         `json:"time"`
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+// qlogExtractor collects a metric from the events it is registered for.
+type qlogExtractor interface {
+	events() []string
+	extract(e qlogEvent) error
+}
+
+// readQLOG reads the NDJSON qlog from r in a single pass and dispatches every
+// event to the extractors registered for it. Lines which are not valid events
+// and events whose data cannot be extracted are skipped.
+func readQLOG(r io.Reader, extractors ...qlogExtractor) (qlogHeader, error) {
+	dispatch := map[string][]qlogExtractor{}
+	for _, x := range extractors {
+		for _, name := range x.events() {
+			dispatch[name] = append(dispatch[name], x)
+		}
+	}
+
+	var header qlogHeader
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), qlogMaxLineSize)
+	first := true
+	for scanner.Scan() {
+		if first {
+			first = false
+			if err := json.Unmarshal(scanner.Bytes(), &header); err == nil && header.Trace.CommonFields.ODCID != "" {
+				continue
+			}
+		}
+		var e qlogEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.Name == "" {
+			continue
+		}
+		for _, x := range dispatch[e.Name] {
+			_ = x.extract(e)
+		}
+	}
+	return header, scanner.Err()
+}
+
+// qlogPacketSizeExtractor collects the sizes of sent or received packets.
+type qlogPacketSizeExtractor struct {
+	name  string
+	sizes plotter.XYs
+}
+
+func (x *qlogPacketSizeExtractor) events() []string {
+	return []string{x.name}
+}
+
+func (x *qlogPacketSizeExtractor) extract(e qlogEvent) error {
+	var data struct {
+		Raw struct {
+			Length int `json:"length"`
+		} `json:"raw"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return err
+	}
+	x.sizes = append(x.sizes, plotter.XY{X: e.Time, Y: float64(data.Raw.Length)})
+	return nil
+}
+
+// qlogMetricsExtractor collects the fields of recovery:metrics_updated
+// events. Events only contain the fields which changed.
+type qlogMetricsExtractor struct {
+	congestionWindow plotter.XYs
+	smoothedRTT      plotter.XYs
+	minRTT           plotter.XYs
+	latestRTT        plotter.XYs
+	bytesInFlight    plotter.XYs
+	pacingRate       plotter.XYs
+}
+
+func (x *qlogMetricsExtractor) events() []string {
+	return []string{qlogMetricsUpdatedEventName}
+}
+
+func (x *qlogMetricsExtractor) extract(e qlogEvent) error {
+	var data struct {
+		CongestionWindow *float64 `json:"congestion_window"`
+		SmoothedRTT      *float64 `json:"smoothed_rtt"`
+		MinRTT           *float64 `json:"min_rtt"`
+		LatestRTT        *float64 `json:"latest_rtt"`
+		BytesInFlight    *float64 `json:"bytes_in_flight"`
+		PacingRate       *float64 `json:"pacing_rate"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return err
+	}
+	appendIfSet := func(xys plotter.XYs, v *float64) plotter.XYs {
+		if v == nil {
+			return xys
+		}
+		return append(xys, plotter.XY{X: e.Time, Y: *v})
+	}
+	if data.CongestionWindow != nil && *data.CongestionWindow > 0 {
+		x.congestionWindow = appendIfSet(x.congestionWindow, data.CongestionWindow)
+	}
+	x.smoothedRTT = appendIfSet(x.smoothedRTT, data.SmoothedRTT)
+	x.minRTT = appendIfSet(x.minRTT, data.MinRTT)
+	x.latestRTT = appendIfSet(x.latestRTT, data.LatestRTT)
+	x.bytesInFlight = appendIfSet(x.bytesInFlight, data.BytesInFlight)
+	x.pacingRate = appendIfSet(x.pacingRate, data.PacingRate)
+	return nil
+}
+
+// qlogPacketLostExtractor collects the times packets were declared lost.
+type qlogPacketLostExtractor struct {
+	lost plotter.XYs
+}
+
+func (x *qlogPacketLostExtractor) events() []string {
+	return []string{qlogPacketLostEventName}
+}
+
+func (x *qlogPacketLostExtractor) extract(e qlogEvent) error {
+	x.lost = append(x.lost, plotter.XY{X: e.Time, Y: 1})
+	return nil
+}
+
+// qlogCongestionStateExtractor collects the intervals of congestion states.
+// The last state lasts until the last event of any kind.
+type qlogCongestionStateExtractor struct {
+	states   []CongestionState
+	lastTime float64
+}
+
+func (x *qlogCongestionStateExtractor) events() []string {
+	return []string{
+		qlogCongestionStateUpdatedEventName,
+		qlogPacketSentEventName,
+		qlogPacketReceivedEventName,
+		qlogMetricsUpdatedEventName,
+		qlogPacketLostEventName,
+	}
+}
+
+func (x *qlogCongestionStateExtractor) extract(e qlogEvent) error {
+	x.lastTime = e.Time
+	if n := len(x.states); n > 0 {
+		x.states[n-1].End = e.Time
+	}
+	if e.Name != qlogCongestionStateUpdatedEventName {
+		return nil
+	}
+	var data struct {
+		New string `json:"new"`
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return err
+	}
+	x.states = append(x.states, CongestionState{
+		Start: e.Time,
+		End:   e.Time,
+		State: data.New,
+	})
+	return nil
+}
+
+// CongestionState is the interval in milliseconds a congestion controller
+// stayed in State.
+type CongestionState struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	State string  `json:"state"`
+}
+
+// QLOGConnection holds the qlog series of a single QUIC connection. Times are
+// given in milliseconds relative to the earliest connection of the same role,
+// packet sizes and lost packets are binned to seconds.
+type QLOGConnection struct {
+	ID            string  `json:"id"`
+	ReferenceTime float64 `json:"reference_time"`
+
+	PacketsSent      plotter.XYs       `json:"packets_sent,omitempty"`
+	PacketsReceived  plotter.XYs       `json:"packets_received,omitempty"`
+	CongestionWindow plotter.XYs       `json:"congestion_window,omitempty"`
+	SmoothedRTT      plotter.XYs       `json:"smoothed_rtt,omitempty"`
+	MinRTT           plotter.XYs       `json:"min_rtt,omitempty"`
+	LatestRTT        plotter.XYs       `json:"latest_rtt,omitempty"`
+	BytesInFlight    plotter.XYs       `json:"bytes_in_flight,omitempty"`
+	PacingRate       plotter.XYs       `json:"pacing_rate,omitempty"`
+	PacketsLost      plotter.XYs       `json:"packets_lost,omitempty"`
+	LostPackets      int               `json:"lost_packets"`
+	CongestionStates []CongestionState `json:"congestion_states,omitempty"`
+
+	// sent, received and lost are the unbinned packet events.
+	sent, received, lost plotter.XYs
+}
+
+func readQLOGConnection(path string) (*QLOGConnection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sent := &qlogPacketSizeExtractor{name: qlogPacketSentEventName}
+	received := &qlogPacketSizeExtractor{name: qlogPacketReceivedEventName}
+	metrics := &qlogMetricsExtractor{}
+	lost := &qlogPacketLostExtractor{}
+	states := &qlogCongestionStateExtractor{}
+	header, err := readQLOG(file, sent, received, metrics, lost, states)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read qlog file %v: %w", path, err)
+	}
+
+	id := header.Trace.CommonFields.ODCID
+	if id == "" {
+		id = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &QLOGConnection{
+		ID:               id,
+		ReferenceTime:    header.Trace.CommonFields.ReferenceTime,
+		CongestionWindow: metrics.congestionWindow,
+		SmoothedRTT:      metrics.smoothedRTT,
+		MinRTT:           metrics.minRTT,
+		LatestRTT:        metrics.latestRTT,
+		BytesInFlight:    metrics.bytesInFlight,
+		PacingRate:       metrics.pacingRate,
+		LostPackets:      len(lost.lost),
+		CongestionStates: states.states,
+		sent:             sent.sizes,
+		received:         received.sizes,
+		lost:             lost.lost,
+	}, nil
+}
+
+// shift moves all series of c by offset milliseconds.
+func (c *QLOGConnection) shift(offset float64) {
+	for _, xys := range []plotter.XYs{
+		c.CongestionWindow, c.SmoothedRTT, c.MinRTT, c.LatestRTT,
+		c.BytesInFlight, c.PacingRate, c.sent, c.received, c.lost,
+	} {
+		for i := range xys {
+			xys[i].X += offset
+		}
+	}
+	for i := range c.CongestionStates {
+		c.CongestionStates[i].Start += offset
+		c.CongestionStates[i].End += offset
+	}
+}
+
+func (c *QLOGConnection) bin() {
+	c.PacketsSent = binToSeconds(c.sent)
+	c.PacketsReceived = binToSeconds(c.received)
+	c.PacketsLost = binToSeconds(c.lost)
+}
+
+// readQLOGConnections reads all qlog files matching pattern, one per
+// connection. The connections are sorted by their reference time and their
+// times are shifted to the reference time of the first connection.
+func readQLOGConnections(pattern string) ([]*QLOGConnection, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GLOB files: %v, %w", pattern, err)
+	}
+	var connections []*QLOGConnection
+	for _, f := range files {
+		c, err := readQLOGConnection(f)
+		if err != nil {
+			return nil, err
+		}
+		connections = append(connections, c)
+	}
+	sort.SliceStable(connections, func(i, j int) bool {
+		return connections[i].ReferenceTime < connections[j].ReferenceTime
+	})
+	for _, c := range connections {
+		if c.ReferenceTime > 0 && connections[0].ReferenceTime > 0 {
+			c.shift(c.ReferenceTime - connections[0].ReferenceTime)
+		}
+		c.bin()
+	}
+	return connections, nil
+}
+
+// mergeQLOGConnections merges the series of all connections into a single
+// connection.
+func mergeQLOGConnections(connections []*QLOGConnection) *QLOGConnection {
+	merged := &QLOGConnection{}
+	for _, c := range connections {
+		merged.CongestionWindow = append(merged.CongestionWindow, c.CongestionWindow...)
+		merged.SmoothedRTT = append(merged.SmoothedRTT, c.SmoothedRTT...)
+		merged.MinRTT = append(merged.MinRTT, c.MinRTT...)
+		merged.LatestRTT = append(merged.LatestRTT, c.LatestRTT...)
+		merged.BytesInFlight = append(merged.BytesInFlight, c.BytesInFlight...)
+		merged.PacingRate = append(merged.PacingRate, c.PacingRate...)
+		merged.CongestionStates = append(merged.CongestionStates, c.CongestionStates...)
+		merged.LostPackets += c.LostPackets
+		merged.sent = append(merged.sent, c.sent...)
+		merged.received = append(merged.received, c.received...)
+		merged.lost = append(merged.lost, c.lost...)
+	}
+	for _, xys := range []plotter.XYs{
+		merged.CongestionWindow, merged.SmoothedRTT, merged.MinRTT, merged.LatestRTT,
+		merged.BytesInFlight, merged.PacingRate, merged.sent, merged.received, merged.lost,
+	} {
+		sort.SliceStable(xys, func(i, j int) bool {
+			return xys[i].X < xys[j].X
+		})
+	}
+	sort.SliceStable(merged.CongestionStates, func(i, j int) bool {
+		return merged.CongestionStates[i].Start < merged.CongestionStates[j].Start
+	})
+	merged.bin()
+	return merged
+}
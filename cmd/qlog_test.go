@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+const qlogTestHeader = `{"qlog_format":"NDJSON","trace":{"common_fields":{"ODCID":"abcd","reference_time":1000}}}`
+
+func TestReadQLOG(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		lines      []string
+		wantHeader bool
+		wantSent   plotter.XYs
+		wantCwnd   plotter.XYs
+		wantSRTT   plotter.XYs
+		wantLost   plotter.XYs
+		wantStates []CongestionState
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:       "header only",
+			lines:      []string{qlogTestHeader},
+			wantHeader: true,
+		},
+		{
+			name: "events",
+			lines: []string{
+				qlogTestHeader,
+				`{"time":1,"name":"transport:packet_sent","data":{"raw":{"length":1200}}}`,
+				`{"time":2,"name":"recovery:metrics_updated","data":{"congestion_window":12000,"smoothed_rtt":25.5}}`,
+				`{"time":3,"name":"recovery:congestion_state_updated","data":{"new":"slow_start"}}`,
+				`{"time":4,"name":"recovery:metrics_updated","data":{"congestion_window":0,"smoothed_rtt":30}}`,
+				`{"time":5,"name":"recovery:packet_lost","data":{}}`,
+				`{"time":6,"name":"recovery:congestion_state_updated","data":{"new":"recovery"}}`,
+				`{"time":8,"name":"transport:packet_sent","data":{"raw":{"length":800}}}`,
+				`{"time":9,"name":"transport:connection_closed","data":{}}`,
+			},
+			wantHeader: true,
+			wantSent:   plotter.XYs{{X: 1, Y: 1200}, {X: 8, Y: 800}},
+			wantCwnd:   plotter.XYs{{X: 2, Y: 12000}},
+			wantSRTT:   plotter.XYs{{X: 2, Y: 25.5}, {X: 4, Y: 30}},
+			wantLost:   plotter.XYs{{X: 5, Y: 1}},
+			wantStates: []CongestionState{
+				{Start: 3, End: 6, State: "slow_start"},
+				{Start: 6, End: 8, State: "recovery"},
+			},
+		},
+		{
+			name: "without header",
+			lines: []string{
+				`{"time":1,"name":"transport:packet_sent","data":{"raw":{"length":100}}}`,
+			},
+			wantSent: plotter.XYs{{X: 1, Y: 100}},
+		},
+		{
+			name: "truncated last event",
+			lines: []string{
+				qlogTestHeader,
+				`{"time":1,"name":"transport:packet_sent","data":{"raw":{"length":1200}}}`,
+				`{"time":2,"name":"transport:packet_sent","data":{"raw":{"len`,
+			},
+			wantHeader: true,
+			wantSent:   plotter.XYs{{X: 1, Y: 1200}},
+		},
+		{
+			name: "malformed lines are skipped",
+			lines: []string{
+				qlogTestHeader,
+				`not json`,
+				`{"time":1,"data":{"raw":{"length":1}}}`,
+				`{"time":2,"name":"transport:packet_sent","data":{"raw":{"length":"big"}}}`,
+				`{"time":3,"name":"recovery:metrics_updated","data":[]}`,
+				``,
+				`{"time":4,"name":"transport:packet_sent","data":{"raw":{"length":500}}}`,
+			},
+			wantHeader: true,
+			wantSent:   plotter.XYs{{X: 4, Y: 500}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := &qlogPacketSizeExtractor{name: qlogPacketSentEventName}
+			metrics := &qlogMetricsExtractor{}
+			lost := &qlogPacketLostExtractor{}
+			states := &qlogCongestionStateExtractor{}
+			header, err := readQLOG(strings.NewReader(strings.Join(tt.lines, "\n")), sent, metrics, lost, states)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := header.Trace.CommonFields.ODCID == "abcd"; got != tt.wantHeader {
+				t.Errorf("header: got %+v, want header %v", header, tt.wantHeader)
+			}
+			for _, c := range []struct {
+				name      string
+				got, want plotter.XYs
+			}{
+				{"sent", sent.sizes, tt.wantSent},
+				{"congestion window", metrics.congestionWindow, tt.wantCwnd},
+				{"smoothed RTT", metrics.smoothedRTT, tt.wantSRTT},
+				{"lost", lost.lost, tt.wantLost},
+			} {
+				if !reflect.DeepEqual(c.got, c.want) {
+					t.Errorf("%v: got %v, want %v", c.name, c.got, c.want)
+				}
+			}
+			if !reflect.DeepEqual(states.states, tt.wantStates) {
+				t.Errorf("congestion states: got %+v, want %+v", states.states, tt.wantStates)
+			}
+		})
+	}
+}
+
+func TestReadQLOGLineTooLong(t *testing.T) {
+	line := `{"time":1,"name":"transport:packet_sent","data":{"raw":{"length":1}},"padding":"` + strings.Repeat("x", qlogMaxLineSize) + `"}`
+	if _, err := readQLOG(strings.NewReader(line)); err == nil {
+		t.Error("expected error for a line exceeding the maximum line size")
+	}
+}
+
+func TestReadQLOGConnections(t *testing.T) {
+	dir := t.TempDir()
+	writeQLOG := func(name string, lines ...string) {
+		t.Helper()
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeQLOG("late.qlog",
+		`{"trace":{"common_fields":{"ODCID":"late","reference_time":3000}}}`,
+		`{"time":10,"name":"transport:packet_sent","data":{"raw":{"length":100}}}`,
+		`{"time":20,"name":"recovery:packet_lost","data":{}}`,
+	)
+	writeQLOG("early.qlog",
+		`{"trace":{"common_fields":{"ODCID":"early","reference_time":1000}}}`,
+		`{"time":10,"name":"transport:packet_sent","data":{"raw":{"length":200}}}`,
+		`{"time":500,"name":"transport:packet_received","data":{"raw":{"length":300}}}`,
+	)
+
+	connections, err := readQLOGConnections(filepath.Join(dir, "*.qlog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range connections {
+		ids = append(ids, c.ID)
+	}
+	if want := []string{"early", "late"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("got connections %v, want %v", ids, want)
+	}
+	late := connections[1]
+	if want := (plotter.XYs{{X: 2010, Y: 100}}); !reflect.DeepEqual(late.sent, want) {
+		t.Errorf("late connection is not shifted: got %v, want %v", late.sent, want)
+	}
+	if want := (plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 2, Y: 100}}); !reflect.DeepEqual(late.PacketsSent, want) {
+		t.Errorf("late connection is not binned: got %v, want %v", late.PacketsSent, want)
+	}
+	if late.LostPackets != 1 {
+		t.Errorf("got %v lost packets, want 1", late.LostPackets)
+	}
+
+	merged := mergeQLOGConnections(connections)
+	if want := (plotter.XYs{{X: 10, Y: 200}, {X: 2010, Y: 100}}); !reflect.DeepEqual(merged.sent, want) {
+		t.Errorf("merged sent: got %v, want %v", merged.sent, want)
+	}
+	if want := (plotter.XYs{{X: 0, Y: 300}}); !reflect.DeepEqual(merged.PacketsReceived, want) {
+		t.Errorf("merged received: got %v, want %v", merged.PacketsReceived, want)
+	}
+	if merged.LostPackets != 1 {
+		t.Errorf("merged: got %v lost packets, want 1", merged.LostPackets)
+	}
+
+	if _, err := readQLOGConnections(filepath.Join(dir, "[")); err == nil {
+		t.Error("expected error for an invalid pattern")
+	}
+}
+
+func TestReadQLOGConnectionWithoutHeader(t *testing.T) {
+	path := writeTestFile(t, "connection.qlog", `{"time":500,"name":"transport:packet_received","data":{"raw":{"length":300}}}`)
+	c, err := readQLOGConnection(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "connection" || c.ReferenceTime != 0 {
+		t.Errorf("got id %q and reference time %v, want id from the filename", c.ID, c.ReferenceTime)
+	}
+	if want := (plotter.XYs{{X: 500, Y: 300}}); !reflect.DeepEqual(c.received, want) {
+		t.Errorf("received: got %v, want %v", c.received, want)
+	}
+}
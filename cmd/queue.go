@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	queueKindTBF     = "tbf"
+	queueKindPFIFO   = "pfifo"
+	queueKindBFIFO   = "bfifo"
+	queueKindFQCoDel = "fq_codel"
+	queueKindCoDel   = "codel"
+	queueKindPIE     = "pie"
+	queueKindRED     = "red"
+
+	defaultQueueBurst   = 20 * 1024
+	defaultQueueLatency = 400 * time.Millisecond
+
+	redAvpkt = 1000
+)
+
+// withDefaults returns a copy of q, in which all unset values that are
+// required to install the queue are set to their defaults.
+func (q Queue) withDefaults() Queue {
+	if q.Kind == "" {
+		q.Kind = queueKindTBF
+	}
+	if q.Burst <= 0 {
+		q.Burst = defaultQueueBurst
+	}
+	if q.Kind == queueKindTBF && q.Bytes <= 0 && q.Latency.Duration <= 0 {
+		q.Latency.Duration = defaultQueueLatency
+	}
+	return q
+}
+
+func (q Queue) validate() error {
+	switch q.Kind {
+	case queueKindTBF:
+		if q.Packets > 0 {
+			return fmt.Errorf("queue %v: buffer size can only be given in bytes or latency", q.Kind)
+		}
+	case queueKindPFIFO:
+		if q.Bytes > 0 || q.Latency.Duration > 0 {
+			return fmt.Errorf("queue %v: buffer size can only be given in packets", q.Kind)
+		}
+	case queueKindBFIFO:
+		if q.Packets > 0 || q.Latency.Duration > 0 {
+			return fmt.Errorf("queue %v: buffer size can only be given in bytes", q.Kind)
+		}
+	case queueKindFQCoDel, queueKindCoDel, queueKindPIE:
+		if q.Bytes > 0 || q.Latency.Duration > 0 {
+			return fmt.Errorf("queue %v: buffer size can only be given in packets", q.Kind)
+		}
+	case queueKindRED:
+		if q.Bytes <= 0 || q.Min <= 0 || q.Max <= 0 {
+			return fmt.Errorf("queue %v: bytes, min and max are required", q.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown queue kind: %q", q.Kind)
+	}
+	if q.ECN && (q.Kind == queueKindTBF || q.Kind == queueKindPFIFO || q.Kind == queueKindBFIFO) {
+		return fmt.Errorf("queue %v does not support ECN marking", q.Kind)
+	}
+	return nil
+}
+
+// tbfArgs returns the arguments for the tbf qdisc limiting the link to
+// bitrate. If the queue has its own child qdisc, the tbf buffer is not used.
+func (q Queue) tbfArgs(bitrate int) []string {
+	args := []string{"tbf", "rate", fmt.Sprintf("%v", bitrate), "burst", fmt.Sprintf("%v", q.Burst)}
+	switch {
+	case q.Kind != queueKindTBF:
+		args = append(args, "latency", fmt.Sprintf("%vms", defaultQueueLatency.Milliseconds()))
+	case q.Bytes > 0:
+		args = append(args, "limit", fmt.Sprintf("%v", q.Bytes))
+	default:
+		args = append(args, "latency", fmt.Sprintf("%vms", q.Latency.Milliseconds()))
+	}
+	return args
+}
+
+// childArgs returns the arguments for the child qdisc attached to the tbf
+// rate limiter or nil, if the tbf queue is used.
+func (q Queue) childArgs(bitrate int) []string {
+	var args []string
+	switch q.Kind {
+	case queueKindTBF:
+		return nil
+	case queueKindPFIFO:
+		args = []string{q.Kind}
+		if q.Packets > 0 {
+			args = append(args, "limit", fmt.Sprintf("%v", q.Packets))
+		}
+	case queueKindBFIFO:
+		args = []string{q.Kind}
+		if q.Bytes > 0 {
+			args = append(args, "limit", fmt.Sprintf("%v", q.Bytes))
+		}
+	case queueKindFQCoDel, queueKindCoDel, queueKindPIE:
+		args = []string{q.Kind}
+		if q.Packets > 0 {
+			args = append(args, "limit", fmt.Sprintf("%v", q.Packets))
+		}
+		if q.Target.Duration > 0 {
+			args = append(args, "target", fmt.Sprintf("%vus", q.Target.Microseconds()))
+		}
+		if q.Interval.Duration > 0 && q.Kind != queueKindPIE {
+			args = append(args, "interval", fmt.Sprintf("%vus", q.Interval.Microseconds()))
+		}
+		// fq_codel enables ECN by default, so always be explicit.
+		if !q.ECN {
+			args = append(args, "noecn")
+		}
+	case queueKindRED:
+		args = []string{
+			q.Kind,
+			"limit", fmt.Sprintf("%v", q.Bytes),
+			"min", fmt.Sprintf("%v", q.Min),
+			"max", fmt.Sprintf("%v", q.Max),
+			"avpkt", fmt.Sprintf("%v", redAvpkt),
+			"bandwidth", fmt.Sprintf("%v", bitrate),
+		}
+		if q.Probability > 0 {
+			args = append(args, "probability", fmt.Sprintf("%v", q.Probability))
+		}
+	}
+	if q.ECN {
+		args = append(args, "ecn")
+	}
+	return args
+}
+
+func (q Queue) String() string {
+	q = q.withDefaults()
+	var params []string
+	if q.Bytes > 0 {
+		params = append(params, fmt.Sprintf("%v bytes", q.Bytes))
+	}
+	if q.Packets > 0 {
+		params = append(params, fmt.Sprintf("%v packets", q.Packets))
+	}
+	if q.Latency.Duration > 0 {
+		params = append(params, fmt.Sprintf("latency %v", q.Latency.Duration))
+	}
+	if q.Target.Duration > 0 {
+		params = append(params, fmt.Sprintf("target %v", q.Target.Duration))
+	}
+	if q.Interval.Duration > 0 {
+		params = append(params, fmt.Sprintf("interval %v", q.Interval.Duration))
+	}
+	if q.Kind == queueKindRED {
+		params = append(params, fmt.Sprintf("min %v, max %v, probability %v", q.Min, q.Max, q.Probability))
+	}
+	if q.ECN {
+		params = append(params, "ECN")
+	}
+	if len(params) == 0 {
+		return q.Kind
+	}
+	return fmt.Sprintf("%v (%v)", q.Kind, strings.Join(params, ", "))
+}
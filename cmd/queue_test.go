@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		queue   Queue
+		wantErr bool
+	}{
+		{name: "tbf latency", queue: Queue{Kind: queueKindTBF, Latency: ms(100)}},
+		{name: "tbf bytes", queue: Queue{Kind: queueKindTBF, Bytes: 10000}},
+		{name: "tbf packets", queue: Queue{Kind: queueKindTBF, Packets: 10}, wantErr: true},
+		{name: "tbf ecn", queue: Queue{Kind: queueKindTBF, ECN: true}, wantErr: true},
+		{name: "pfifo packets", queue: Queue{Kind: queueKindPFIFO, Packets: 10}},
+		{name: "pfifo bytes", queue: Queue{Kind: queueKindPFIFO, Bytes: 10000}, wantErr: true},
+		{name: "bfifo bytes", queue: Queue{Kind: queueKindBFIFO, Bytes: 10000}},
+		{name: "bfifo latency", queue: Queue{Kind: queueKindBFIFO, Latency: ms(10)}, wantErr: true},
+		{name: "fq_codel packets", queue: Queue{Kind: queueKindFQCoDel, Packets: 100, ECN: true}},
+		{name: "fq_codel bytes", queue: Queue{Kind: queueKindFQCoDel, Bytes: 10000}, wantErr: true},
+		{name: "codel latency", queue: Queue{Kind: queueKindCoDel, Latency: ms(10)}, wantErr: true},
+		{name: "pie packets", queue: Queue{Kind: queueKindPIE, Packets: 100}},
+		{name: "red", queue: Queue{Kind: queueKindRED, Bytes: 100000, Min: 10000, Max: 30000}},
+		{name: "red without max", queue: Queue{Kind: queueKindRED, Bytes: 100000, Min: 10000}, wantErr: true},
+		{name: "unknown kind", queue: Queue{Kind: "sfq"}, wantErr: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.queue.validate()
+			if tt.wantErr && err == nil {
+				t.Errorf("expected error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueueArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		queue Queue
+		tbf   string
+		child string
+	}{
+		{
+			name:  "default",
+			queue: Queue{},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+		},
+		{
+			name:  "tbf bytes",
+			queue: Queue{Bytes: 50000},
+			tbf:   "tbf rate 1000000 burst 20480 limit 50000",
+		},
+		{
+			name:  "tbf latency",
+			queue: Queue{Burst: 3000, Latency: ms(50)},
+			tbf:   "tbf rate 1000000 burst 3000 latency 50ms",
+		},
+		{
+			name:  "pfifo",
+			queue: Queue{Kind: queueKindPFIFO, Packets: 20},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "pfifo limit 20",
+		},
+		{
+			name:  "bfifo",
+			queue: Queue{Kind: queueKindBFIFO, Bytes: 30000},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "bfifo limit 30000",
+		},
+		{
+			name:  "fq_codel",
+			queue: Queue{Kind: queueKindFQCoDel, Packets: 100, Target: ms(5), Interval: ms(100), ECN: true},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "fq_codel limit 100 target 5000us interval 100000us ecn",
+		},
+		{
+			name:  "codel without ecn",
+			queue: Queue{Kind: queueKindCoDel},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "codel noecn",
+		},
+		{
+			name:  "pie ignores interval",
+			queue: Queue{Kind: queueKindPIE, Target: ms(15), Interval: ms(100)},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "pie target 15000us noecn",
+		},
+		{
+			name:  "red",
+			queue: Queue{Kind: queueKindRED, Bytes: 100000, Min: 10000, Max: 30000, Probability: 0.1, ECN: true},
+			tbf:   "tbf rate 1000000 burst 20480 latency 400ms",
+			child: "red limit 100000 min 10000 max 30000 avpkt 1000 bandwidth 1000000 probability 0.1 ecn",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.queue.withDefaults()
+			if got, want := q.tbfArgs(1000000), strings.Fields(tt.tbf); !reflect.DeepEqual(got, want) {
+				t.Errorf("tbf: got %q, want %q", got, want)
+			}
+			got := q.childArgs(1000000)
+			var want []string
+			if tt.child != "" {
+				want = strings.Fields(tt.child)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("child: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestQdiscArgs(t *testing.T) {
+	red := Queue{Kind: queueKindRED, Bytes: 100000, Min: 10000, Max: 30000}.withDefaults()
+	pfifo := Queue{Kind: queueKindPFIFO, Packets: 20}.withDefaults()
+	config := tcConfig{Delay: ms(10), Bitrate: 1000000}
+	for _, tt := range []struct {
+		name     string
+		queue    Queue
+		config   tcConfig
+		previous *tcConfig
+		want     []string
+	}{
+		{
+			name:   "add",
+			queue:  red,
+			config: config,
+			want: []string{
+				"add dev eth0 root handle 1: netem delay 10ms",
+				"add dev eth0 parent 1: handle 2: tbf rate 1000000 burst 20480 latency 400ms",
+				"add dev eth0 parent 2:1 handle 3: red limit 100000 min 10000 max 30000 avpkt 1000 bandwidth 1000000",
+			},
+		},
+		{
+			name:     "unchanged",
+			queue:    red,
+			config:   config,
+			previous: &config,
+		},
+		{
+			name:     "delay changed",
+			queue:    red,
+			config:   tcConfig{Delay: ms(20), Bitrate: 1000000},
+			previous: &config,
+			want: []string{
+				"change dev eth0 root handle 1: netem delay 20ms",
+			},
+		},
+		{
+			name:     "rate changed",
+			queue:    red,
+			config:   tcConfig{Delay: ms(10), Bitrate: 500000},
+			previous: &config,
+			want: []string{
+				"change dev eth0 parent 1: handle 2: tbf rate 500000 burst 20480 latency 400ms",
+				"change dev eth0 parent 2:1 handle 3: red limit 100000 min 10000 max 30000 avpkt 1000 bandwidth 500000",
+			},
+		},
+		{
+			name:     "rate changed, child independent of rate",
+			queue:    pfifo,
+			config:   tcConfig{Delay: ms(10), Bitrate: 500000},
+			previous: &config,
+			want: []string{
+				"change dev eth0 parent 1: handle 2: tbf rate 500000 burst 20480 latency 400ms",
+			},
+		},
+		{
+			name:   "rate below minimum",
+			queue:  Queue{}.withDefaults(),
+			config: tcConfig{},
+			want: []string{
+				"add dev eth0 root handle 1: netem delay 0ms",
+				"add dev eth0 parent 1: handle 2: tbf rate 8000 burst 20480 latency 400ms",
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var want [][]string
+			for _, w := range tt.want {
+				want = append(want, strings.Fields(w))
+			}
+			if got := tt.config.qdiscArgs(tt.queue, tt.previous); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestQueueWithDefaults(t *testing.T) {
+	if q := (Queue{}).withDefaults(); q.Kind != queueKindTBF || q.Latency.Duration != 400*time.Millisecond {
+		t.Errorf("unexpected defaults: %+v", q)
+	}
+	if q := (Queue{Bytes: 1000}).withDefaults(); q.Latency.Duration != 0 {
+		t.Errorf("latency set although bytes given: %+v", q)
+	}
+}
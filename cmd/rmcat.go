@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// Parameters of the congestion control evaluation criteria of RFC 8867 and
+// RFC 8868.
+const (
+	// minCapacityChange is the relative change of the link capacity from
+	// which on the adaptation of the congestion controller is evaluated.
+	// Smaller changes, e.g. between the samples of a trace, are ignored.
+	minCapacityChange = 0.1
+	// convergenceThreshold is the fraction of the link capacity the target
+	// bitrate has to reach to count as converged.
+	convergenceThreshold = 0.9
+)
+
+// CapacityChange is a change of the link capacity in kbit/s at At
+// milliseconds. Adaptation is the time in milliseconds until the target
+// bitrate fell to the new capacity after a drop, or reached
+// convergenceThreshold of the new capacity after an increase. It is -1 if the
+// target bitrate did not adapt until the next change.
+type CapacityChange struct {
+	At         float64 `json:"at"`
+	From       float64 `json:"from"`
+	To         float64 `json:"to"`
+	Adaptation float64 `json:"adaptation"`
+}
+
+func (c CapacityChange) drop() bool {
+	return c.To < c.From
+}
+
+// ccEvaluation holds the congestion control evaluation criteria of a run.
+type ccEvaluation struct {
+	linkCapacity plotter.XYs
+
+	// utilization is the ratio of the received RTP rate to the link capacity
+	// per second, phaseUtilization its average per phase of the testcase.
+	utilization        plotter.XYs
+	averageUtilization float64
+	phaseUtilization   []float64
+
+	// timeAboveCapacity is the time in seconds the target bitrate exceeded
+	// the link capacity.
+	timeAboveCapacity float64
+
+	// reactionTime and reconvergenceTime are the average adaptation times in
+	// milliseconds after capacity drops and increases.
+	capacityChanges   []CapacityChange
+	reactionTime      float64
+	reconvergenceTime float64
+
+	// oscillation is the average standard deviation of the target bitrate in
+	// kbit/s while the capacity was constant and the target bitrate had
+	// converged.
+	oscillation float64
+
+	// queueingDelay is the packet latency exceeding the configured delay of
+	// the link in milliseconds.
+	queueingDelay        plotter.XYs
+	averageQueueingDelay float64
+}
+
+// evaluateCC compares the target bitrate in kbit/s, the received RTP bytes
+// per second and the packet latency to the link configured in c.
+func evaluateCC(c Config, targetBitrate, receivedRTP, packetLatency plotter.XYs) ccEvaluation {
+	var e ccEvaluation
+
+	maxX := 0.0
+	if len(targetBitrate) > 0 {
+		maxX = math.Max(maxX, targetBitrate[len(targetBitrate)-1].X)
+	}
+	if len(receivedRTP) > 0 {
+		maxX = math.Max(maxX, (receivedRTP[len(receivedRTP)-1].X+1)*1000)
+	}
+	if len(packetLatency) > 0 {
+		maxX = math.Max(maxX, packetLatency[len(packetLatency)-1].X)
+	}
+	steps := linkSchedule(c)
+	if len(steps) == 0 {
+		return e
+	}
+	capacity := getCapacityFromConfig(c, maxX)
+	e.linkCapacity = rect(capacity)
+
+	for _, r := range receivedRTP {
+		if capacity := valueAt(capacity, r.X*1000+500); capacity > 0 {
+			e.utilization = append(e.utilization, plotter.XY{X: r.X, Y: r.Y * 8 / 1000 / capacity})
+		}
+	}
+	e.averageUtilization = averageMapValues(e.utilization)
+	e.phaseUtilization = phaseAverages(c.TestCase.Phases, e.utilization)
+
+	for i := 0; i+1 < len(targetBitrate); i++ {
+		if targetBitrate[i].Y > valueAt(capacity, targetBitrate[i].X) {
+			e.timeAboveCapacity += (targetBitrate[i+1].X - targetBitrate[i].X) / 1000
+		}
+	}
+
+	e.capacityChanges, e.oscillation = capacityAdaptation(capacity, targetBitrate)
+	var reactions, reconvergences []float64
+	for _, change := range e.capacityChanges {
+		if change.Adaptation < 0 {
+			continue
+		}
+		if change.drop() {
+			reactions = append(reactions, change.Adaptation)
+		} else {
+			reconvergences = append(reconvergences, change.Adaptation)
+		}
+	}
+	e.reactionTime = newStatistic(reactions).Mean
+	e.reconvergenceTime = newStatistic(reconvergences).Mean
+
+	for _, p := range packetLatency {
+		i := sort.Search(len(steps), func(i int) bool {
+			return float64(steps[i].at.Milliseconds()) > p.X
+		})
+		if i > 0 {
+			i--
+		}
+		delay := math.Max(0, p.Y-float64(steps[i].link.forward.Delay.Milliseconds()))
+		e.queueingDelay = append(e.queueingDelay, plotter.XY{X: p.X, Y: delay})
+	}
+	e.averageQueueingDelay = averageMapValues(e.queueingDelay)
+	return e
+}
+
+// valueAt returns the value of the step function xys at x.
+func valueAt(xys plotter.XYs, x float64) float64 {
+	i := sort.Search(len(xys), func(i int) bool {
+		return xys[i].X > x
+	})
+	if i == 0 {
+		return 0
+	}
+	return xys[i-1].Y
+}
+
+// phaseAverages averages the values of a series binned to seconds over every
+// phase.
+func phaseAverages(phases []tcPhase, perSecond plotter.XYs) []float64 {
+	var result []float64
+	start := 0.0
+	for _, p := range phases {
+		end := math.Inf(1)
+		if p.Duration.Duration > 0 {
+			end = start + p.Duration.Seconds()
+		}
+		var inPhase plotter.XYs
+		for _, v := range perSecond {
+			if v.X+0.5 >= start && v.X+0.5 < end {
+				inPhase = append(inPhase, v)
+			}
+		}
+		result = append(result, averageMapValues(inPhase))
+		if math.IsInf(end, 1) {
+			break
+		}
+		start = end
+	}
+	return result
+}
+
+// capacityAdaptation finds the capacity changes and the time the target
+// bitrate needed to adapt to them. It returns the average oscillation of the
+// target bitrate between adaptation and the next change of the capacity. The
+// start of the run counts as an increase from zero, but it is not returned as
+// a change.
+func capacityAdaptation(capacity, targetBitrate plotter.XYs) ([]CapacityChange, float64) {
+	var changes []CapacityChange
+	var oscillations []float64
+	for i := 0; i+1 < len(capacity); i++ {
+		start, end := capacity[i].X, capacity[i+1].X
+		change := CapacityChange{At: start, To: capacity[i].Y, Adaptation: -1}
+		if i > 0 {
+			change.From = capacity[i-1].Y
+		}
+		significant := change.From == 0 || math.Abs(change.To-change.From)/change.From >= minCapacityChange
+
+		steady := start
+		if significant {
+			for _, t := range targetBitrate {
+				if t.X < start || t.X >= end {
+					continue
+				}
+				if (change.drop() && t.Y <= change.To) || (!change.drop() && t.Y >= convergenceThreshold*change.To) {
+					change.Adaptation = t.X - start
+					break
+				}
+			}
+			if i > 0 {
+				changes = append(changes, change)
+			}
+			if change.Adaptation < 0 {
+				continue
+			}
+			steady += change.Adaptation
+		}
+
+		var values []float64
+		for _, t := range targetBitrate {
+			if t.X >= steady && t.X < end {
+				values = append(values, t.Y)
+			}
+		}
+		if len(values) > 1 {
+			oscillations = append(oscillations, newStatistic(values).StdDev)
+		}
+	}
+	return changes, newStatistic(oscillations).Mean
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func TestValueAt(t *testing.T) {
+	steps := plotter.XYs{{X: 0, Y: 1000}, {X: 5000, Y: 500}, {X: 10000, Y: 500}}
+	for _, tt := range []struct {
+		name string
+		xys  plotter.XYs
+		x    float64
+		want float64
+	}{
+		{name: "empty", x: 10, want: 0},
+		{name: "before first step", xys: plotter.XYs{{X: 100, Y: 1}}, x: 10, want: 0},
+		{name: "at first step", xys: steps, x: 0, want: 1000},
+		{name: "within step", xys: steps, x: 4999, want: 1000},
+		{name: "at change", xys: steps, x: 5000, want: 500},
+		{name: "after last step", xys: steps, x: 20000, want: 500},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := valueAt(tt.xys, tt.x); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhaseAverages(t *testing.T) {
+	perSecond := plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 3}, {X: 2, Y: 5}, {X: 3, Y: 7}}
+	phase := func(d time.Duration) tcPhase {
+		return tcPhase{Duration: Duration{Duration: d}}
+	}
+	for _, tt := range []struct {
+		name   string
+		phases []tcPhase
+		want   []float64
+	}{
+		{name: "no phases"},
+		{name: "single phase until the end", phases: []tcPhase{phase(0)}, want: []float64{4}},
+		{name: "two phases", phases: []tcPhase{phase(2 * time.Second), phase(0)}, want: []float64{2, 6}},
+		{name: "phases after the end", phases: []tcPhase{phase(4 * time.Second), phase(time.Second)}, want: []float64{4, 0}},
+		{name: "unlimited phase ends the phases", phases: []tcPhase{phase(0), phase(time.Second)}, want: []float64{4}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := phaseAverages(tt.phases, perSecond); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapacityAdaptation(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		capacity        plotter.XYs
+		targetBitrate   plotter.XYs
+		wantChanges     []CapacityChange
+		wantOscillation float64
+	}{
+		{
+			name: "no capacity",
+		},
+		{
+			name:          "drop",
+			capacity:      plotter.XYs{{X: 0, Y: 1000}, {X: 10000, Y: 500}, {X: 20000, Y: 500}},
+			targetBitrate: plotter.XYs{{X: 0, Y: 500}, {X: 2000, Y: 950}, {X: 5000, Y: 1050}, {X: 10000, Y: 900}, {X: 11000, Y: 500}, {X: 15000, Y: 520}},
+			wantChanges: []CapacityChange{
+				{At: 10000, From: 1000, To: 500, Adaptation: 1000},
+			},
+			wantOscillation: (50*math.Sqrt2 + 10*math.Sqrt2) / 2,
+		},
+		{
+			name:          "increase without adaptation",
+			capacity:      plotter.XYs{{X: 0, Y: 1000}, {X: 5000, Y: 2000}, {X: 10000, Y: 2000}},
+			targetBitrate: plotter.XYs{{X: 0, Y: 950}, {X: 1000, Y: 950}, {X: 6000, Y: 1000}, {X: 7000, Y: 1000}},
+			wantChanges: []CapacityChange{
+				{At: 5000, From: 1000, To: 2000, Adaptation: -1},
+			},
+		},
+		{
+			name:            "insignificant change",
+			capacity:        plotter.XYs{{X: 0, Y: 1000}, {X: 5000, Y: 1050}, {X: 10000, Y: 1050}},
+			targetBitrate:   plotter.XYs{{X: 0, Y: 1000}, {X: 1000, Y: 1000}, {X: 6000, Y: 1000}, {X: 7000, Y: 1100}},
+			wantOscillation: 50 * math.Sqrt2 / 2,
+		},
+		{
+			name:        "no target bitrate",
+			capacity:    plotter.XYs{{X: 0, Y: 1000}, {X: 5000, Y: 500}, {X: 10000, Y: 500}},
+			wantChanges: []CapacityChange{{At: 5000, From: 1000, To: 500, Adaptation: -1}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, oscillation := capacityAdaptation(tt.capacity, tt.targetBitrate)
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes: got %+v, want %+v", changes, tt.wantChanges)
+			}
+			if math.Abs(oscillation-tt.wantOscillation) > 1e-9 {
+				t.Errorf("oscillation: got %v, want %v", oscillation, tt.wantOscillation)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/bits"
+	"os"
+	"strconv"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// Columns of the tab separated RTCP logs. Like in RTP logs, the time is given
+// in milliseconds since the start of the log. The packet column holds the hex
+// encoded compound packet and is missing in logs of older implementations.
+const (
+	rtcpLogTimeColumn   = 2
+	rtcpLogSizeColumn   = 3
+	rtcpLogPacketColumn = 4
+)
+
+// RTCP packet types and feedback message types.
+const (
+	rtcpTypeSenderReport   = 200
+	rtcpTypeReceiverReport = 201
+	rtcpTypeTransportFB    = 205
+
+	rtcpFormatNACK = 1
+	rtcpFormatCCFB = 11
+	rtcpFormatTWCC = 15
+)
+
+// Kinds of decoded RTCP feedback.
+const (
+	rtcpFeedbackRR   = "RR"
+	rtcpFeedbackNACK = "NACK"
+	rtcpFeedbackTWCC = "TWCC"
+	rtcpFeedbackCCFB = "CCFB"
+)
+
+// rtcpFeedbackKinds orders the feedback kinds for plotting.
+var rtcpFeedbackKinds = []string{
+	rtcpFeedbackRR,
+	rtcpFeedbackNACK,
+	rtcpFeedbackTWCC,
+	rtcpFeedbackCCFB,
+}
+
+// rtcpRecord is a single compound packet of an RTCP log.
+type rtcpRecord struct {
+	time float64
+	size float64
+	// decoded is set if the log contains the packet and it could be
+	// decoded, feedback is empty otherwise.
+	decoded  bool
+	feedback []rtcpFeedback
+}
+
+// rtcpFeedback is a single decoded packet of a compound packet. Sender and
+// receiver reports are both of kind RR.
+type rtcpFeedback struct {
+	kind string
+	// fractionsLost are the loss fractions of the report blocks of a report.
+	fractionsLost []float64
+	// nacked is the number of packets requested by a NACK.
+	nacked int
+}
+
+// readRTCPLog reads the records of an RTCP log. Like RTP logs, RTCP logs are
+// cut if a container is killed, so reading stops at the first row without a
+// valid time and size. Records of packets which cannot be decoded are kept
+// without feedback.
+func readRTCPLog(filename string) ([]rtcpRecord, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.Comma = '\t'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	var records []rtcpRecord
+	undecodable := 0
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			var record rtcpRecord
+			if record, err = parseRTCPRecord(row); err == nil {
+				if err = record.decode(row); err != nil {
+					if undecodable == 0 {
+						log.Printf("WARNING: failed to decode packet in RTCP log '%v' at %vms: %v\n", filename, record.time, err)
+					}
+					undecodable++
+				}
+				records = append(records, record)
+				continue
+			}
+		}
+		log.Printf("WARNING: failed to read record from RTCP log '%v', assuming file was cut: %v\n", filename, err)
+		break
+	}
+	if undecodable > 0 {
+		log.Printf("WARNING: %v of %v packets in RTCP log '%v' could not be decoded\n", undecodable, len(records), filename)
+	}
+	return records, nil
+}
+
+func parseRTCPRecord(row []string) (rtcpRecord, error) {
+	if len(row) <= rtcpLogSizeColumn {
+		return rtcpRecord{}, fmt.Errorf("invalid number of columns: %v", len(row))
+	}
+	var r rtcpRecord
+	var err error
+	if r.time, err = strconv.ParseFloat(row[rtcpLogTimeColumn], 64); err != nil {
+		return rtcpRecord{}, err
+	}
+	if r.size, err = strconv.ParseFloat(row[rtcpLogSizeColumn], 64); err != nil {
+		return rtcpRecord{}, err
+	}
+	return r, nil
+}
+
+// decode decodes the feedback of the packet column of row, if the log
+// contains it.
+func (r *rtcpRecord) decode(row []string) error {
+	if len(row) <= rtcpLogPacketColumn {
+		return nil
+	}
+	buf, err := hex.DecodeString(row[rtcpLogPacketColumn])
+	if err != nil {
+		return err
+	}
+	feedback, err := decodeRTCP(buf)
+	if err != nil {
+		return err
+	}
+	r.feedback = feedback
+	r.decoded = true
+	return nil
+}
+
+// rtcpPacketsDecoded reports whether any packet of records was decoded.
+func rtcpPacketsDecoded(records []rtcpRecord) bool {
+	for _, r := range records {
+		if r.decoded {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeRTCP decodes the feedback of the compound packet buf. Packets of
+// other types are skipped.
+func decodeRTCP(buf []byte) ([]rtcpFeedback, error) {
+	var result []rtcpFeedback
+	for len(buf) > 0 {
+		if len(buf) < 4 {
+			return nil, fmt.Errorf("rtcp packet too short: %v bytes", len(buf))
+		}
+		count := int(buf[0] & 0x1f)
+		packetType := buf[1]
+		length := 4 * (int(binary.BigEndian.Uint16(buf[2:4])) + 1)
+		if length > len(buf) {
+			return nil, fmt.Errorf("rtcp packet length %v exceeds buffer of %v bytes", length, len(buf))
+		}
+		packet := buf[4:length]
+		buf = buf[length:]
+
+		switch packetType {
+		case rtcpTypeSenderReport:
+			// Skip the sender SSRC and the sender info.
+			result = append(result, decodeReportBlocks(packet, 24, count))
+		case rtcpTypeReceiverReport:
+			// Skip the sender SSRC.
+			result = append(result, decodeReportBlocks(packet, 4, count))
+		case rtcpTypeTransportFB:
+			switch count {
+			case rtcpFormatNACK:
+				f := rtcpFeedback{kind: rtcpFeedbackNACK}
+				// Skip sender and media SSRC, every FCI holds a packet ID
+				// and a bitmask of following lost packets.
+				for i := 8; i+4 <= len(packet); i += 4 {
+					f.nacked += 1 + bits.OnesCount16(binary.BigEndian.Uint16(packet[i+2:i+4]))
+				}
+				result = append(result, f)
+			case rtcpFormatTWCC:
+				result = append(result, rtcpFeedback{kind: rtcpFeedbackTWCC})
+			case rtcpFormatCCFB:
+				result = append(result, rtcpFeedback{kind: rtcpFeedbackCCFB})
+			}
+		}
+	}
+	return result, nil
+}
+
+func decodeReportBlocks(packet []byte, offset, count int) rtcpFeedback {
+	f := rtcpFeedback{kind: rtcpFeedbackRR}
+	for i := 0; i < count; i++ {
+		block := offset + 24*i
+		if block+24 > len(packet) {
+			break
+		}
+		f.fractionsLost = append(f.fractionsLost, float64(packet[block+4])/256)
+	}
+	return f
+}
+
+// rtcpBytes returns the size of every compound packet over the time it was
+// logged.
+func rtcpBytes(records []rtcpRecord) plotter.XYs {
+	result := make(plotter.XYs, len(records))
+	for i, r := range records {
+		result[i] = plotter.XY{X: r.time, Y: r.size}
+	}
+	return result
+}
+
+// rtcpFeedbackStats summarizes the feedback received by the sender.
+type rtcpFeedbackStats struct {
+	// timeline maps the feedback kinds to the times the feedback was
+	// received and its size in bytes.
+	timeline map[string]plotter.XYs
+	// interval is the difference of the log times of two consecutive
+	// compound packets, i.e. milliseconds.
+	interval        plotter.XYs
+	averageInterval float64
+	// fractionLost is the loss fraction of every report block.
+	fractionLost  plotter.XYs
+	nacks         int
+	nackedPackets int
+}
+
+func getRTCPFeedbackStats(records []rtcpRecord) rtcpFeedbackStats {
+	s := rtcpFeedbackStats{
+		timeline: map[string]plotter.XYs{},
+	}
+	for i, r := range records {
+		if i > 0 {
+			s.interval = append(s.interval, plotter.XY{X: r.time, Y: r.time - records[i-1].time})
+		}
+		for _, f := range r.feedback {
+			s.timeline[f.kind] = append(s.timeline[f.kind], plotter.XY{X: r.time, Y: r.size})
+			for _, fraction := range f.fractionsLost {
+				s.fractionLost = append(s.fractionLost, plotter.XY{X: r.time, Y: fraction})
+			}
+			if f.kind == rtcpFeedbackNACK {
+				s.nacks++
+				s.nackedPackets += f.nacked
+			}
+		}
+	}
+	s.averageInterval = averageMapValues(s.interval)
+	return s
+}
+
+// feedbackOverhead returns the ratio of feedback bytes to media bytes per
+// second and in total. Both series must be binned to seconds.
+func feedbackOverhead(feedback, media plotter.XYs) (plotter.XYs, float64) {
+	var result plotter.XYs
+	var feedbackBytes, mediaBytes float64
+	for i := range media {
+		if i >= len(feedback) {
+			break
+		}
+		feedbackBytes += feedback[i].Y
+		mediaBytes += media[i].Y
+		if media[i].Y > 0 {
+			result = append(result, plotter.XY{X: media[i].X, Y: feedback[i].Y / media[i].Y})
+		}
+	}
+	if mediaBytes == 0 {
+		return result, 0
+	}
+	return result, feedbackBytes / mediaBytes
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// rtcpPacket returns an RTCP packet with the count or format field count,
+// the packet type and the payload following the common header. The payload
+// must be padded to 32 bit words.
+func rtcpPacket(count, packetType byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	header := make([]byte, 4)
+	header[0] = 0x80 | count
+	header[1] = packetType
+	binary.BigEndian.PutUint16(header[2:], uint16((4+len(body))/4-1))
+	return append(header, body...)
+}
+
+// reportBlock returns a report block with the given fraction lost.
+func reportBlock(fractionLost byte) []byte {
+	b := make([]byte, 24)
+	b[4] = fractionLost
+	return b
+}
+
+// nackFCI returns a generic NACK FCI entry with the packet ID and the bitmask
+// of following lost packets.
+func nackFCI(pid, blp uint16) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint16(b, pid)
+	binary.BigEndian.PutUint16(b[2:], blp)
+	return b
+}
+
+var (
+	ssrcs      = make([]byte, 8)
+	senderInfo = make([]byte, 24)
+)
+
+func TestDecodeRTCP(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		buf     []byte
+		want    []rtcpFeedback
+		wantErr bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "receiver report",
+			buf:  rtcpPacket(2, rtcpTypeReceiverReport, ssrcs[:4], reportBlock(64), reportBlock(0)),
+			want: []rtcpFeedback{{kind: rtcpFeedbackRR, fractionsLost: []float64{0.25, 0}}},
+		},
+		{
+			name: "sender report without blocks",
+			buf:  rtcpPacket(0, rtcpTypeSenderReport, senderInfo),
+			want: []rtcpFeedback{{kind: rtcpFeedbackRR}},
+		},
+		{
+			name: "sender report with block",
+			buf:  rtcpPacket(1, rtcpTypeSenderReport, senderInfo, reportBlock(128)),
+			want: []rtcpFeedback{{kind: rtcpFeedbackRR, fractionsLost: []float64{0.5}}},
+		},
+		{
+			name: "report count exceeds packet",
+			buf:  rtcpPacket(3, rtcpTypeReceiverReport, ssrcs[:4], reportBlock(64)),
+			want: []rtcpFeedback{{kind: rtcpFeedbackRR, fractionsLost: []float64{0.25}}},
+		},
+		{
+			name: "nack",
+			buf:  rtcpPacket(rtcpFormatNACK, rtcpTypeTransportFB, ssrcs, nackFCI(10, 0), nackFCI(20, 0x8001)),
+			want: []rtcpFeedback{{kind: rtcpFeedbackNACK, nacked: 4}},
+		},
+		{
+			name: "compound packet",
+			buf: bytes.Join([][]byte{
+				rtcpPacket(1, rtcpTypeReceiverReport, ssrcs[:4], reportBlock(0)),
+				rtcpPacket(1, 202, ssrcs[:4], make([]byte, 4)),
+				rtcpPacket(rtcpFormatTWCC, rtcpTypeTransportFB, ssrcs, make([]byte, 8)),
+				rtcpPacket(rtcpFormatCCFB, rtcpTypeTransportFB, ssrcs, make([]byte, 8)),
+			}, nil),
+			want: []rtcpFeedback{
+				{kind: rtcpFeedbackRR, fractionsLost: []float64{0}},
+				{kind: rtcpFeedbackTWCC},
+				{kind: rtcpFeedbackCCFB},
+			},
+		},
+		{
+			name: "unknown feedback format",
+			buf:  rtcpPacket(31, rtcpTypeTransportFB, ssrcs),
+		},
+		{
+			name:    "truncated header",
+			buf:     rtcpPacket(0, rtcpTypeSenderReport, senderInfo)[:3],
+			wantErr: true,
+		},
+		{
+			name:    "truncated packet",
+			buf:     rtcpPacket(1, rtcpTypeReceiverReport, ssrcs[:4], reportBlock(0))[:20],
+			wantErr: true,
+		},
+		{
+			name: "truncated second packet",
+			buf: append(
+				rtcpPacket(rtcpFormatTWCC, rtcpTypeTransportFB, ssrcs),
+				rtcpPacket(rtcpFormatCCFB, rtcpTypeTransportFB, ssrcs)[:6]...,
+			),
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeRTCP(tt.buf)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRTCPLog(t *testing.T) {
+	nack := hex.EncodeToString(rtcpPacket(rtcpFormatNACK, rtcpTypeTransportFB, ssrcs, nackFCI(1, 1)))
+	twcc := hex.EncodeToString(rtcpPacket(rtcpFormatTWCC, rtcpTypeTransportFB, ssrcs))
+	withPackets := []rtcpRecord{
+		{time: 0, size: 16, decoded: true, feedback: []rtcpFeedback{{kind: rtcpFeedbackNACK, nacked: 2}}},
+		{time: 5, size: 12, decoded: true, feedback: []rtcpFeedback{{kind: rtcpFeedbackTWCC}}},
+	}
+	withoutPackets := []rtcpRecord{
+		{time: 0, size: 16},
+		{time: 5, size: 12},
+	}
+	for _, tt := range []struct {
+		name        string
+		content     string
+		want        []rtcpRecord
+		wantDecoded bool
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:        "with packets",
+			content:     "a\tb\t0\t16\t" + nack + "\na\tb\t5\t12\t" + twcc + "\n",
+			want:        withPackets,
+			wantDecoded: true,
+		},
+		{
+			name:    "without packet column",
+			content: "a\tb\t0\t16\na\tb\t5\t12\n",
+			want:    withoutPackets,
+		},
+		{
+			name:    "truncated row",
+			content: "a\tb\t0\t16\na\tb\t5\t12\na\tb\t1",
+			want:    withoutPackets,
+		},
+		{
+			name:        "truncated packet is kept",
+			content:     "a\tb\t0\t16\t" + nack + "\na\tb\t5\t12\t" + twcc + "\na\tb\t7\t16\t" + nack[:10],
+			want:        append(withPackets[:2:2], rtcpRecord{time: 7, size: 16}),
+			wantDecoded: true,
+		},
+		{
+			name:    "invalid hex is kept",
+			content: "a\tb\t0\t16\t" + nack + "\na\tb\t5\t12\txyz\na\tb\t7\t12\t" + twcc + "\n",
+			want: []rtcpRecord{
+				withPackets[0],
+				{time: 5, size: 12},
+				{time: 7, size: 12, decoded: true, feedback: []rtcpFeedback{{kind: rtcpFeedbackTWCC}}},
+			},
+			wantDecoded: true,
+		},
+		{
+			name:    "no decodable packets",
+			content: "a\tb\t0\t16\txyz\na\tb\t5\t12\t" + nack[:10] + "\n",
+			want:    withoutPackets,
+		},
+		{
+			name:    "malformed size",
+			content: "a\tb\t0\t16\na\tb\t5\tbig\n",
+			want:    withoutPackets[:1],
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRTCPLog(writeTestFile(t, "rtcp.log", tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if decoded := rtcpPacketsDecoded(got); decoded != tt.wantDecoded {
+				t.Errorf("packets decoded: got %v, want %v", decoded, tt.wantDecoded)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := readRTCPLog(filepath.Join(t.TempDir(), "missing.log")); !os.IsNotExist(err) {
+			t.Errorf("expected not exist error, got %v", err)
+		}
+	})
+}
+
+func TestGetRTCPFeedbackStats(t *testing.T) {
+	records := []rtcpRecord{
+		{time: 0, size: 32, feedback: []rtcpFeedback{{kind: rtcpFeedbackRR, fractionsLost: []float64{0.5}}}},
+		{time: 10, size: 16, feedback: []rtcpFeedback{{kind: rtcpFeedbackNACK, nacked: 3}}},
+		{time: 30, size: 48, feedback: []rtcpFeedback{
+			{kind: rtcpFeedbackTWCC},
+			{kind: rtcpFeedbackNACK, nacked: 1},
+		}},
+	}
+	s := getRTCPFeedbackStats(records)
+	if want := (plotter.XYs{{X: 10, Y: 10}, {X: 30, Y: 20}}); !reflect.DeepEqual(s.interval, want) {
+		t.Errorf("interval: got %v, want %v", s.interval, want)
+	}
+	if s.averageInterval != 15 {
+		t.Errorf("average interval: got %v, want 15", s.averageInterval)
+	}
+	if s.nacks != 2 || s.nackedPackets != 4 {
+		t.Errorf("nacks: got %v NACKs of %v packets, want 2 NACKs of 4 packets", s.nacks, s.nackedPackets)
+	}
+	if want := (plotter.XYs{{X: 0, Y: 0.5}}); !reflect.DeepEqual(s.fractionLost, want) {
+		t.Errorf("fraction lost: got %v, want %v", s.fractionLost, want)
+	}
+	wantTimeline := map[string]plotter.XYs{
+		rtcpFeedbackRR:   {{X: 0, Y: 32}},
+		rtcpFeedbackNACK: {{X: 10, Y: 16}, {X: 30, Y: 48}},
+		rtcpFeedbackTWCC: {{X: 30, Y: 48}},
+	}
+	if !reflect.DeepEqual(s.timeline, wantTimeline) {
+		t.Errorf("timeline: got %v, want %v", s.timeline, wantTimeline)
+	}
+}
+
+func TestFeedbackOverhead(t *testing.T) {
+	for _, tt := range []struct {
+		name            string
+		feedback, media plotter.XYs
+		want            plotter.XYs
+		wantTotal       float64
+	}{
+		{
+			name: "no media",
+		},
+		{
+			name:      "per second",
+			feedback:  plotter.XYs{{X: 0, Y: 10}, {X: 1, Y: 30}},
+			media:     plotter.XYs{{X: 0, Y: 100}, {X: 1, Y: 100}},
+			want:      plotter.XYs{{X: 0, Y: 0.1}, {X: 1, Y: 0.3}},
+			wantTotal: 0.2,
+		},
+		{
+			name:      "seconds without media",
+			feedback:  plotter.XYs{{X: 0, Y: 10}, {X: 1, Y: 10}},
+			media:     plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 100}},
+			want:      plotter.XYs{{X: 1, Y: 0.1}},
+			wantTotal: 0.2,
+		},
+		{
+			name:      "shorter feedback",
+			feedback:  plotter.XYs{{X: 0, Y: 10}},
+			media:     plotter.XYs{{X: 0, Y: 100}, {X: 1, Y: 100}},
+			want:      plotter.XYs{{X: 0, Y: 0.1}},
+			wantTotal: 0.1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total := feedbackOverhead(tt.feedback, tt.media)
+			if !reflect.DeepEqual(got, tt.want) || total != tt.wantTotal {
+				t.Errorf("got %v, %v, want %v, %v", got, total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+}
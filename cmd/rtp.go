@@ -0,0 +1,383 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// Columns of the tab separated RTP logs written by sender and receiver. The
+// time is given in milliseconds since the start of the log, so the logs of
+// sender and receiver use different epochs.
+const (
+	rtpLogPayloadTypeColumn    = 1
+	rtpLogTimeColumn           = 2
+	rtpLogSSRCColumn           = 3
+	rtpLogSequenceNumberColumn = 4
+	rtpLogTimestampColumn      = 5
+	rtpLogMarkerColumn         = 6
+	rtpLogSizeColumn           = 8
+)
+
+// rtpRecord is a single packet of an RTP log.
+type rtpRecord struct {
+	time           float64
+	payloadType    uint8
+	ssrc           uint32
+	sequenceNumber uint16
+	timestamp      uint32
+	marker         bool
+	size           float64
+}
+
+// rtpPacketKey identifies a packet in the RTP logs of sender and receiver.
+// The RTP timestamp disambiguates wrapped sequence numbers.
+type rtpPacketKey struct {
+	ssrc           uint32
+	sequenceNumber uint16
+	timestamp      uint32
+}
+
+func (r rtpRecord) key() rtpPacketKey {
+	return rtpPacketKey{
+		ssrc:           r.ssrc,
+		sequenceNumber: r.sequenceNumber,
+		timestamp:      r.timestamp,
+	}
+}
+
+// rtpFrameKey identifies a video frame, i.e. all packets of a stream sharing
+// an RTP timestamp.
+type rtpFrameKey struct {
+	ssrc      uint32
+	timestamp uint32
+}
+
+// readRTPLog reads the records of an RTP log. Logs are cut if a container is
+// killed at the timeout, so reading stops at the first invalid row.
+func readRTPLog(filename string) ([]rtpRecord, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.Comma = '\t'
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	var records []rtpRecord
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err == nil {
+			var record rtpRecord
+			if record, err = parseRTPRecord(row); err == nil {
+				records = append(records, record)
+				continue
+			}
+		}
+		log.Printf("WARNING: failed to read record from RTP log '%v', assuming file was cut: %v\n", filename, err)
+		return records, nil
+	}
+}
+
+func parseRTPRecord(row []string) (rtpRecord, error) {
+	if len(row) <= rtpLogSizeColumn {
+		return rtpRecord{}, fmt.Errorf("invalid number of columns: %v", len(row))
+	}
+	var r rtpRecord
+	var err error
+	if r.time, err = strconv.ParseFloat(row[rtpLogTimeColumn], 64); err != nil {
+		return rtpRecord{}, err
+	}
+	pt, err := strconv.ParseUint(row[rtpLogPayloadTypeColumn], 10, 8)
+	if err != nil {
+		return rtpRecord{}, err
+	}
+	r.payloadType = uint8(pt)
+	ssrc, err := strconv.ParseUint(row[rtpLogSSRCColumn], 10, 32)
+	if err != nil {
+		return rtpRecord{}, err
+	}
+	r.ssrc = uint32(ssrc)
+	seq, err := strconv.ParseUint(row[rtpLogSequenceNumberColumn], 10, 16)
+	if err != nil {
+		return rtpRecord{}, err
+	}
+	r.sequenceNumber = uint16(seq)
+	ts, err := strconv.ParseUint(row[rtpLogTimestampColumn], 10, 32)
+	if err != nil {
+		return rtpRecord{}, err
+	}
+	r.timestamp = uint32(ts)
+	if r.marker, err = strconv.ParseBool(row[rtpLogMarkerColumn]); err != nil {
+		return rtpRecord{}, err
+	}
+	if r.size, err = strconv.ParseFloat(row[rtpLogSizeColumn], 64); err != nil {
+		return rtpRecord{}, err
+	}
+	return r, nil
+}
+
+// Percentiles summarizes the distribution of a metric.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+func newPercentiles(xys plotter.XYs) Percentiles {
+	if len(xys) == 0 {
+		return Percentiles{}
+	}
+	values := make([]float64, len(xys))
+	for i, xy := range xys {
+		values[i] = xy.Y
+	}
+	sort.Float64s(values)
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(values)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return values[i]
+	}
+	return Percentiles{
+		P50: rank(0.50),
+		P95: rank(0.95),
+		P99: rank(0.99),
+	}
+}
+
+// getLatency correlates the packets sent and received and returns the one-way
+// delay in milliseconds of every received packet and of every completely
+// received frame, over the sending time. A frame is delayed from sending its
+// first packet until receiving its last packet.
+//
+// As the logs use different epochs, the clock offset is estimated by assuming
+// that the fastest packet experienced no queueing, i.e. its delay equals
+// baseDelay, the minimum delay configured on the link.
+func getLatency(sent, received []rtpRecord, baseDelay float64) (packets, frames plotter.XYs) {
+	sentAt := map[rtpPacketKey]float64{}
+	frameSize := map[rtpFrameKey]int{}
+	for _, r := range sent {
+		if _, ok := sentAt[r.key()]; ok {
+			continue
+		}
+		sentAt[r.key()] = r.time
+		frameSize[rtpFrameKey{r.ssrc, r.timestamp}]++
+	}
+
+	type frame struct {
+		firstSent    float64
+		lastReceived float64
+		packets      int
+	}
+	framesByKey := map[rtpFrameKey]*frame{}
+	offset := math.Inf(1)
+	seen := map[rtpPacketKey]bool{}
+	for _, r := range received {
+		s, ok := sentAt[r.key()]
+		if !ok || seen[r.key()] {
+			continue
+		}
+		seen[r.key()] = true
+		packets = append(packets, plotter.XY{X: s, Y: r.time - s})
+		offset = math.Min(offset, r.time-s-baseDelay)
+
+		k := rtpFrameKey{r.ssrc, r.timestamp}
+		f, ok := framesByKey[k]
+		if !ok {
+			f = &frame{firstSent: s, lastReceived: r.time}
+			framesByKey[k] = f
+		}
+		f.firstSent = math.Min(f.firstSent, s)
+		f.lastReceived = math.Max(f.lastReceived, r.time)
+		f.packets++
+	}
+	if len(packets) == 0 {
+		return nil, nil
+	}
+
+	for k, f := range framesByKey {
+		if f.packets < frameSize[k] {
+			continue
+		}
+		frames = append(frames, plotter.XY{X: f.firstSent, Y: f.lastReceived - f.firstSent - offset})
+	}
+
+	for i := range packets {
+		packets[i].Y -= offset
+	}
+	sort.Slice(packets, func(i, j int) bool {
+		return packets[i].X < packets[j].X
+	})
+	sort.Slice(frames, func(i, j int) bool {
+		return frames[i].X < frames[j].X
+	})
+	return packets, frames
+}
+
+// minForwardDelay returns the minimum delay in milliseconds configured for the
+// forward direction of the link in any phase of t.
+func minForwardDelay(t TestCase) float64 {
+	min := math.Inf(1)
+	for _, p := range t.Phases {
+		min = math.Min(min, float64(p.link().forward.Delay.Milliseconds()))
+	}
+	if math.IsInf(min, 1) {
+		return 0
+	}
+	return min
+}
+
+// rtpVideoClockRate is the RTP clock rate of video payloads.
+const rtpVideoClockRate = 90000
+
+// rtpBytes returns the size of every packet over the time it was logged.
+func rtpBytes(records []rtpRecord) plotter.XYs {
+	result := make(plotter.XYs, len(records))
+	for i, r := range records {
+		result[i] = plotter.XY{X: r.time, Y: r.size}
+	}
+	return result
+}
+
+// sequenceUnwrapper extends 16 bit RTP sequence numbers to detect wrap
+// arounds.
+type sequenceUnwrapper struct {
+	started bool
+	last    int64
+}
+
+func (u *sequenceUnwrapper) unwrap(seq uint16) int64 {
+	if !u.started {
+		u.started = true
+		u.last = int64(seq)
+		return u.last
+	}
+	u.last += int64(int16(seq - uint16(u.last)))
+	return u.last
+}
+
+// rtpStreamStats are the loss, reordering, duplication and jitter statistics
+// of the received RTP packets.
+type rtpStreamStats struct {
+	// lossRate is the fraction of packets sent per second which were not
+	// received.
+	lossRate   plotter.XYs
+	packetLoss float64
+
+	// duplicates is the number of duplicate packets received per second.
+	duplicates       plotter.XYs
+	duplicatePackets int
+
+	// reorderExtent is the number of packets a reordered packet arrived
+	// late, over the time it was received.
+	reorderExtent    plotter.XYs
+	reorderedPackets int
+	maxReorderExtent int
+
+	// jitter is the RFC 3550 interarrival jitter in milliseconds over the
+	// time a packet was received.
+	jitter        plotter.XYs
+	averageJitter float64
+}
+
+// getRTPStreamStats compares the packets sent and received. Packets are
+// reordered if their extended sequence number is lower than the highest
+// extended sequence number received before in the same stream.
+func getRTPStreamStats(sent, received []rtpRecord) rtpStreamStats {
+	var s rtpStreamStats
+
+	type stream struct {
+		unwrapper  sequenceUnwrapper
+		highest    int64
+		started    bool
+		transit    float64
+		jitter     float64
+		hasTransit bool
+	}
+	streams := map[uint32]*stream{}
+	seen := map[rtpPacketKey]bool{}
+	var duplicates plotter.XYs
+	for _, r := range received {
+		if seen[r.key()] {
+			s.duplicatePackets++
+			duplicates = append(duplicates, plotter.XY{X: r.time, Y: 1})
+			continue
+		}
+		seen[r.key()] = true
+
+		st, ok := streams[r.ssrc]
+		if !ok {
+			st = &stream{}
+			streams[r.ssrc] = st
+		}
+		seq := st.unwrapper.unwrap(r.sequenceNumber)
+		if st.started && seq < st.highest {
+			extent := int(st.highest - seq)
+			s.reorderedPackets++
+			if extent > s.maxReorderExtent {
+				s.maxReorderExtent = extent
+			}
+			s.reorderExtent = append(s.reorderExtent, plotter.XY{X: r.time, Y: float64(extent)})
+		}
+		if !st.started || seq > st.highest {
+			st.highest = seq
+			st.started = true
+		}
+
+		transit := r.time - float64(r.timestamp)*1000/rtpVideoClockRate
+		if st.hasTransit {
+			d := math.Abs(transit - st.transit)
+			st.jitter += (d - st.jitter) / 16
+			s.jitter = append(s.jitter, plotter.XY{X: r.time, Y: st.jitter})
+		}
+		st.transit = transit
+		st.hasTransit = true
+	}
+	s.duplicates = binToSeconds(duplicates)
+	s.averageJitter = averageMapValues(s.jitter)
+
+	var sentPackets, lostPackets plotter.XYs
+	counted := map[rtpPacketKey]bool{}
+	for _, r := range sent {
+		if counted[r.key()] {
+			continue
+		}
+		counted[r.key()] = true
+		sentPackets = append(sentPackets, plotter.XY{X: r.time, Y: 1})
+		lost := 0.0
+		if !seen[r.key()] {
+			lost = 1
+		}
+		lostPackets = append(lostPackets, plotter.XY{X: r.time, Y: lost})
+	}
+	if len(sentPackets) == 0 {
+		return s
+	}
+	sentPerSecond, lostPerSecond := binToSeconds(sentPackets), binToSeconds(lostPackets)
+	total, lost := 0.0, 0.0
+	for i := range sentPerSecond {
+		total += sentPerSecond[i].Y
+		lost += lostPerSecond[i].Y
+		rate := 0.0
+		if sentPerSecond[i].Y > 0 {
+			rate = lostPerSecond[i].Y / sentPerSecond[i].Y
+		}
+		s.lossRate = append(s.lossRate, plotter.XY{X: sentPerSecond[i].X, Y: rate})
+	}
+	s.packetLoss = lost / total
+	return s
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// writeTestFile writes content to a file in a temporary directory and returns
+// its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestParseRTPRecord(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		row     []string
+		want    rtpRecord
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			row:  []string{"", "96", "12.5", "4242", "65535", "90000", "true", "", "1200"},
+			want: rtpRecord{
+				time:           12.5,
+				payloadType:    96,
+				ssrc:           4242,
+				sequenceNumber: 65535,
+				timestamp:      90000,
+				marker:         true,
+				size:           1200,
+			},
+		},
+		{
+			name:    "missing columns",
+			row:     []string{"", "96", "12.5", "4242"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			row:     []string{"", "96", "x", "4242", "1", "90000", "true", "", "1200"},
+			wantErr: true,
+		},
+		{
+			name:    "payload type out of range",
+			row:     []string{"", "256", "12.5", "4242", "1", "90000", "true", "", "1200"},
+			wantErr: true,
+		},
+		{
+			name:    "sequence number out of range",
+			row:     []string{"", "96", "12.5", "4242", "65536", "90000", "true", "", "1200"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid marker",
+			row:     []string{"", "96", "12.5", "4242", "1", "90000", "yes", "", "1200"},
+			wantErr: true,
+		},
+		{
+			name:    "empty size",
+			row:     []string{"", "96", "12.5", "4242", "1", "90000", "false", "", ""},
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRTPRecord(tt.row)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadRTPLog(t *testing.T) {
+	const (
+		first  = "a\t96\t0\t1\t1\t0\tfalse\tb\t1000\n"
+		second = "a\t96\t10\t1\t2\t0\ttrue\tb\t500\n"
+	)
+	want := []rtpRecord{
+		{time: 0, payloadType: 96, ssrc: 1, sequenceNumber: 1, size: 1000},
+		{time: 10, payloadType: 96, ssrc: 1, sequenceNumber: 2, marker: true, size: 500},
+	}
+	for _, tt := range []struct {
+		name    string
+		content string
+		want    []rtpRecord
+	}{
+		{name: "empty", content: ""},
+		{name: "complete", content: first + second, want: want},
+		{name: "missing trailing newline", content: first + second[:len(second)-1], want: want},
+		{name: "truncated row", content: first + second + "a\t96\t20\t1", want: want},
+		{name: "truncated value", content: first + second + "a\t96\t20\t1\t3\t0\tfa", want: want},
+		{name: "malformed row stops reading", content: first + "garbage\n" + second, want: want[:1]},
+		{name: "malformed quote", content: first + "a\t\"96\t20\n" + second, want: want[:1]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readRTPLog(writeTestFile(t, "rtp.log", tt.content))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		if _, err := readRTPLog(filepath.Join(t.TempDir(), "missing.log")); !os.IsNotExist(err) {
+			t.Errorf("expected not exist error, got %v", err)
+		}
+	})
+}
+
+func TestSequenceUnwrapper(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		seqs []uint16
+		want []int64
+	}{
+		{name: "in order", seqs: []uint16{1, 2, 3}, want: []int64{1, 2, 3}},
+		{name: "wrap around", seqs: []uint16{65534, 65535, 0, 1}, want: []int64{65534, 65535, 65536, 65537}},
+		{name: "reordered across wrap", seqs: []uint16{65535, 1, 0}, want: []int64{65535, 65537, 65536}},
+		{name: "backwards", seqs: []uint16{1, 0, 65535}, want: []int64{1, 0, -1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var u sequenceUnwrapper
+			var got []int64
+			for _, s := range tt.seqs {
+				got = append(got, u.unwrap(s))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// rtp returns a record of stream 1 of a frame with the RTP timestamp ts.
+func rtp(time float64, seq uint16, ts uint32) rtpRecord {
+	return rtpRecord{time: time, ssrc: 1, sequenceNumber: seq, timestamp: ts, size: 1000}
+}
+
+func TestGetRTPStreamStats(t *testing.T) {
+	// The transit time of the received packets grows by 10ms per packet, so
+	// the jitter is 10/16 after the second and 10/16+(10-10/16)/16 after the
+	// third packet.
+	for _, tt := range []struct {
+		name                string
+		sent, received      []rtpRecord
+		wantLoss            float64
+		wantDuplicates      int
+		wantReordered       int
+		wantMaxReorder      int
+		wantAverageJitter   float64
+		wantLossRateSeconds int
+	}{
+		{
+			name: "no packets",
+		},
+		{
+			name:                "lossless",
+			sent:                []rtpRecord{rtp(0, 1, 0), rtp(10, 2, 900), rtp(20, 3, 1800)},
+			received:            []rtpRecord{rtp(50, 1, 0), rtp(60, 2, 900), rtp(70, 3, 1800)},
+			wantLossRateSeconds: 1,
+		},
+		{
+			name:                "loss, duplicate and reordering",
+			sent:                []rtpRecord{rtp(0, 1, 0), rtp(10, 2, 0), rtp(20, 3, 0), rtp(1030, 4, 0)},
+			received:            []rtpRecord{rtp(50, 1, 0), rtp(60, 3, 0), rtp(70, 2, 0), rtp(80, 2, 0)},
+			wantLoss:            0.25,
+			wantDuplicates:      1,
+			wantReordered:       1,
+			wantMaxReorder:      1,
+			wantAverageJitter:   (2*10.0/16 + (10-10.0/16)/16) / 2,
+			wantLossRateSeconds: 2,
+		},
+		{
+			name:                "everything lost",
+			sent:                []rtpRecord{rtp(0, 1, 0), rtp(10, 2, 0)},
+			wantLoss:            1,
+			wantLossRateSeconds: 1,
+		},
+		{
+			name:                "reordered across wrap around",
+			sent:                []rtpRecord{rtp(0, 65535, 0), rtp(10, 0, 0), rtp(20, 1, 0)},
+			received:            []rtpRecord{rtp(50, 65535, 0), rtp(60, 1, 0), rtp(70, 0, 0)},
+			wantReordered:       1,
+			wantMaxReorder:      1,
+			wantAverageJitter:   (2*10.0/16 + (10-10.0/16)/16) / 2,
+			wantLossRateSeconds: 1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := getRTPStreamStats(tt.sent, tt.received)
+			if s.packetLoss != tt.wantLoss {
+				t.Errorf("packet loss: got %v, want %v", s.packetLoss, tt.wantLoss)
+			}
+			if s.duplicatePackets != tt.wantDuplicates {
+				t.Errorf("duplicates: got %v, want %v", s.duplicatePackets, tt.wantDuplicates)
+			}
+			if s.reorderedPackets != tt.wantReordered || s.maxReorderExtent != tt.wantMaxReorder {
+				t.Errorf("reordering: got %v packets, extent %v, want %v packets, extent %v", s.reorderedPackets, s.maxReorderExtent, tt.wantReordered, tt.wantMaxReorder)
+			}
+			if len(s.lossRate) != tt.wantLossRateSeconds {
+				t.Errorf("loss rate: got %v seconds, want %v", len(s.lossRate), tt.wantLossRateSeconds)
+			}
+			if d := s.averageJitter - tt.wantAverageJitter; d > 1e-9 || d < -1e-9 {
+				t.Errorf("average jitter: got %v, want %v", s.averageJitter, tt.wantAverageJitter)
+			}
+		})
+	}
+}
+
+func TestGetLatency(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		sent, received []rtpRecord
+		baseDelay      float64
+		wantPackets    plotter.XYs
+		wantFrames     plotter.XYs
+	}{
+		{
+			name: "nothing received",
+			sent: []rtpRecord{rtp(0, 1, 0)},
+		},
+		{
+			name:        "clock offset",
+			sent:        []rtpRecord{rtp(0, 1, 0), rtp(10, 2, 0)},
+			received:    []rtpRecord{rtp(50, 1, 0), rtp(65, 2, 0)},
+			baseDelay:   20,
+			wantPackets: plotter.XYs{{X: 0, Y: 20}, {X: 10, Y: 25}},
+			wantFrames:  plotter.XYs{{X: 0, Y: 35}},
+		},
+		{
+			name:        "incomplete frames and duplicates",
+			sent:        []rtpRecord{rtp(0, 1, 0), rtp(10, 2, 3000), rtp(20, 3, 3000)},
+			received:    []rtpRecord{rtp(30, 1, 0), rtp(30, 1, 0), rtp(40, 2, 3000), rtp(100, 4, 6000)},
+			baseDelay:   30,
+			wantPackets: plotter.XYs{{X: 0, Y: 30}, {X: 10, Y: 30}},
+			wantFrames:  plotter.XYs{{X: 0, Y: 30}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			packets, frames := getLatency(tt.sent, tt.received, tt.baseDelay)
+			if !reflect.DeepEqual(packets, tt.wantPackets) {
+				t.Errorf("packets: got %v, want %v", packets, tt.wantPackets)
+			}
+			if !reflect.DeepEqual(frames, tt.wantFrames) {
+				t.Errorf("frames: got %v, want %v", frames, tt.wantFrames)
+			}
+		})
+	}
+}
+
+func TestNewPercentiles(t *testing.T) {
+	var hundred plotter.XYs
+	for i := 100; i > 0; i-- {
+		hundred = append(hundred, plotter.XY{Y: float64(i)})
+	}
+	for _, tt := range []struct {
+		name string
+		xys  plotter.XYs
+		want Percentiles
+	}{
+		{name: "empty", want: Percentiles{}},
+		{name: "single", xys: plotter.XYs{{Y: 7}}, want: Percentiles{P50: 7, P95: 7, P99: 7}},
+		{name: "hundred", xys: hundred, want: Percentiles{P50: 50, P95: 95, P99: 99}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPercentiles(tt.xys); got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinForwardDelay(t *testing.T) {
+	backward := tcConfig{Delay: ms(5)}
+	for _, tt := range []struct {
+		name   string
+		phases []tcPhase
+		want   float64
+	}{
+		{name: "no phases", want: 0},
+		{
+			name: "minimum of all phases",
+			phases: []tcPhase{
+				{Duration: Duration{time.Second}, Config: tcConfig{Delay: ms(50)}},
+				{Config: tcConfig{Delay: ms(20)}},
+			},
+			want: 20,
+		},
+		{
+			name: "forward direction only",
+			phases: []tcPhase{
+				{Config: tcConfig{Delay: ms(50)}, Backward: &backward},
+			},
+			want: 50,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minForwardDelay(TestCase{Phases: tt.phases}); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
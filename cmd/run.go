@@ -2,14 +2,18 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync"
 	"time"
 
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 	"github.com/spf13/cobra"
 )
@@ -19,13 +23,31 @@ var (
 	implementation string
 	testcase       string
 	timeout        time.Duration
+
+	runMatrix            bool
+	runOutputDir         string
+	runAggregateFilename string
+	runParallel          int
+	runRepetitions       int
+	runCPUsPerRun        float64
+	runVideoCacheDir     string
 )
 
+const configFilename = "config.json"
+
 func init() {
 	runCmd.Flags().Int64VarP(&runDate, "date", "d", time.Now().Unix(), "Unix Timestamp in seconds since epoch")
 	runCmd.Flags().StringVarP(&implementation, "implementation", "i", "rtq-go-scream", "implementation from implementation.json to use")
 	runCmd.Flags().StringVarP(&testcase, "testcase", "c", "simple-p2p-1", "test case to run")
 	runCmd.Flags().DurationVarP(&timeout, "timeout", "t", 4*time.Minute, "max time to wait before cancelling the test run")
+	runCmd.Flags().BoolVarP(&runMatrix, "matrix", "m", false, "run every implementation/testcase combination matching the glob patterns given by --implementation and --testcase")
+	runCmd.Flags().BoolVar(&runMatrix, "all", false, "alias for --matrix")
+	runCmd.Flags().StringVarP(&runOutputDir, "output", "o", "results", "output directory for matrix runs, each run gets its own subdirectory")
+	runCmd.Flags().StringVar(&runAggregateFilename, "aggregate", "results.json", "filename for the aggregated results of a matrix run")
+	runCmd.Flags().IntVar(&runRepetitions, "repetitions", 1, "number of times each implementation/testcase combination is run, implies a matrix run if greater than one")
+	runCmd.Flags().IntVar(&runParallel, "parallel", 1, "number of matrix runs to execute at the same time, each in its own docker network")
+	runCmd.Flags().Float64Var(&runCPUsPerRun, "cpus-per-run", 2, "CPUs reserved for each parallel run, limits --parallel to the available CPUs")
+	runCmd.Flags().StringVar(&runVideoCacheDir, "video-cache", ".cache/videos", "cache directory for downloaded and prepared videos of the testcases")
 
 	rootCmd.AddCommand(runCmd)
 }
@@ -33,225 +55,507 @@ func init() {
 var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Execute tests",
-	RunE: func(*cobra.Command, []string) error {
+	RunE: func(cmd *cobra.Command, _ []string) error {
 		var is Implementations
 		err := parseJSONFile("implementations.json", &is)
 		if err != nil {
 			return err
 		}
+		var ts TestCases
+		err = parseJSONFile("testcases.json", &ts)
+		if err != nil {
+			return err
+		}
+
+		if runRepetitions < 1 {
+			return fmt.Errorf("invalid number of repetitions: %v", runRepetitions)
+		}
+		if runMatrix || runRepetitions > 1 {
+			implementationPattern, testcasePattern := implementation, testcase
+			if runMatrix && !cmd.Flags().Changed("implementation") {
+				implementationPattern = "*"
+			}
+			if runMatrix && !cmd.Flags().Changed("testcase") {
+				testcasePattern = "*"
+			}
+			runs, err := selectMatrix(is, ts, implementationPattern, testcasePattern, runRepetitions)
+			if err != nil {
+				return err
+			}
+			var testcases []TestCase
+			for _, r := range runs {
+				testcases = append(testcases, r.testcase)
+			}
+			if err = prepareVideos(testcases, runVideoCacheDir); err != nil {
+				return err
+			}
+			return runAll(runs, runOutputDir, runAggregateFilename, runParallel, runCPUsPerRun)
+		}
+
 		i, ok := is[implementation]
 		if !ok {
 			return fmt.Errorf("implementation not found: %v", implementation)
 		}
 		i.Name = implementation
 
-		var ts TestCases
-		err = parseJSONFile("testcases.json", &ts)
-		if err != nil {
-			return err
-		}
 		t, ok := ts[testcase]
 		if !ok {
 			return fmt.Errorf("testcase not found: %v", testcase)
 		}
 		t.Name = testcase
+		if err = resolveCrossTraffic(&t, is); err != nil {
+			return err
+		}
+		if err = validateAssertions(t); err != nil {
+			return err
+		}
+		if err = prepareVideos([]TestCase{t}, runVideoCacheDir); err != nil {
+			return err
+		}
 
 		return run(&Config{
 			Date:           time.Unix(runDate, 0),
 			Implementation: i,
 			TestCase:       t,
 			Timeout:        timeout,
-		})
+		}, ".", os.Stdout)
 	},
 }
 
-func run(c *Config) error {
-	err := saveToJSONFile("config.json", c)
-	if err != nil {
+// run executes the test described by c. The config file is written to dir,
+// the logs of the sender and receiver to dir/sender_logs and
+// dir/receiver_logs and the receiver writes its output video to dir/output.
+// The output of the containers is written to output.
+func run(c *Config, dir string, output io.Writer) error {
+	if c.TestCase.Queue != nil {
+		c.Queue = *c.TestCase.Queue
+	}
+	c.Queue = c.Queue.withDefaults()
+	if err := c.Queue.validate(); err != nil {
 		return err
 	}
 
-	cmd := exec.Command("docker-compose", "up", "--abort-on-container-exit", "--force-recreate")
+	err := saveToJSONFile(filepath.Join(dir, configFilename), c)
+	if err != nil {
+		return err
+	}
 
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
+	input, err := filepath.Abs(inputDir)
+	if err != nil {
+		return err
+	}
+	mounts := map[string]string{}
+	for _, d := range []string{"output", "sender_logs", "receiver_logs"} {
+		abs, err := filepath.Abs(filepath.Join(dir, d))
+		if err != nil {
+			return err
+		}
+		if err = os.RemoveAll(abs); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(abs, os.ModePerm); err != nil {
+			return err
+		}
+		mounts[d] = abs
+	}
 
-	cmd.Env = os.Environ()
-	for k, v := range map[string]string{
-		"SENDER":   c.Implementation.Sender.Image,
-		"RECEIVER": c.Implementation.Receiver.Image,
-		"VIDEOS":   path.Join("input", c.TestCase.VideoFile.Name),
+	tc := trafficController{
+		phases: c.TestCase.Phases,
+		queue:  c.Queue,
+	}
+	if c.TestCase.Trace != nil {
+		if tc.trace, err = loadTrace(*c.TestCase.Trace); err != nil {
+			return fmt.Errorf("failed to load trace: %w", err)
+		}
+	}
 
-		"SENDER_PARAMS":   c.Implementation.Sender.Params,
-		"RECEIVER_PARAMS": c.Implementation.Receiver.Params,
-		"INPUT":           "./input",
-		"OUTPUT":          "./output",
-	} {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%v=%v", k, v))
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to get docker client: %w", err)
 	}
+	defer cli.Close()
+
+	tb := newTestbed(cli, newRunID(c), output)
+	defer tb.close()
+
+	// Wait for the log readers after cancelling ctx, as the caller may
+	// close output when run returns.
+	var logReaders sync.WaitGroup
+	defer logReaders.Wait()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
-	tc := trafficController{
-		phases: c.TestCase.Phases,
-	}
 
-	err = cmd.Start()
+	if err = tb.createNetwork(ctx); err != nil {
+		return err
+	}
+	videos := path.Join(inputDir, c.TestCase.VideoFile.Name)
+	// The CPU limit of a run is shared equally by sender, receiver and the
+	// two containers of every cross traffic flow.
+	nanoCPUs := int64(c.CPUs / float64(2+2*len(c.TestCase.CrossTraffic)) * 1e9)
+	err = tb.create(ctx, "sender", containerSpec{
+		image: c.Implementation.Sender.Image,
+		env: []string{
+			"ROLE=sender",
+			"QLOGDIR=/logs/qlog",
+			"RTPLOGDIR=/logs/rtp",
+			"CCLOGFILE=/logs/cc.log",
+			"LOG_FILE=/logs/qrt.log",
+			"STREAMLOGFILE=/logs/stream.log",
+			"RECEIVER=receiver:4242",
+			"VIDEOS=" + videos,
+			"SENDER_PARAMS=" + c.Implementation.Sender.Params,
+		},
+		binds: []string{
+			fmt.Sprintf("%v:/input:ro", input),
+			fmt.Sprintf("%v:/logs", mounts["sender_logs"]),
+		},
+		capAdd:   []string{"NET_ADMIN"},
+		nanoCPUs: nanoCPUs,
+	})
+	if err != nil {
+		return err
+	}
+	err = tb.create(ctx, "receiver", containerSpec{
+		image: c.Implementation.Receiver.Image,
+		env: []string{
+			"ROLE=receiver",
+			"QLOGDIR=/logs/qlog",
+			"RTPLOGDIR=/logs/rtp",
+			"LOG_FILE=/logs/qrt.log",
+			"STREAMLOGFILE=/logs/stream.log",
+			"DESTINATION=/streams/out.mkv",
+			"RECEIVER_PARAMS=" + c.Implementation.Receiver.Params,
+		},
+		binds: []string{
+			fmt.Sprintf("%v:/streams", mounts["output"]),
+			fmt.Sprintf("%v:/logs", mounts["receiver_logs"]),
+		},
+		capAdd:   []string{"NET_ADMIN"},
+		nanoCPUs: nanoCPUs,
+	})
 	if err != nil {
 		return err
 	}
-	go func() {
-		cli, err := client.NewClientWithOpts(client.FromEnv)
-		if err != nil {
-			log.Printf("failed to get docker client: %v\n", err)
-			return
-		}
 
-		containerRunning := map[string]bool{
-			"/sender":   false,
-			"/receiver": false,
-		}
-		waitingFor := len(containerRunning)
-		done := false
-		start := time.Now()
-		for !done {
-			var containers []types.Container
-			if containers, err = cli.ContainerList(context.Background(), types.ContainerListOptions{}); err != nil {
-				log.Printf("failed to get container list: %v\n", err)
-			}
-			for _, container := range containers {
-				for _, name := range container.Names {
-					if running, ok := containerRunning[name]; ok && !running {
-						if container.State == "running" {
-							containerRunning[name] = true
-							waitingFor--
-							fmt.Printf("found container %v in state %v\n", name, container.State)
-						}
-					}
-				}
-			}
-			if waitingFor == 0 {
-				done = true
-			}
-			if time.Since(start) > 5*time.Minute {
-				fmt.Printf("stopped waiting for container after 1 minute, skipping traffic control")
-				return
+	for _, role := range []string{"sender", "receiver"} {
+		logReaders.Add(1)
+		go func(role string) {
+			defer logReaders.Done()
+			if err := tb.logs(ctx, role, output, output); err != nil && ctx.Err() == nil {
+				log.Printf("failed to read logs of %v: %v\n", role, err)
 			}
-			time.Sleep(100 * time.Millisecond)
-		}
+		}(role)
+	}
 
-		log.Printf("start traffic controller after %v\n", time.Since(start))
-		err = tc.run(ctx)
-		if err != nil {
+	start := time.Now()
+	startCtx, startCancel := context.WithTimeout(ctx, c.Timeout)
+	err = tb.start(startCtx, "sender", "receiver")
+	startCancel()
+	if err != nil {
+		return err
+	}
+	log.Printf("start traffic controller after %v\n", time.Since(start))
+
+	// Stop the controllers and wait for them to clean up before the
+	// containers are removed.
+	var controllers sync.WaitGroup
+	defer controllers.Wait()
+	defer cancel()
+
+	tc.sender = tb.exec(ctx, "sender")
+	tc.receiver = tb.exec(ctx, "receiver")
+	ct := crossTrafficController{
+		testbed:  tb,
+		flows:    c.TestCase.CrossTraffic,
+		dir:      dir,
+		videos:   videos,
+		input:    input,
+		nanoCPUs: nanoCPUs,
+	}
+	controllers.Add(2)
+	go func() {
+		defer controllers.Done()
+		if err := tc.run(ctx); err != nil {
 			log.Printf("tc controller failed: %v\n", err)
 		}
 	}()
-
-	done := make(chan error, 1)
 	go func() {
-		done <- cmd.Wait()
+		defer controllers.Done()
+		if err := ct.run(ctx); err != nil {
+			log.Printf("cross traffic controller failed: %v\n", err)
+		}
 	}()
 
-	select {
-	case err := <-done:
-		if err != nil {
-			return err
-		}
-	case <-time.After(timeout):
-		err := cmd.Process.Kill()
-		if err != nil {
-			return err
-		}
-		log.Printf("WARNING: process killed after timeout")
+	waitCtx, waitCancel := context.WithTimeout(ctx, c.Timeout)
+	defer waitCancel()
+	role, err := tb.wait(waitCtx, "sender", "receiver")
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		log.Printf("WARNING: stopping containers after timeout")
+	case err != nil:
+		return err
+	default:
+		log.Printf("%v exited, stopping all containers\n", role)
 	}
-	return nil
+	return tb.stop(context.Background(), "sender", "receiver")
 }
 
 type tcConfig struct {
 	Delay   Duration `json:"delay"`
 	Bitrate int      `json:"bitrate"`
+
+	// Jitter is the delay variation added to Delay, JitterDistribution
+	// optionally names the netem distribution table to use, e.g. "normal"
+	// or "pareto".
+	Jitter             Duration `json:"jitter"`
+	JitterDistribution string   `json:"jitter_distribution"`
+
+	// Loss, Reorder, Duplicate and Corrupt are given in percent, the
+	// correlations in percent relative to the previous packet.
+	Loss               float64 `json:"loss"`
+	LossCorrelation    float64 `json:"loss_correlation"`
+	Reorder            float64 `json:"reorder"`
+	ReorderCorrelation float64 `json:"reorder_correlation"`
+	Duplicate          float64 `json:"duplicate"`
+	Corrupt            float64 `json:"corrupt"`
 }
 
-func (t tcConfig) apply(isFirst bool) error {
-	cmd := "change"
-	if isFirst {
-		cmd = "add"
+func (t tcConfig) netemArgs() []string {
+	args := []string{"netem", "delay", fmt.Sprintf("%vms", t.Delay.Milliseconds())}
+	if t.Jitter.Duration > 0 {
+		args = append(args, fmt.Sprintf("%vms", t.Jitter.Milliseconds()))
+		if t.JitterDistribution != "" {
+			args = append(args, "distribution", t.JitterDistribution)
+		}
 	}
-	for _, role := range []string{"sender", "receiver"} {
-		tcDelay := exec.Command(
-			"docker",
-			"exec",
-			role,
+	if t.Loss > 0 {
+		args = append(args, "loss", "random", percent(t.Loss))
+		if t.LossCorrelation > 0 {
+			args = append(args, percent(t.LossCorrelation))
+		}
+	}
+	if t.Reorder > 0 {
+		args = append(args, "reorder", percent(t.Reorder))
+		if t.ReorderCorrelation > 0 {
+			args = append(args, percent(t.ReorderCorrelation))
+		}
+	}
+	if t.Duplicate > 0 {
+		args = append(args, "duplicate", percent(t.Duplicate))
+	}
+	if t.Corrupt > 0 {
+		args = append(args, "corrupt", percent(t.Corrupt))
+	}
+	return args
+}
 
-			"tc",
-			"qdisc",
-			cmd,
-			"dev", "eth0",
-			"root", "handle", "1:",
-			"netem", "delay", fmt.Sprintf("%vms", t.Delay.Milliseconds()),
-		)
-		log.Printf("applying tcConfig: %v %v\n", tcDelay.Path, tcDelay.Args)
-		tcDelay.Stdout = os.Stdout
-		tcDelay.Stderr = os.Stderr
+// minRate is the lowest rate in bit/s passed to tbf, which does not accept a
+// rate of 0, e.g. for outages in a trace.
+const minRate = 8000
 
-		err := tcDelay.Run()
-		if err != nil {
+func (t tcConfig) rate() int {
+	if t.Bitrate < minRate {
+		return minRate
+	}
+	return t.Bitrate
+}
+
+func percent(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64) + "%"
+}
+
+// apply configures the egress qdiscs of the container exec runs in. If
+// previous is nil, the qdiscs are added, otherwise only the qdiscs that
+// differ from previous are changed.
+func (t tcConfig) apply(exec execFunc, q Queue, previous *tcConfig) error {
+	for _, args := range t.qdiscArgs(q, previous) {
+		if err := tc(exec, args...); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		tcBitrate := exec.Command(
-			"docker",
-			"exec",
-			role,
+// qdiscArgs returns the arguments of the 'tc qdisc' commands apply runs.
+func (t tcConfig) qdiscArgs(q Queue, previous *tcConfig) [][]string {
+	cmd := "change"
+	if previous == nil {
+		cmd = "add"
+	}
+	var commands [][]string
+	if previous == nil || !reflect.DeepEqual(t.netemArgs(), previous.netemArgs()) {
+		commands = append(commands, append([]string{
+			cmd,
+			"dev", "eth0",
+			"root", "handle", "1:",
+		}, t.netemArgs()...))
+	}
 
-			"tc",
-			"qdisc",
+	if previous == nil || t.Bitrate != previous.Bitrate {
+		commands = append(commands, append([]string{
 			cmd,
 			"dev", "eth0",
 			"parent", "1:", "handle", "2:",
-			"tbf", "rate", fmt.Sprintf("%v", t.Bitrate), "latency", "400ms", "burst", "20kB",
-		)
-		log.Printf("applying tcConfig: %v %v\n", tcBitrate.Path, tcBitrate.Args)
-		tcBitrate.Stdout = os.Stdout
-		tcBitrate.Stderr = os.Stderr
-
-		err = tcBitrate.Run()
-		if err != nil {
-			return err
-		}
+		}, q.tbfArgs(t.rate())...))
+	}
 
+	// The child qdisc depends on the rate, too, e.g. RED averages the queue
+	// length with the bandwidth of the link.
+	child := q.childArgs(t.rate())
+	if child != nil && (previous == nil || !reflect.DeepEqual(child, q.childArgs(previous.rate()))) {
+		commands = append(commands, append([]string{
+			cmd,
+			"dev", "eth0",
+			"parent", "2:1", "handle", "3:",
+		}, child...))
 	}
-	return nil
+	return commands
+}
+
+// tc runs 'tc qdisc' with args using exec.
+func tc(exec execFunc, args ...string) error {
+	cmd := append([]string{"tc", "qdisc"}, args...)
+	log.Printf("applying tcConfig: %v\n", cmd)
+	return exec(cmd...)
 }
 
+// tcPhase configures the link for Duration. Config applies to both
+// directions, unless Forward (sender to receiver) or Backward (receiver to
+// sender) are set.
 type tcPhase struct {
-	Duration Duration `json:"duration"`
-	Config   tcConfig `json:"config"`
+	Duration Duration  `json:"duration"`
+	Config   tcConfig  `json:"config"`
+	Forward  *tcConfig `json:"forward,omitempty"`
+	Backward *tcConfig `json:"backward,omitempty"`
+}
+
+func (p tcPhase) link() tcLink {
+	l := tcLink{
+		forward:  p.Config,
+		backward: p.Config,
+	}
+	if p.Forward != nil {
+		l.forward = *p.Forward
+	}
+	if p.Backward != nil {
+		l.backward = *p.Backward
+	}
+	return l
+}
+
+// tcLink is the configuration of both directions of the link. The forward
+// direction is applied to the egress of the sender, the backward direction to
+// the egress of the receiver.
+type tcLink struct {
+	forward  tcConfig
+	backward tcConfig
+}
+
+func (l tcLink) apply(sender, receiver execFunc, q Queue, previous *tcLink) error {
+	var previousForward, previousBackward *tcConfig
+	if previous != nil {
+		previousForward = &previous.forward
+		previousBackward = &previous.backward
+	}
+	if err := l.forward.apply(sender, q, previousForward); err != nil {
+		return err
+	}
+	return l.backward.apply(receiver, q, previousBackward)
 }
 
 type trafficController struct {
 	phases []tcPhase
+	trace  []rateSample
+	queue  Queue
+
+	sender   execFunc
+	receiver execFunc
 }
 
-func (t *trafficController) run(ctx context.Context) error {
-	if len(t.phases) <= 0 {
-		return nil
+// tcStep is a link configuration which is applied at the given offset from
+// the start of the traffic controller.
+type tcStep struct {
+	at   time.Duration
+	link tcLink
+}
+
+// schedule merges the phases and the bitrates of the trace into the list of
+// steps to apply. The trace overrides the forward bitrate of the phases and
+// the backward bitrate of phases that do not configure the backward direction
+// explicitly. A phase with a duration of 0 lasts forever, any phase following
+// it is ignored.
+func (t *trafficController) schedule() []tcStep {
+	type phaseStart struct {
+		at    time.Duration
+		phase tcPhase
+	}
+	var starts []phaseStart
+	var offset time.Duration
+	for _, p := range t.phases {
+		starts = append(starts, phaseStart{at: offset, phase: p})
+		if p.Duration.Duration == 0 {
+			break
+		}
+		offset += p.Duration.Duration
 	}
 
-	for i, p := range t.phases {
-		err := p.Config.apply(i == 0)
-		if err != nil {
-			return err
+	var steps []tcStep
+	var phase tcPhase
+	var rate int
+	traced := false
+	i, j := 0, 0
+	for i < len(starts) || j < len(t.trace) {
+		var at time.Duration
+		switch {
+		case j >= len(t.trace) || (i < len(starts) && starts[i].at <= t.trace[j].at):
+			at = starts[i].at
+		default:
+			at = t.trace[j].at
 		}
-		if p.Duration.Duration == 0 {
-			return nil
+		for ; i < len(starts) && starts[i].at == at; i++ {
+			phase = starts[i].phase
+		}
+		for ; j < len(t.trace) && t.trace[j].at == at; j++ {
+			rate = t.trace[j].bitrate
+			traced = true
 		}
 
+		link := phase.link()
+		if traced {
+			link.forward.Bitrate = rate
+			if phase.Backward == nil {
+				link.backward.Bitrate = rate
+			}
+		}
+		if len(steps) > 0 && steps[len(steps)-1].link == link {
+			continue
+		}
+		steps = append(steps, tcStep{at: at, link: link})
+	}
+	return steps
+}
+
+func (t *trafficController) run(ctx context.Context) error {
+	steps := t.schedule()
+	if len(steps) <= 0 {
+		return nil
+	}
+
+	start := time.Now()
+	var previous *tcLink
+	for i := range steps {
 		select {
 		case <-ctx.Done():
 			return nil
-		case <-time.After(p.Duration.Duration):
+		case <-time.After(time.Until(start.Add(steps[i].at))):
+		}
+
+		err := steps[i].link.apply(t.sender, t.receiver, t.queue, previous)
+		if err != nil {
+			return err
 		}
+		previous = &steps[i].link
 	}
 	return nil
 }
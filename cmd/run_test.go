@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ms(v int) Duration {
+	return Duration{time.Duration(v) * time.Millisecond}
+}
+
+func TestNetemArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		config tcConfig
+		want   string
+	}{
+		{
+			name:   "delay only",
+			config: tcConfig{Delay: ms(50)},
+			want:   "netem delay 50ms",
+		},
+		{
+			name:   "jitter",
+			config: tcConfig{Delay: ms(50), Jitter: ms(10)},
+			want:   "netem delay 50ms 10ms",
+		},
+		{
+			name:   "jitter distribution",
+			config: tcConfig{Delay: ms(50), Jitter: ms(10), JitterDistribution: "pareto"},
+			want:   "netem delay 50ms 10ms distribution pareto",
+		},
+		{
+			name:   "distribution without jitter",
+			config: tcConfig{Delay: ms(50), JitterDistribution: "normal"},
+			want:   "netem delay 50ms",
+		},
+		{
+			name:   "loss",
+			config: tcConfig{Loss: 0.5},
+			want:   "netem delay 0ms loss random 0.5%",
+		},
+		{
+			name:   "loss correlation",
+			config: tcConfig{Loss: 1, LossCorrelation: 25},
+			want:   "netem delay 0ms loss random 1% 25%",
+		},
+		{
+			name:   "correlation without loss",
+			config: tcConfig{LossCorrelation: 25},
+			want:   "netem delay 0ms",
+		},
+		{
+			name:   "reorder",
+			config: tcConfig{Delay: ms(10), Reorder: 10, ReorderCorrelation: 50},
+			want:   "netem delay 10ms reorder 10% 50%",
+		},
+		{
+			name: "everything",
+			config: tcConfig{
+				Delay:              ms(100),
+				Jitter:             ms(20),
+				JitterDistribution: "normal",
+				Loss:               2,
+				LossCorrelation:    10,
+				Reorder:            5,
+				Duplicate:          0.1,
+				Corrupt:            0.01,
+			},
+			want: "netem delay 100ms 20ms distribution normal loss random 2% 10% reorder 5% duplicate 0.1% corrupt 0.01%",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.netemArgs()
+			if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	phase := func(d, delay, bitrate int) tcPhase {
+		return tcPhase{
+			Duration: Duration{time.Duration(d) * time.Second},
+			Config:   tcConfig{Delay: ms(delay), Bitrate: bitrate},
+		}
+	}
+	step := func(at, delay, bitrate int) tcStep {
+		c := tcConfig{Delay: ms(delay), Bitrate: bitrate}
+		return tcStep{
+			at:   time.Duration(at) * time.Second,
+			link: tcLink{forward: c, backward: c},
+		}
+	}
+	directed := func(at int, forward, backward tcConfig) tcStep {
+		return tcStep{
+			at:   time.Duration(at) * time.Second,
+			link: tcLink{forward: forward, backward: backward},
+		}
+	}
+	slow := tcConfig{Delay: ms(10), Bitrate: 100}
+	sample := func(at, bitrate int) rateSample {
+		return rateSample{at: time.Duration(at) * time.Second, bitrate: bitrate}
+	}
+	for _, tt := range []struct {
+		name   string
+		phases []tcPhase
+		trace  []rateSample
+		want   []tcStep
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:   "phases",
+			phases: []tcPhase{phase(10, 50, 1000), phase(10, 100, 2000)},
+			want:   []tcStep{step(0, 50, 1000), step(10, 100, 2000)},
+		},
+		{
+			name:   "endless phase ends the schedule",
+			phases: []tcPhase{phase(0, 50, 1000), phase(10, 100, 2000)},
+			want:   []tcStep{step(0, 50, 1000)},
+		},
+		{
+			name:   "unchanged phases are merged",
+			phases: []tcPhase{phase(10, 50, 1000), phase(10, 50, 1000), phase(0, 10, 1000)},
+			want:   []tcStep{step(0, 50, 1000), step(20, 10, 1000)},
+		},
+		{
+			name:   "trace overrides bitrate",
+			phases: []tcPhase{phase(0, 50, 1000)},
+			trace:  []rateSample{sample(0, 500), sample(1, 700), sample(2, 700)},
+			want:   []tcStep{step(0, 50, 500), step(1, 50, 700)},
+		},
+		{
+			name:   "trace keeps bitrate across phases",
+			phases: []tcPhase{phase(2, 50, 1000), phase(0, 100, 1000)},
+			trace:  []rateSample{sample(0, 500), sample(1, 700), sample(3, 900)},
+			want:   []tcStep{step(0, 50, 500), step(1, 50, 700), step(2, 100, 700), step(3, 100, 900)},
+		},
+		{
+			name:   "phase bitrate until trace starts",
+			phases: []tcPhase{phase(0, 50, 1000)},
+			trace:  []rateSample{sample(2, 500)},
+			want:   []tcStep{step(0, 50, 1000), step(2, 50, 500)},
+		},
+		{
+			name: "forward and backward",
+			phases: []tcPhase{
+				{Duration: Duration{time.Second}, Config: tcConfig{Delay: ms(50), Bitrate: 1000}, Forward: &slow},
+				{Config: tcConfig{Delay: ms(50), Bitrate: 1000}, Backward: &slow},
+			},
+			want: []tcStep{
+				directed(0, slow, tcConfig{Delay: ms(50), Bitrate: 1000}),
+				directed(1, tcConfig{Delay: ms(50), Bitrate: 1000}, slow),
+			},
+		},
+		{
+			name: "trace does not override explicit backward bitrate",
+			phases: []tcPhase{
+				{Config: tcConfig{Delay: ms(50), Bitrate: 1000}, Backward: &slow},
+			},
+			trace: []rateSample{sample(1, 500)},
+			want: []tcStep{
+				directed(0, tcConfig{Delay: ms(50), Bitrate: 1000}, slow),
+				directed(1, tcConfig{Delay: ms(50), Bitrate: 500}, slow),
+			},
+		},
+		{
+			name: "trace overrides explicit forward bitrate",
+			phases: []tcPhase{
+				{Config: tcConfig{Delay: ms(50), Bitrate: 1000}, Forward: &slow},
+			},
+			trace: []rateSample{sample(0, 500)},
+			want: []tcStep{
+				directed(0, tcConfig{Delay: ms(10), Bitrate: 500}, tcConfig{Delay: ms(50), Bitrate: 500}),
+			},
+		},
+		{
+			name:  "trace without phases",
+			trace: []rateSample{sample(0, 500), sample(1, 700)},
+			want:  []tcStep{step(0, 0, 500), step(1, 0, 700)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &trafficController{phases: tt.phases, trace: tt.trace}
+			got := c.schedule()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+// tQuantiles95 are the two-sided 95% quantiles of the Student's t
+// distribution for 1 to 30 degrees of freedom.
+var tQuantiles95 = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// Statistic summarizes the values of a metric over all repetitions of a run.
+type Statistic struct {
+	N      int
+	Mean   float64
+	StdDev float64
+	// CI95 is the half width of the 95% confidence interval of the mean.
+	CI95 float64
+}
+
+func newStatistic(values []float64) Statistic {
+	s := Statistic{N: len(values)}
+	if s.N == 0 {
+		return s
+	}
+	for _, v := range values {
+		s.Mean += v
+	}
+	s.Mean /= float64(s.N)
+	if s.N == 1 {
+		return s
+	}
+	for _, v := range values {
+		s.StdDev += (v - s.Mean) * (v - s.Mean)
+	}
+	s.StdDev = math.Sqrt(s.StdDev / float64(s.N-1))
+
+	// Use the normal approximation for more than 30 degrees of freedom.
+	t := 1.96
+	if s.N-1 <= len(tQuantiles95) {
+		t = tQuantiles95[s.N-2]
+	}
+	s.CI95 = t * s.StdDev / math.Sqrt(float64(s.N))
+	return s
+}
+
+func (s Statistic) String() string {
+	if s.N <= 1 {
+		return fmt.Sprintf("%.3f", s.Mean)
+	}
+	return fmt.Sprintf("%.3f ± %.3f", s.Mean, s.CI95)
+}
+
+// envelope returns the minimum and maximum of all series at every x value
+// of any of the series. Series must be sorted by x, they are interpolated
+// linearly between their points and only contribute within their x range.
+func envelope(series []plotter.XYs) (lower, upper plotter.XYs) {
+	var xs []float64
+	for _, s := range series {
+		for _, p := range s {
+			xs = append(xs, p.X)
+		}
+	}
+	sort.Float64s(xs)
+	for i, x := range xs {
+		if i > 0 && x == xs[i-1] {
+			continue
+		}
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, s := range series {
+			if y, ok := interpolate(s, x); ok {
+				min = math.Min(min, y)
+				max = math.Max(max, y)
+			}
+		}
+		if math.IsInf(min, 1) {
+			continue
+		}
+		lower = append(lower, plotter.XY{X: x, Y: min})
+		upper = append(upper, plotter.XY{X: x, Y: max})
+	}
+	return lower, upper
+}
+
+// interpolate returns the value of s at x, if x is within the range of s.
+func interpolate(s plotter.XYs, x float64) (float64, bool) {
+	i := sort.Search(len(s), func(i int) bool {
+		return s[i].X >= x
+	})
+	if i == len(s) {
+		return 0, false
+	}
+	if s[i].X == x {
+		return s[i].Y, true
+	}
+	if i == 0 {
+		return 0, false
+	}
+	a, b := s[i-1], s[i]
+	return a.Y + (b.Y-a.Y)*(x-a.X)/(b.X-a.X), true
+}
@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/plot/plotter"
+)
+
+func sequence(from, to int) []float64 {
+	var values []float64
+	for i := from; i <= to; i++ {
+		values = append(values, float64(i))
+	}
+	return values
+}
+
+func TestNewStatistic(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		values []float64
+		want   Statistic
+	}{
+		{
+			name: "no values",
+			want: Statistic{},
+		},
+		{
+			name:   "single value",
+			values: []float64{3.5},
+			want:   Statistic{N: 1, Mean: 3.5},
+		},
+		{
+			name:   "two values",
+			values: []float64{1, 3},
+			want:   Statistic{N: 2, Mean: 2, StdDev: math.Sqrt2, CI95: 12.706},
+		},
+		{
+			name:   "constant values",
+			values: []float64{4, 4, 4},
+			want:   Statistic{N: 3, Mean: 4},
+		},
+		{
+			name:   "t distribution",
+			values: []float64{2, 4, 4, 4, 5, 5, 7, 9},
+			want:   Statistic{N: 8, Mean: 5, StdDev: 2.138090, CI95: 1.787772},
+		},
+		{
+			name:   "30 degrees of freedom",
+			values: sequence(1, 31),
+			want:   Statistic{N: 31, Mean: 16, StdDev: 9.092121, CI95: 3.334572},
+		},
+		{
+			name:   "normal approximation",
+			values: sequence(1, 40),
+			want:   Statistic{N: 40, Mean: 20.5, StdDev: 11.690452, CI95: 3.622909},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newStatistic(tt.values)
+			if got.N != tt.want.N ||
+				math.Abs(got.Mean-tt.want.Mean) > 1e-6 ||
+				math.Abs(got.StdDev-tt.want.StdDev) > 1e-6 ||
+				math.Abs(got.CI95-tt.want.CI95) > 1e-6 {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatisticString(t *testing.T) {
+	for _, tt := range []struct {
+		s    Statistic
+		want string
+	}{
+		{Statistic{}, "0.000"},
+		{Statistic{N: 1, Mean: 0.5}, "0.500"},
+		{Statistic{N: 3, Mean: 0.5, StdDev: 0.1, CI95: 0.25}, "0.500 ± 0.250"},
+	} {
+		if got := tt.s.String(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	s := plotter.XYs{{X: 1, Y: 10}, {X: 3, Y: 30}, {X: 4, Y: 0}}
+	for _, tt := range []struct {
+		name   string
+		s      plotter.XYs
+		x      float64
+		want   float64
+		wantOK bool
+	}{
+		{name: "empty", s: nil, x: 1},
+		{name: "before first point", s: s, x: 0.5},
+		{name: "after last point", s: s, x: 4.5},
+		{name: "first point", s: s, x: 1, want: 10, wantOK: true},
+		{name: "last point", s: s, x: 4, want: 0, wantOK: true},
+		{name: "between points", s: s, x: 2, want: 20, wantOK: true},
+		{name: "falling", s: s, x: 3.25, want: 22.5, wantOK: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := interpolate(tt.s, tt.x)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("got %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		series    []plotter.XYs
+		wantLower plotter.XYs
+		wantUpper plotter.XYs
+	}{
+		{
+			name: "no series",
+		},
+		{
+			name:      "single series",
+			series:    []plotter.XYs{{{X: 0, Y: 1}, {X: 1, Y: 2}}},
+			wantLower: plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 2}},
+			wantUpper: plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 2}},
+		},
+		{
+			name: "interpolated between points",
+			series: []plotter.XYs{
+				{{X: 0, Y: 0}, {X: 2, Y: 4}},
+				{{X: 1, Y: 1}},
+			},
+			wantLower: plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 4}},
+			wantUpper: plotter.XYs{{X: 0, Y: 0}, {X: 1, Y: 2}, {X: 2, Y: 4}},
+		},
+		{
+			name: "series of different length",
+			series: []plotter.XYs{
+				{{X: 0, Y: 5}, {X: 1, Y: 5}},
+				{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}},
+			},
+			wantLower: plotter.XYs{{X: 0, Y: 1}, {X: 1, Y: 1}, {X: 2, Y: 1}},
+			wantUpper: plotter.XYs{{X: 0, Y: 5}, {X: 1, Y: 5}, {X: 2, Y: 1}},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			lower, upper := envelope(tt.series)
+			if !reflect.DeepEqual(lower, tt.wantLower) {
+				t.Errorf("lower: got %v, want %v", lower, tt.wantLower)
+			}
+			if !reflect.DeepEqual(upper, tt.wantUpper) {
+				t.Errorf("upper: got %v, want %v", upper, tt.wantUpper)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// storeDateFormat is the format of the run date in the filenames of the
+// result store.
+const storeDateFormat = "20060102T150405Z"
+
+// resultStore is an append-only directory of results. Every result is stored
+// in <dir>/<implementation>/<testcase>/<date>-<repetition>.json and is never
+// overwritten, so that results of earlier runs are kept.
+type resultStore struct {
+	dir string
+}
+
+func (s resultStore) path(r *Result) string {
+	return filepath.Join(
+		s.dir,
+		r.Config.Implementation.Name,
+		r.Config.TestCase.Name,
+		fmt.Sprintf("%v-%v.json", r.Config.Date.UTC().Format(storeDateFormat), r.Config.Repetition),
+	)
+}
+
+// add stores r. It returns false if a result of the same run was already
+// stored.
+func (s resultStore) add(r *Result) (bool, error) {
+	path := s.path(r)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return false, err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// load reads all stored results. The repetitions of an implementation and
+// testcase contain the results of all dates, sorted by date and repetition.
+func (s resultStore) load() (AggregatedResults, error) {
+	results := AggregatedResults{}
+	err := filepath.Walk(s.dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		var r Result
+		if err := parseJSONFile(path, &r); err != nil {
+			return fmt.Errorf("failed to read stored result %v: %w", path, err)
+		}
+		implementation := r.Config.Implementation.Name
+		if _, ok := results[implementation]; !ok {
+			results[implementation] = map[string]Repetitions{}
+		}
+		results[implementation][r.Config.TestCase.Name] = append(results[implementation][r.Config.TestCase.Name], &r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range results {
+		for _, rs := range t {
+			sort.SliceStable(rs, func(i, j int) bool {
+				if !rs[i].Config.Date.Equal(rs[j].Config.Date) {
+					return rs[i].Config.Date.Before(rs[j].Config.Date)
+				}
+				return rs[i].Config.Repetition < rs[j].Config.Repetition
+			})
+		}
+	}
+	return results, nil
+}
+
+// trendPoint are the repetitions of a run at Date with the given images.
+type trendPoint struct {
+	Date          time.Time
+	SenderImage   string
+	ReceiverImage string
+	Runs          Repetitions
+}
+
+// Label identifies the point on the axis of trend plots.
+func (p trendPoint) Label() string {
+	return p.Date.UTC().Format("2006-01-02 15:04")
+}
+
+// trend groups the results of an implementation and testcase, sorted by
+// date, into runs of the same date and images.
+func trend(rs Repetitions) []*trendPoint {
+	var points []*trendPoint
+	for _, r := range rs {
+		sender, receiver := r.Config.Implementation.Sender.Image, r.Config.Implementation.Receiver.Image
+		if n := len(points); n > 0 {
+			last := points[n-1]
+			if last.Date.Equal(r.Config.Date) && last.SenderImage == sender && last.ReceiverImage == receiver {
+				last.Runs = append(last.Runs, r)
+				continue
+			}
+		}
+		points = append(points, &trendPoint{
+			Date:          r.Config.Date,
+			SenderImage:   sender,
+			ReceiverImage: receiver,
+			Runs:          Repetitions{r},
+		})
+	}
+	return points
+}
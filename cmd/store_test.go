@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func storeTestResult(date time.Time, repetition int, ssim float64) *Result {
+	return &Result{
+		Config: Config{
+			Date: date,
+			Implementation: Implementation{
+				Name:     "impl",
+				Sender:   Endpoint{Image: "sender:1"},
+				Receiver: Endpoint{Image: "receiver:1"},
+			},
+			TestCase:   TestCase{Name: "tc"},
+			Repetition: repetition,
+		},
+		Metrics: Metrics{AverageSSIM: ssim},
+	}
+}
+
+func TestResultStore(t *testing.T) {
+	s := resultStore{dir: t.TempDir()}
+	early := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	late := early.Add(time.Hour)
+
+	for _, r := range []*Result{
+		storeTestResult(late, 0, 0.7),
+		storeTestResult(early, 1, 0.8),
+		storeTestResult(early, 0, 0.9),
+	} {
+		added, err := s.add(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !added {
+			t.Errorf("result of %v, repetition %v not added", r.Config.Date, r.Config.Repetition)
+		}
+	}
+
+	path := filepath.Join(s.dir, "impl", "tc", "20210304T050607Z-1.json")
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("result not stored at %v: %v", path, err)
+	}
+
+	added, err := s.add(storeTestResult(early, 0, 0.1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if added {
+		t.Error("result of the same run added twice")
+	}
+
+	results, err := s.load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rs := results["impl"]["tc"]
+	want := []struct {
+		date       time.Time
+		repetition int
+		ssim       float64
+	}{
+		{early, 0, 0.9},
+		{early, 1, 0.8},
+		{late, 0, 0.7},
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("got %v results, want %v", len(rs), len(want))
+	}
+	for i, w := range want {
+		c := rs[i].Config
+		if !c.Date.Equal(w.date) || c.Repetition != w.repetition || rs[i].Metrics.AverageSSIM != w.ssim {
+			t.Errorf("result %v: got %v, repetition %v with SSIM %v, want %v, repetition %v with SSIM %v",
+				i, c.Date, c.Repetition, rs[i].Metrics.AverageSSIM, w.date, w.repetition, w.ssim)
+		}
+	}
+}
+
+func TestResultStoreLoadInvalid(t *testing.T) {
+	s := resultStore{dir: t.TempDir()}
+	if err := os.WriteFile(filepath.Join(s.dir, "result.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, "README"), []byte("not a result"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.load(); err == nil {
+		t.Error("expected error for an invalid result")
+	}
+}
+
+func TestTrend(t *testing.T) {
+	early := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	late := early.Add(time.Hour)
+	updated := storeTestResult(late, 1, 0.6)
+	updated.Config.Implementation.Sender.Image = "sender:2"
+
+	points := trend(Repetitions{
+		storeTestResult(early, 0, 0.9),
+		storeTestResult(early, 1, 0.8),
+		storeTestResult(late, 0, 0.7),
+		updated,
+	})
+	want := []struct {
+		date   time.Time
+		sender string
+		runs   int
+	}{
+		{early, "sender:1", 2},
+		{late, "sender:1", 1},
+		{late, "sender:2", 1},
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %v points, want %v", len(points), len(want))
+	}
+	for i, w := range want {
+		p := points[i]
+		if !p.Date.Equal(w.date) || p.SenderImage != w.sender || p.ReceiverImage != "receiver:1" || len(p.Runs) != w.runs {
+			t.Errorf("point %v: got %v with %v/%v and %v runs, want %v with %v and %v runs",
+				i, p.Date, p.SenderImage, p.ReceiverImage, len(p.Runs), w.date, w.sender, w.runs)
+		}
+	}
+	if got, want := points[0].Label(), "2021-03-04 05:06"; got != want {
+		t.Errorf("got label %q, want %q", got, want)
+	}
+}
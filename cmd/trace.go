@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	traceFormatMahimahi = "mahimahi"
+	traceFormatCSV      = "csv"
+
+	// mahimahiPacketSize is the number of bytes that can be delivered at
+	// each timestamp of a Mahimahi trace.
+	mahimahiPacketSize = 1500
+
+	defaultTraceInterval = 100 * time.Millisecond
+)
+
+// rateSample is a bitrate in bit/s, which is valid from the offset at until
+// the next sample.
+type rateSample struct {
+	at      time.Duration
+	bitrate int
+}
+
+func loadTrace(t Trace) ([]rateSample, error) {
+	file, err := os.Open(t.File)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch t.Format {
+	case traceFormatMahimahi:
+		interval := t.Interval.Duration
+		if interval <= 0 {
+			interval = defaultTraceInterval
+		}
+		return parseMahimahiTrace(file, interval)
+	case traceFormatCSV:
+		return parseCSVTrace(file)
+	default:
+		return nil, fmt.Errorf("unknown trace format: %q", t.Format)
+	}
+}
+
+// parseMahimahiTrace reads a Mahimahi packet delivery trace, in which every
+// line is a millisecond timestamp at which one MTU sized packet can be
+// delivered, and converts it to one bitrate per interval.
+func parseMahimahiTrace(r io.Reader, interval time.Duration) ([]rateSample, error) {
+	var bins []int
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		ms, err := strconv.ParseInt(line, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mahimahi timestamp %q: %w", line, err)
+		}
+		bin := int((time.Duration(ms) * time.Millisecond) / interval)
+		for len(bins) <= bin {
+			bins = append(bins, 0)
+		}
+		bins[bin]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	samples := make([]rateSample, len(bins))
+	for i, count := range bins {
+		samples[i] = rateSample{
+			at:      time.Duration(i) * interval,
+			bitrate: int(float64(count*mahimahiPacketSize*8) / interval.Seconds()),
+		}
+	}
+	return samples, nil
+}
+
+// parseCSVTrace reads a trace of 'time_ms,bitrate' rows. A header row is
+// skipped.
+func parseCSVTrace(r io.Reader) ([]rateSample, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	var samples []rateSample
+	for i := 0; ; i++ {
+		row, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return samples, nil
+			}
+			return nil, err
+		}
+		if len(row) < 2 {
+			return nil, fmt.Errorf("invalid trace row %v: expected 2 columns, got %v", i, len(row))
+		}
+		ms, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid trace timestamp %q: %w", row[0], err)
+		}
+		bitrate, err := strconv.ParseFloat(row[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace bitrate %q: %w", row[1], err)
+		}
+		at := time.Duration(ms) * time.Millisecond
+		if len(samples) > 0 && at < samples[len(samples)-1].at {
+			return nil, fmt.Errorf("trace timestamps not in order at row %v", i)
+		}
+		samples = append(samples, rateSample{
+			at:      at,
+			bitrate: int(bitrate),
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMahimahiTrace(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		input    string
+		interval time.Duration
+		want     []rateSample
+		wantErr  bool
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			interval: 100 * time.Millisecond,
+			want:     []rateSample{},
+		},
+		{
+			name:     "one packet per bin",
+			input:    "0\n100\n200\n",
+			interval: 100 * time.Millisecond,
+			want: []rateSample{
+				{at: 0, bitrate: 120000},
+				{at: 100 * time.Millisecond, bitrate: 120000},
+				{at: 200 * time.Millisecond, bitrate: 120000},
+			},
+		},
+		{
+			name:     "gaps and blank lines",
+			input:    "1\n2\n\n  250  \n",
+			interval: 100 * time.Millisecond,
+			want: []rateSample{
+				{at: 0, bitrate: 240000},
+				{at: 100 * time.Millisecond, bitrate: 0},
+				{at: 200 * time.Millisecond, bitrate: 120000},
+			},
+		},
+		{
+			name:     "one second interval",
+			input:    "0\n999\n",
+			interval: time.Second,
+			want: []rateSample{
+				{at: 0, bitrate: 24000},
+			},
+		},
+		{
+			name:     "missing trailing newline",
+			input:    "0\n10",
+			interval: 100 * time.Millisecond,
+			want: []rateSample{
+				{at: 0, bitrate: 240000},
+			},
+		},
+		{
+			name:     "malformed timestamp",
+			input:    "0\nabc\n",
+			interval: 100 * time.Millisecond,
+			wantErr:  true,
+		},
+		{
+			name:     "truncated number",
+			input:    "0\n1.\n",
+			interval: 100 * time.Millisecond,
+			wantErr:  true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMahimahiTrace(strings.NewReader(tt.input), tt.interval)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCSVTrace(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		input   string
+		want    []rateSample
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			input: "",
+		},
+		{
+			name:  "with header",
+			input: "time_ms,bitrate\n0,1000000\n500, 2000000\n",
+			want: []rateSample{
+				{at: 0, bitrate: 1000000},
+				{at: 500 * time.Millisecond, bitrate: 2000000},
+			},
+		},
+		{
+			name:  "without header",
+			input: "0,1e6\n1000,500000.5\n",
+			want: []rateSample{
+				{at: 0, bitrate: 1000000},
+				{at: time.Second, bitrate: 500000},
+			},
+		},
+		{
+			name:    "truncated row",
+			input:   "0,1000000\n1000\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed timestamp",
+			input:   "0,1000000\nx,1000000\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed bitrate",
+			input:   "0,1000000\n1000,fast\n",
+			wantErr: true,
+		},
+		{
+			name:    "unordered timestamps",
+			input:   "1000,1000000\n0,1000000\n",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated quote",
+			input:   "0,\"1000000\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCSVTrace(strings.NewReader(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
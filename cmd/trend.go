@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/font"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+const (
+	trendDir = "trends"
+
+	trendSSIMPlotFileName          = "%v-%v-trend-ssim.svg"
+	trendPSNRPlotFileName          = "%v-%v-trend-psnr.svg"
+	trendTargetBitratePlotFileName = "%v-%v-trend-target-bitrate.svg"
+)
+
+// IndexTrendLink links a trend page from the index page.
+type IndexTrendLink struct {
+	Implementation string
+	TestCase       string
+	Link           string
+}
+
+// trendRow summarizes the repetitions of a run on the trend page.
+type trendRow struct {
+	Label         string
+	SenderImage   string
+	ReceiverImage string
+	Repetitions   int
+
+	SSIM          Statistic
+	PSNR          Statistic
+	TargetBitrate Statistic
+}
+
+type trendInput struct {
+	Assets         string
+	Implementation string
+	TestCase       string
+	Rows           []trendRow
+
+	SSIMPlotSVG          string
+	PSNRPlotSVG          string
+	TargetBitratePlotSVG string
+}
+
+// buildTrendPages builds a trend page for every implementation and testcase of
+// the result store in storeDir and returns the links to the pages.
+func buildTrendPages(storeDir, outDir string) ([]IndexTrendLink, error) {
+	results, err := resultStore{dir: storeDir}.load()
+	if err != nil {
+		return nil, err
+	}
+	var links []IndexTrendLink
+	for i, t := range results {
+		for tc, rs := range t {
+			link := filepath.Join(trendDir, i, tc)
+			if err := buildTrendPage(i, tc, trend(rs), outDir, link); err != nil {
+				return nil, err
+			}
+			links = append(links, IndexTrendLink{
+				Implementation: i,
+				TestCase:       tc,
+				Link:           link,
+			})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Implementation != links[j].Implementation {
+			return links[i].Implementation < links[j].Implementation
+		}
+		return links[i].TestCase < links[j].TestCase
+	})
+	return links, nil
+}
+
+func buildTrendPage(implementation, testcase string, points []*trendPoint, outDir, link string) error {
+	input := trendInput{
+		Assets:               assetsLink(link),
+		Implementation:       implementation,
+		TestCase:             testcase,
+		SSIMPlotSVG:          fmt.Sprintf(trendSSIMPlotFileName, implementation, testcase),
+		PSNRPlotSVG:          fmt.Sprintf(trendPSNRPlotFileName, implementation, testcase),
+		TargetBitratePlotSVG: fmt.Sprintf(trendTargetBitratePlotFileName, implementation, testcase),
+	}
+	labels := make([]string, len(points))
+	var ssim, psnr, targetBitrate []Statistic
+	for i, p := range points {
+		row := trendRow{
+			Label:         p.Label(),
+			SenderImage:   p.SenderImage,
+			ReceiverImage: p.ReceiverImage,
+			Repetitions:   len(p.Runs),
+			SSIM: p.Runs.statistic(func(m *Metrics) float64 {
+				return m.AverageSSIM
+			}),
+			PSNR: p.Runs.statistic(func(m *Metrics) float64 {
+				return m.AveragePSNR
+			}),
+			TargetBitrate: p.Runs.statistic(func(m *Metrics) float64 {
+				return m.AverageTargetBitrate
+			}),
+		}
+		input.Rows = append(input.Rows, row)
+		labels[i] = row.Label
+		ssim = append(ssim, row.SSIM)
+		psnr = append(psnr, row.PSNR)
+		targetBitrate = append(targetBitrate, row.TargetBitrate)
+	}
+
+	if err := os.MkdirAll(filepath.Join(outDir, link), os.ModePerm); err != nil {
+		return err
+	}
+	for _, t := range []struct {
+		title, unit, filename string
+		values                []Statistic
+	}{
+		{"Average SSIM", "", input.SSIMPlotSVG, ssim},
+		{"Average PSNR", "dB", input.PSNRPlotSVG, psnr},
+		{"Average Target Bitrate", "kbit/s", input.TargetBitratePlotSVG, targetBitrate},
+	} {
+		p, err := plotTrend(t.title, t.unit, labels, t.values)
+		if err != nil {
+			return err
+		}
+		if err = p.Save(width, height, filepath.Join(outDir, link, t.filename)); err != nil {
+			return err
+		}
+	}
+
+	index, err := os.Create(filepath.Join(outDir, link, "index.html"))
+	if err != nil {
+		return err
+	}
+	defer index.Close()
+	return templates.ExecuteTemplate(index, "trend.html", input)
+}
+
+// plotTrend plots the means of values over the runs labeled by labels and
+// shades their 95% confidence intervals.
+func plotTrend(title, unit string, labels []string, values []Statistic) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = title
+	p.Y.Label.Text = unit
+	p.X.Tick.Label.Font = font.From(plot.DefaultFont, 6)
+
+	var ticks plot.ConstantTicks
+	means := make(plotter.XYs, len(values))
+	var upper, lower plotter.XYs
+	for i, v := range values {
+		ticks = append(ticks, plot.Tick{Value: float64(i), Label: labels[i]})
+		means[i] = plotter.XY{X: float64(i), Y: v.Mean}
+		upper = append(upper, plotter.XY{X: float64(i), Y: v.Mean + v.CI95})
+		lower = append([]plotter.XY{{X: float64(i), Y: v.Mean - v.CI95}}, lower...)
+	}
+	p.X.Tick.Marker = ticks
+	p.X.Min = -0.5
+	p.X.Max = float64(len(values)) - 0.5
+
+	if len(values) > 1 {
+		band, err := plotter.NewPolygon(append(upper, lower...))
+		if err != nil {
+			return nil, err
+		}
+		band.Color = color.NRGBA{A: 48}
+		band.LineStyle.Width = 0
+		p.Add(band)
+	}
+	line, err := plotter.NewLine(means)
+	if err != nil {
+		return nil, err
+	}
+	marks, err := plotter.NewScatter(means)
+	if err != nil {
+		return nil, err
+	}
+	marks.GlyphStyle.Radius = vg.Points(2)
+	p.Add(line, marks)
+	return p, nil
+}
@@ -1,10 +1,12 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image/color"
+	"math"
 	"sort"
 	"strconv"
 	"time"
@@ -70,11 +72,101 @@ type TestCase struct {
 	VideoFile VideoFile `json:"videofile"`
 
 	Phases []tcPhase `json:"phases"`
+	Trace  *Trace    `json:"trace,omitempty"`
+	Queue  *Queue    `json:"queue,omitempty"`
+
+	CrossTraffic []CrossTrafficFlow `json:"cross_traffic,omitempty"`
+
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// CrossTrafficFlow is a flow from the sender to the receiver host, which
+// competes with the media flow for the bottleneck. Type is "tcp", "udp" or
+// "implementation". TCP flows use CongestionControl, UDP flows send at a
+// constant Bitrate. Implementation flows run a second instance of the
+// implementation named Implementation, SenderParams and ReceiverParams are
+// appended to its parameters. Both endpoints get the address of the receiver
+// with Port in the RECEIVER variable. The flow starts at Start and runs until
+// Stop or the end of the test, if Stop is 0.
+type CrossTrafficFlow struct {
+	ID    string   `json:"id"`
+	Type  string   `json:"type"`
+	Start Duration `json:"start"`
+	Stop  Duration `json:"stop"`
+	Port  int      `json:"port"`
+
+	Image             string `json:"image,omitempty"`
+	CongestionControl string `json:"congestion_control,omitempty"`
+	Bitrate           int    `json:"bitrate,omitempty"`
+
+	Implementation string          `json:"implementation,omitempty"`
+	SenderParams   string          `json:"sender_params,omitempty"`
+	ReceiverParams string          `json:"receiver_params,omitempty"`
+	Endpoints      *Implementation `json:"endpoints,omitempty"`
 }
 
+// VideoFile is the source video of a testcase. Before a run, the video at
+// URL is downloaded, verified against its SHA256 checksum and converted by
+// ffmpeg to input/<Name>, whose extension selects the container format.
+// PixelFormat, Width, Height and FrameRate set the raw format of the
+// prepared video, Start and Duration the section of the source it contains
+// and Crop the area of the source frames it shows. Unset fields keep the
+// values of the source. Without URL, the video has to be placed in
+// input/<Name> manually. A video in input/<Name> is also kept if neither a
+// checksum nor any of the fields above are declared.
 type VideoFile struct {
 	URL  string `json:"url"`
 	Name string `json:"name"`
+
+	SHA256      string     `json:"sha256,omitempty"`
+	PixelFormat string     `json:"pixel_format,omitempty"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	FrameRate   string     `json:"framerate,omitempty"`
+	Start       Duration   `json:"start"`
+	Duration    Duration   `json:"duration"`
+	Crop        *VideoCrop `json:"crop,omitempty"`
+}
+
+// VideoCrop is the area of Width x Height pixels at offset X, Y of the source
+// frames.
+type VideoCrop struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	X      int `json:"x"`
+	Y      int `json:"y"`
+}
+
+// Trace is a bandwidth trace file which drives the bottleneck bitrate of a
+// testcase. Format is either "mahimahi" or "csv". Interval sets the
+// granularity in which the packet delivery opportunities of Mahimahi traces
+// are converted to bitrates.
+type Trace struct {
+	File     string   `json:"file"`
+	Format   string   `json:"format"`
+	Interval Duration `json:"interval"`
+}
+
+// Queue configures the bottleneck queue. Kind is one of "tbf" (default),
+// "pfifo", "bfifo", "fq_codel", "codel", "pie" or "red". The tbf rate limiter
+// is always installed, all other kinds are attached to it as child qdisc.
+// The buffer size is given in Bytes, Packets or Latency, depending on what the
+// kind supports. Target and Interval configure CoDel and PIE, Min, Max and
+// Probability configure RED.
+type Queue struct {
+	Kind    string   `json:"kind"`
+	Burst   int      `json:"burst,omitempty"`
+	Bytes   int      `json:"bytes,omitempty"`
+	Packets int      `json:"packets,omitempty"`
+	Latency Duration `json:"latency"`
+	ECN     bool     `json:"ecn,omitempty"`
+
+	Target   Duration `json:"target"`
+	Interval Duration `json:"interval"`
+
+	Min         int     `json:"min,omitempty"`
+	Max         int     `json:"max,omitempty"`
+	Probability float64 `json:"probability,omitempty"`
 }
 
 type Config struct {
@@ -82,18 +174,55 @@ type Config struct {
 	DetailsLink    string         `json:"details_link"`
 	Implementation Implementation `json:"implementation"`
 	TestCase       TestCase       `json:"testcase"`
+	Queue          Queue          `json:"queue"`
 	Timeout        time.Duration  `json:"timeout"`
+	// Repetition is the index of the run among the repetitions of the same
+	// implementation and testcase.
+	Repetition int `json:"repetition"`
+	// CPUs limits the CPU time of sender, receiver and cross traffic
+	// combined, zero means unlimited.
+	CPUs float64 `json:"cpus,omitempty"`
 }
 
 type Metrics struct {
 	AverageSSIM          float64 `json:"average_ssim"`
 	AveragePSNR          float64 `json:"average_psnr"`
 	AverageTargetBitrate float64 `json:"average_cc_target_bitrate"`
+	AverageVMAF          float64 `json:"average_vmaf,omitempty"`
 
 	PerFrameSSIM plotter.XYs `json:"per_frame_ssim"`
 	PerFramePSNR plotter.XYs `json:"per_frame_psnr"`
-
-	LinkCapacity plotter.XYs `json:"link_capacity"`
+	PerFrameVMAF plotter.XYs `json:"per_frame_vmaf,omitempty"`
+
+	// DroppedFrames are source frames never shown by the receiver,
+	// DuplicatedFrames and FrozenFrames are received frames repeating the
+	// previous frame for less or at least minFreezeFrames frames. They are
+	// only counted if FramesAligned is set.
+	DroppedFrames    int  `json:"dropped_frames"`
+	DuplicatedFrames int  `json:"duplicated_frames"`
+	FrozenFrames     int  `json:"frozen_frames"`
+	FramesAligned    bool `json:"frames_aligned,omitempty"`
+
+	// LinkCapacity is the forward bitrate of the link in kbit/s.
+	// Utilization is the ratio of the received RTP rate to the link
+	// capacity per second and PhaseUtilization its average per phase.
+	// TimeAboveCapacity is the time in seconds the target bitrate exceeded
+	// the link capacity. ReactionTime and ReconvergenceTime are the average
+	// times in milliseconds the target bitrate needed to adapt to capacity
+	// drops and increases, Oscillation is the standard deviation of the
+	// converged target bitrate in kbit/s and QueueingDelay is the packet
+	// latency exceeding the configured link delay in milliseconds.
+	LinkCapacity         plotter.XYs      `json:"link_capacity"`
+	Utilization          plotter.XYs      `json:"utilization,omitempty"`
+	AverageUtilization   float64          `json:"average_utilization"`
+	PhaseUtilization     []float64        `json:"phase_utilization,omitempty"`
+	TimeAboveCapacity    float64          `json:"time_above_capacity"`
+	CapacityChanges      []CapacityChange `json:"capacity_changes,omitempty"`
+	ReactionTime         float64          `json:"reaction_time"`
+	ReconvergenceTime    float64          `json:"reconvergence_time"`
+	Oscillation          float64          `json:"oscillation"`
+	QueueingDelay        plotter.XYs      `json:"queueing_delay,omitempty"`
+	AverageQueueingDelay float64          `json:"average_queueing_delay"`
 
 	SentRTP  plotter.XYs `json:"sent_rtp"`
 	SentRTCP plotter.XYs `json:"sent_rtcp"`
@@ -101,6 +230,42 @@ type Metrics struct {
 	ReceivedRTP  plotter.XYs `json:"received_rtp"`
 	ReceivedRTCP plotter.XYs `json:"received_rtcp"`
 
+	// PacketLatency and FrameLatency are the one-way delays in milliseconds
+	// of packets and frames over the time they were sent.
+	PacketLatency            plotter.XYs `json:"packet_latency,omitempty"`
+	FrameLatency             plotter.XYs `json:"frame_latency,omitempty"`
+	PacketLatencyPercentiles Percentiles `json:"packet_latency_percentiles"`
+	FrameLatencyPercentiles  Percentiles `json:"frame_latency_percentiles"`
+
+	// LossRate is the fraction of packets lost per second, Duplicates the
+	// number of duplicate packets per second, ReorderExtent the number of
+	// packets a reordered packet arrived late and Jitter the RFC 3550
+	// interarrival jitter in milliseconds.
+	LossRate         plotter.XYs `json:"loss_rate,omitempty"`
+	PacketLoss       float64     `json:"packet_loss"`
+	Duplicates       plotter.XYs `json:"duplicates,omitempty"`
+	DuplicatePackets int         `json:"duplicate_packets"`
+	ReorderExtent    plotter.XYs `json:"reorder_extent,omitempty"`
+	ReorderedPackets int         `json:"reordered_packets"`
+	MaxReorderExtent int         `json:"max_reorder_extent"`
+	Jitter           plotter.XYs `json:"jitter,omitempty"`
+	AverageJitter    float64     `json:"average_jitter"`
+
+	// FeedbackTimeline maps the kinds of RTCP feedback received by the
+	// sender to the times and sizes of the feedback packets.
+	// FeedbackInterval is the time between two feedback packets in
+	// milliseconds and FeedbackOverhead the ratio of feedback bytes to
+	// media bytes per second. ReportFractionLost holds the loss fractions of
+	// the report blocks of receiver and sender reports.
+	FeedbackTimeline        map[string]plotter.XYs `json:"feedback_timeline,omitempty"`
+	FeedbackInterval        plotter.XYs            `json:"feedback_interval,omitempty"`
+	AverageFeedbackInterval float64                `json:"average_feedback_interval"`
+	FeedbackOverhead        plotter.XYs            `json:"feedback_overhead,omitempty"`
+	AverageFeedbackOverhead float64                `json:"average_feedback_overhead"`
+	ReportFractionLost      plotter.XYs            `json:"report_fraction_lost,omitempty"`
+	NACKs                   int                    `json:"nacks"`
+	NACKedPackets           int                    `json:"nacked_packets"`
+
 	QLOGSenderPacketsSent     plotter.XYs `json:"qlog_sender_packets_sent"`
 	QLOGSenderPacketsReceived plotter.XYs `json:"qlog_sender_packets_received"`
 
@@ -109,23 +274,158 @@ type Metrics struct {
 
 	QLOGCongestionWindow plotter.XYs `json:"qlog_congestion_window"`
 
+	// The QLOG recovery metrics of the sender. RTTs are given in
+	// milliseconds, the pacing rate in bits per second and
+	// QLOGPacketsLost is the number of lost packets per second.
+	QLOGSmoothedRTT      plotter.XYs       `json:"qlog_smoothed_rtt,omitempty"`
+	QLOGMinRTT           plotter.XYs       `json:"qlog_min_rtt,omitempty"`
+	QLOGLatestRTT        plotter.XYs       `json:"qlog_latest_rtt,omitempty"`
+	QLOGBytesInFlight    plotter.XYs       `json:"qlog_bytes_in_flight,omitempty"`
+	QLOGPacingRate       plotter.XYs       `json:"qlog_pacing_rate,omitempty"`
+	QLOGPacketsLost      plotter.XYs       `json:"qlog_packets_lost,omitempty"`
+	QLOGLostPackets      int               `json:"qlog_lost_packets"`
+	QLOGCongestionStates []CongestionState `json:"qlog_congestion_states,omitempty"`
+
+	// QLOGSenderConnections and QLOGReceiverConnections hold the series of
+	// every connection if more than one connection was logged. The QLOG
+	// metrics above merge all connections of a role.
+	QLOGSenderConnections   []*QLOGConnection `json:"qlog_sender_connections,omitempty"`
+	QLOGReceiverConnections []*QLOGConnection `json:"qlog_receiver_connections,omitempty"`
+
 	CCTargetBitrate   plotter.XYs `json:"cc_target_bitrate"`
 	CCRateTransmitted plotter.XYs `json:"cc_rate_transmitted"`
 	CCSRTT            plotter.XYs `json:"cc_srtt"`
+
+	// CrossTrafficThroughput maps the cross traffic flow IDs to their
+	// throughput in bytes per second.
+	CrossTrafficThroughput map[string]plotter.XYs `json:"cross_traffic_throughput,omitempty"`
+
+	// FlowThroughput maps the IDs of all flows sharing the bottleneck,
+	// including the media flow, to their throughput in bytes per second.
+	// FairnessIndex is Jain's fairness index of the active flows per second
+	// and PhaseFairnessIndex its average per phase. ThroughputShare is the
+	// fraction of the bytes of all flows transmitted by each flow.
+	// StarvingFlow is the first flow which got less than starvationThreshold
+	// of the fair share while active, StarvationTime the second it starved.
+	FlowThroughput       map[string]plotter.XYs `json:"flow_throughput,omitempty"`
+	FairnessIndex        plotter.XYs            `json:"fairness_index,omitempty"`
+	AverageFairnessIndex float64                `json:"average_fairness_index,omitempty"`
+	PhaseFairnessIndex   []float64              `json:"phase_fairness_index,omitempty"`
+	ThroughputShare      map[string]float64     `json:"throughput_share,omitempty"`
+	StarvingFlow         string                 `json:"starving_flow,omitempty"`
+	StarvationTime       float64                `json:"starvation_time,omitempty"`
+}
+
+// map: "implementation" -> "testcase" -> Results of all repetitions
+type AggregatedResults map[string]map[string]Repetitions
+
+// UnmarshalJSON also reads aggregated files written before repeated runs were
+// supported, which contain a single result per testcase. That result is read
+// as the only repetition.
+func (r *AggregatedResults) UnmarshalJSON(b []byte) error {
+	var raw map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*r = make(AggregatedResults, len(raw))
+	for implementation, testcases := range raw {
+		(*r)[implementation] = make(map[string]Repetitions, len(testcases))
+		for testcase, v := range testcases {
+			var rs Repetitions
+			if v = bytes.TrimSpace(v); len(v) > 0 && v[0] == '{' {
+				rs = Repetitions{new(Result)}
+				if err := json.Unmarshal(v, rs[0]); err != nil {
+					return fmt.Errorf("%v/%v: %w", implementation, testcase, err)
+				}
+			} else if err := json.Unmarshal(v, &rs); err != nil {
+				return fmt.Errorf("%v/%v: %w", implementation, testcase, err)
+			}
+			(*r)[implementation][testcase] = rs
+		}
+	}
+	return nil
 }
 
-// map: "implementation" -> "testcase" -> Result
-type AggregatedResults map[string]map[string]*Result
+// Repetitions are the results of all repetitions of a run, sorted by
+// repetition.
+type Repetitions []*Result
+
+func (r Repetitions) statistic(value func(*Metrics) float64) Statistic {
+	values := make([]float64, len(r))
+	for i, result := range r {
+		values[i] = value(&result.Metrics)
+	}
+	return newStatistic(values)
+}
+
+// filter returns the repetitions whose metrics match keep.
+func (r Repetitions) filter(keep func(*Metrics) bool) Repetitions {
+	var result Repetitions
+	for _, res := range r {
+		if keep(&res.Metrics) {
+			result = append(result, res)
+		}
+	}
+	return result
+}
+
+// notes returns the distinct notes of all repetitions.
+func (r Repetitions) notes() []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, res := range r {
+		for _, n := range res.Notes {
+			if !seen[n] {
+				result = append(result, n)
+				seen[n] = true
+			}
+		}
+	}
+	return result
+}
+
+// assertions summarizes the assertion results of all repetitions by
+// assertion.
+func (r Repetitions) assertions() []assertionDetails {
+	var result []assertionDetails
+	index := map[string]int{}
+	for _, res := range r {
+		for _, a := range res.Assertions {
+			name := a.Assertion.String()
+			i, ok := index[name]
+			if !ok {
+				i = len(result)
+				index[name] = i
+				result = append(result, assertionDetails{Name: name})
+			}
+			result[i].Total++
+			if a.Passed {
+				result[i].Passed++
+			} else {
+				result[i].Messages = append(result[i].Messages, a.Message)
+			}
+		}
+	}
+	return result
+}
+
+func (r Repetitions) series(xys func(*Metrics) plotter.XYs) []plotter.XYs {
+	series := make([]plotter.XYs, len(r))
+	for i, result := range r {
+		series[i] = xys(&result.Metrics)
+	}
+	return series
+}
 
 func (r AggregatedResults) getTableHeaders() []IndexTableHeader {
 	result := []IndexTableHeader{}
 	dupMap := map[string]bool{}
 	for _, t := range r {
-		for name, r := range t {
+		for name, rs := range t {
 			if _, ok := dupMap[name]; !ok {
 				result = append(result, IndexTableHeader{
 					Header:  name,
-					Tooltip: r.Config.TestCase.Name,
+					Tooltip: fmt.Sprintf("%v, bottleneck queue: %v", rs[0].Config.TestCase.Name, rs[0].Config.Queue),
 				})
 				dupMap[name] = true
 			}
@@ -149,12 +449,47 @@ func (r AggregatedResults) getTableRows(header []string) []IndexTableRow {
 		var impl Implementation
 		metrics := make([]*IndexMetric, len(header))
 		for i, h := range header {
-			if run, ok := t[h]; ok {
+			if rs, ok := t[h]; ok {
 				metrics[i] = &IndexMetric{
-					Link:    run.Config.DetailsLink,
-					Metrics: run.Metrics,
+					Link:        rs[0].Config.DetailsLink,
+					TestCase:    h,
+					Repetitions: len(rs),
+					SSIM: rs.statistic(func(m *Metrics) float64 {
+						return m.AverageSSIM
+					}),
+					PSNR: rs.statistic(func(m *Metrics) float64 {
+						return m.AveragePSNR
+					}),
+					TargetBitrate: rs.statistic(func(m *Metrics) float64 {
+						return m.AverageTargetBitrate
+					}),
+					VMAF: rs.filter(hasVMAF).statistic(func(m *Metrics) float64 {
+						return m.AverageVMAF
+					}),
+					Utilization: rs.statistic(func(m *Metrics) float64 {
+						return m.AverageUtilization
+					}),
+					TimeAboveCapacity: rs.statistic(func(m *Metrics) float64 {
+						return m.TimeAboveCapacity
+					}),
+					ReactionTime: rs.statistic(func(m *Metrics) float64 {
+						return m.ReactionTime
+					}),
+					ReconvergenceTime: rs.statistic(func(m *Metrics) float64 {
+						return m.ReconvergenceTime
+					}),
+					Oscillation: rs.statistic(func(m *Metrics) float64 {
+						return m.Oscillation
+					}),
+					QueueingDelay: rs.statistic(func(m *Metrics) float64 {
+						return m.AverageQueueingDelay
+					}),
+				}
+				for _, a := range rs.assertions() {
+					metrics[i].Assertions += a.Total
+					metrics[i].AssertionsPassed += a.Passed
 				}
-				impl = run.Config.Implementation
+				impl = rs[0].Config.Implementation
 			}
 		}
 		next := IndexTableRow{
@@ -173,66 +508,271 @@ func (r AggregatedResults) getTableRows(header []string) []IndexTableRow {
 type Result struct {
 	Config  Config  `json:"config"`
 	Metrics Metrics `json:"metrics"`
+	// Notes explain metrics which could not be calculated.
+	Notes []string `json:"notes,omitempty"`
+	// Assertions are the results of the assertions of the testcase.
+	Assertions []AssertionResult `json:"assertions,omitempty"`
 }
 
-func (t *Metrics) plotPerFrameVideoMetric(name string, data plotter.XYs) (*plot.Plot, error) {
+func hasVMAF(m *Metrics) bool {
+	return len(m.PerFrameVMAF) > 0
+}
+
+func hasFlows(m *Metrics) bool {
+	return len(m.FlowThroughput) > 0
+}
+
+// addRepetitions adds a line in color c for each repetition in series to p
+// and shades the range of the repetitions. It returns the line of the first
+// repetition to be used in the legend.
+func addRepetitions(p *plot.Plot, series []plotter.XYs, c color.Color) (*plotter.Line, error) {
+	if len(series) > 1 {
+		lower, upper := envelope(series)
+		if len(upper) > 0 {
+			band := append(plotter.XYs{}, upper...)
+			for i := len(lower) - 1; i >= 0; i-- {
+				band = append(band, lower[i])
+			}
+			polygon, err := plotter.NewPolygon(band)
+			if err != nil {
+				return nil, err
+			}
+			r, g, b, _ := c.RGBA()
+			polygon.Color = color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: 48}
+			polygon.LineStyle.Width = 0
+			p.Add(polygon)
+		}
+	}
+
+	var first *plotter.Line
+	for _, s := range series {
+		if len(s) == 0 {
+			continue
+		}
+		l, err := plotter.NewLine(s)
+		if err != nil {
+			return nil, err
+		}
+		l.Color = c
+		p.Add(l)
+		if first == nil {
+			first = l
+		}
+	}
+	return first, nil
+}
+
+func plotPerFrameVideoMetric(name string, data []plotter.XYs) (*plot.Plot, error) {
 	p := plot.New()
 	p.Add(plotter.NewGrid())
 	p.Title.Text = fmt.Sprintf("%s per Frame", name)
 	p.X.Label.Text = "Frames"
 	p.Y.Label.Text = name
 
-	l, err := plotter.NewLine(data)
-	if err != nil {
+	if _, err := addRepetitions(p, data, color.Black); err != nil {
 		return nil, err
 	}
-	p.Add(l)
 
 	return p, nil
 }
 
-func (t *Metrics) plotCCBitrate() (*plot.Plot, error) {
+func plotCCBitrate(targetBitrate, rateTransmitted []plotter.XYs, linkCapacity plotter.XYs) (*plot.Plot, error) {
 	p := plot.New()
 	p.Add(plotter.NewGrid())
 	p.Title.Text = "CC Target Bitrate"
 	p.X.Label.Text = "s"
 	p.Y.Label.Text = "kbit/s"
-	p.X.Tick.Marker = fixedSecondsTicker{seconds: []int{0, 60, 120}}
+	p.X.Tick.Marker = secondsTicker{}
 	p.Legend.TextStyle.Font = font.From(plot.DefaultFont, 6)
 	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
 	p.Legend.YOffs = -0.25 * vg.Centimeter
 
-	if t.CCRateTransmitted != nil {
-		rateTransmittedLine, err := plotter.NewLine(t.CCRateTransmitted)
-		if err != nil {
-			return nil, err
-		}
-		rateTransmittedLine.Color = color.RGBA{B: 255, A: 255}
-		p.Add(rateTransmittedLine)
+	rateTransmittedLine, err := addRepetitions(p, rateTransmitted, color.RGBA{B: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+	if rateTransmittedLine != nil {
 		p.Legend.Add("Transmitted", rateTransmittedLine)
 	}
 
-	targetBitrateLine, err := plotter.NewLine(t.CCTargetBitrate)
+	targetBitrateLine, err := addRepetitions(p, targetBitrate, color.RGBA{R: 255, A: 255})
 	if err != nil {
 		return nil, err
 	}
-	targetBitrateLine.Color = color.RGBA{R: 255, A: 255}
-	p.Add(targetBitrateLine)
-	p.Legend.Add("Target", targetBitrateLine)
+	if targetBitrateLine != nil {
+		p.Legend.Add("Target", targetBitrateLine)
+	}
 
-	capacityLine, err := plotter.NewLine(t.LinkCapacity)
+	capacityLine, err := plotter.NewLine(linkCapacity)
 	if err != nil {
 		return nil, err
 	}
 	p.Add(capacityLine)
 	p.Legend.Add("Link Capacity", capacityLine)
 
-	p.Y.Max = 1400 // TODO: Hack to set height for paper output, remove for later experiments
+	return p, nil
+}
+
+func plotLatency(packets, frames []plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = "One-way Delay"
+	p.X.Label.Text = "s"
+	p.Y.Label.Text = "ms"
+	p.X.Tick.Marker = secondsTicker{}
+	p.Legend.TextStyle.Font = font.From(plot.DefaultFont, 6)
+	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
+
+	packetLine, err := addRepetitions(p, packets, color.Gray{Y: 160})
+	if err != nil {
+		return nil, err
+	}
+	if packetLine != nil {
+		p.Legend.Add("Packet", packetLine)
+	}
+	frameLine, err := addRepetitions(p, frames, color.RGBA{R: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+	if frameLine != nil {
+		p.Legend.Add("Frame", frameLine)
+	}
+
+	return p, nil
+}
+
+// plotTimeSeries plots the repetitions of a series whose values are given in
+// unit.
+func plotTimeSeries(title, unit string, ticker plot.Ticker, data []plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = title
+	p.X.Label.Text = "s"
+	p.Y.Label.Text = unit
+	p.X.Tick.Marker = ticker
+
+	if _, err := addRepetitions(p, data, color.Black); err != nil {
+		return nil, err
+	}
 
 	return p, nil
 }
 
-func (t *Metrics) plotSRTT() (*plot.Plot, error) {
+// plotFeedbackTimeline plots a row of marks for every kind of feedback at
+// the times the feedback was received. Repetitions are stacked within the
+// row of a kind.
+func plotFeedbackTimeline(timelines []map[string]plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = "RTCP Feedback Timeline"
+	p.X.Label.Text = "s"
+	p.X.Tick.Marker = secondsTicker{}
+
+	var ticks plot.ConstantTicks
+	for row, kind := range rtcpFeedbackKinds {
+		ticks = append(ticks, plot.Tick{Value: float64(row), Label: kind})
+		for i, timeline := range timelines {
+			if len(timeline[kind]) == 0 {
+				continue
+			}
+			marks := make(plotter.XYs, len(timeline[kind]))
+			for j, xy := range timeline[kind] {
+				marks[j] = plotter.XY{
+					X: xy.X,
+					Y: float64(row) + 0.6*(float64(i)+0.5)/float64(len(timelines)) - 0.3,
+				}
+			}
+			s, err := plotter.NewScatter(marks)
+			if err != nil {
+				return nil, err
+			}
+			s.GlyphStyle.Radius = vg.Points(1)
+			p.Add(s)
+		}
+	}
+	p.Y.Tick.Marker = ticks
+	p.Y.Min = -0.5
+	p.Y.Max = float64(len(rtcpFeedbackKinds)) - 0.5
+
+	return p, nil
+}
+
+// congestionStateColors are the colors of the regions of the congestion
+// states defined by qlog.
+var congestionStateColors = map[string]color.Color{
+	"slow_start":           color.NRGBA{G: 160, A: 40},
+	"congestion_avoidance": color.NRGBA{B: 255, A: 30},
+	"application_limited":  color.NRGBA{R: 128, G: 128, B: 128, A: 40},
+	"recovery":             color.NRGBA{R: 255, G: 140, A: 50},
+}
+
+// plotWithCongestionStates plots the repetitions of every series with its
+// label and shades the congestion states in the background. As the states of
+// repetitions differ, only the states of a single repetition should be given.
+func plotWithCongestionStates(title, unit string, states []CongestionState, labels []string, series ...[]plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = title
+	p.X.Label.Text = "s"
+	p.Y.Label.Text = unit
+	p.X.Tick.Marker = secondsTicker{}
+	p.Legend.TextStyle.Font = font.From(plot.DefaultFont, 6)
+	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
+
+	ymax := 0.0
+	for _, s := range series {
+		for _, xys := range s {
+			if len(xys) == 0 {
+				continue
+			}
+			_, _, _, max := plotter.XYRange(xys)
+			ymax = math.Max(ymax, max)
+		}
+	}
+
+	inLegend := map[string]bool{}
+	for _, s := range states {
+		c, ok := congestionStateColors[s.State]
+		if !ok {
+			c = color.NRGBA{A: 20}
+		}
+		region, err := plotter.NewPolygon(plotter.XYs{
+			{X: s.Start, Y: 0},
+			{X: s.End, Y: 0},
+			{X: s.End, Y: ymax},
+			{X: s.Start, Y: ymax},
+		})
+		if err != nil {
+			return nil, err
+		}
+		region.Color = c
+		region.LineStyle.Width = 0
+		p.Add(region)
+		if !inLegend[s.State] {
+			p.Legend.Add(s.State, region)
+			inLegend[s.State] = true
+		}
+	}
+
+	colors := []color.Color{
+		color.Black,
+		color.RGBA{R: 255, A: 255},
+		color.RGBA{B: 255, A: 255},
+	}
+	for i, s := range series {
+		l, err := addRepetitions(p, s, colors[i%len(colors)])
+		if err != nil {
+			return nil, err
+		}
+		if l != nil && i < len(labels) {
+			p.Legend.Add(labels[i], l)
+		}
+	}
+
+	return p, nil
+}
+
+func plotSRTT(data []plotter.XYs) (*plot.Plot, error) {
 	p := plot.New()
 	p.Add(plotter.NewGrid())
 	p.Title.Text = "CC RTT"
@@ -240,16 +780,80 @@ func (t *Metrics) plotSRTT() (*plot.Plot, error) {
 	p.Y.Label.Text = "s"
 	p.X.Tick.Marker = secondsTicker{}
 
-	l1, err := plotter.NewLine(t.CCSRTT)
-	if err != nil {
+	if _, err := addRepetitions(p, data, color.Black); err != nil {
 		return nil, err
 	}
-	p.Add(l1)
 
 	return p, nil
 }
 
-func plotMetric(title string, ticker plot.Ticker, data plotter.XYs) (*plot.Plot, error) {
+// flowColors are the colors of the flows in the stacked throughput plot and
+// of the implementations in overlay plots.
+var flowColors = []color.Color{
+	color.NRGBA{R: 31, G: 119, B: 180, A: 200},
+	color.NRGBA{R: 255, G: 127, B: 14, A: 200},
+	color.NRGBA{R: 44, G: 160, B: 44, A: 200},
+	color.NRGBA{R: 214, G: 39, B: 40, A: 200},
+	color.NRGBA{R: 148, G: 103, B: 189, A: 200},
+	color.NRGBA{R: 140, G: 86, B: 75, A: 200},
+}
+
+// plotStackedThroughput stacks the throughput of the flows in bytes per
+// second in kbit/s on top of each other and draws the link capacity in kbit/s
+// over milliseconds above them.
+func plotStackedThroughput(flows map[string]plotter.XYs, linkCapacity plotter.XYs) (*plot.Plot, error) {
+	p := plot.New()
+	p.Add(plotter.NewGrid())
+	p.Title.Text = "Throughput"
+	p.X.Label.Text = "s"
+	p.Y.Label.Text = "kbit/s"
+	p.Legend.TextStyle.Font = font.From(plot.DefaultFont, 6)
+	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
+
+	var stack []float64
+	for i, id := range flowIDs(flows) {
+		var upper, lower plotter.XYs
+		for s, xy := range flows[id] {
+			if s >= len(stack) {
+				stack = append(stack, 0)
+			}
+			lower = append(lower, plotter.XY{X: xy.X, Y: stack[s]})
+			stack[s] += xy.Y * 8 / 1000
+			upper = append(upper, plotter.XY{X: xy.X, Y: stack[s]})
+		}
+		if len(upper) == 0 {
+			continue
+		}
+		for j := len(lower) - 1; j >= 0; j-- {
+			upper = append(upper, lower[j])
+		}
+		area, err := plotter.NewPolygon(upper)
+		if err != nil {
+			return nil, err
+		}
+		area.Color = flowColors[i%len(flowColors)]
+		area.LineStyle.Width = 0
+		p.Add(area)
+		p.Legend.Add(id, area)
+	}
+
+	if len(linkCapacity) > 0 {
+		capacity := make(plotter.XYs, len(linkCapacity))
+		for i, xy := range linkCapacity {
+			capacity[i] = plotter.XY{X: xy.X / 1000, Y: xy.Y}
+		}
+		capacityLine, err := plotter.NewLine(capacity)
+		if err != nil {
+			return nil, err
+		}
+		p.Add(capacityLine)
+		p.Legend.Add("Link Capacity", capacityLine)
+	}
+
+	return p, nil
+}
+
+func plotMetric(title string, ticker plot.Ticker, data []plotter.XYs) (*plot.Plot, error) {
 	p := plot.New()
 	p.Add(plotter.NewGrid())
 	p.Title.Text = title
@@ -260,17 +864,19 @@ func plotMetric(title string, ticker plot.Ticker, data plotter.XYs) (*plot.Plot,
 	p.Legend.ThumbnailWidth = 0.4 * vg.Centimeter
 	p.X.Tick.Marker = ticker
 
-	var l *plotter.Line
-	var err error
-
-	l, err = plotter.NewLine(data)
-	if err != nil {
+	if _, err := addRepetitions(p, data, color.Black); err != nil {
 		return nil, err
 	}
-	p.Add(l)
-	//p.Legend.Add(title, l)
 
-	_, _, ymin, ymax := plotter.XYRange(data)
+	ymin, ymax := math.Inf(1), math.Inf(-1)
+	for _, xys := range data {
+		if len(xys) == 0 {
+			continue
+		}
+		_, _, min, max := plotter.XYRange(xys)
+		ymin = math.Min(ymin, min)
+		ymax = math.Max(ymax, max)
+	}
 
 	if ymax <= ymin {
 		ymin = 0
@@ -281,21 +887,6 @@ func plotMetric(title string, ticker plot.Ticker, data plotter.XYs) (*plot.Plot,
 	return p, nil
 }
 
-type fixedSecondsTicker struct {
-	seconds []int
-}
-
-func (f fixedSecondsTicker) Ticks(min, max float64) []plot.Tick {
-	var result []plot.Tick
-	for _, s := range f.seconds {
-		result = append(result, plot.Tick{
-			Value: float64(1000 * s),
-			Label: fmt.Sprintf("%v", s),
-		})
-	}
-	return result
-}
-
 type secondsTicker struct{}
 
 func (secondsTicker) Ticks(min, max float64) []plot.Tick {
@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// inputDir is the directory of the source videos. It is mounted to /input in
+// the sender containers and the evaluation compares the received videos to
+// its videos.
+const inputDir = "input"
+
+// videoCache is a content-addressed cache of videos. Downloaded videos are
+// stored in <dir>/sha256/<checksum>, videos prepared from them in
+// <dir>/prepared/<key>/<name>, where key is the checksum of the source and the
+// preparation parameters. <dir>/urls/<checksum of URL> holds the checksum of
+// the last download of a URL without declared checksum.
+type videoCache struct {
+	dir string
+}
+
+// prepareVideos prepares the videos of all testcases ts in the input
+// directory. Testcases without URL use the video already placed in the input
+// directory.
+func prepareVideos(ts []TestCase, cacheDir string) error {
+	cache := videoCache{dir: cacheDir}
+	prepared := map[string]string{}
+	for _, t := range ts {
+		v := t.VideoFile
+		if v.URL == "" {
+			continue
+		}
+		key := v.URL + "|" + v.preparationKey()
+		if k, ok := prepared[v.Name]; ok {
+			if k != key {
+				return fmt.Errorf("testcase %v: video %v is prepared differently by another testcase", t.Name, v.Name)
+			}
+			continue
+		}
+		if err := cache.prepare(v, inputDir); err != nil {
+			return fmt.Errorf("testcase %v: failed to prepare video %v: %w", t.Name, v.Name, err)
+		}
+		prepared[v.Name] = key
+	}
+	return nil
+}
+
+// preparationKey identifies the preparation of v in the cache. It does not
+// include the URL, as the content of the source is identified by its checksum.
+func (v VideoFile) preparationKey() string {
+	crop := ""
+	if v.Crop != nil {
+		crop = v.Crop.filter()
+	}
+	return fmt.Sprintf("%v|%v|%v|%vx%v|%v|%v|%v|%v", v.Name, v.SHA256, v.PixelFormat, v.Width, v.Height, v.FrameRate, v.Start, v.Duration, crop)
+}
+
+// declaresFormat reports whether v declares any property of the prepared
+// video.
+func (v VideoFile) declaresFormat() bool {
+	return v.PixelFormat != "" || v.Width > 0 || v.Height > 0 || v.FrameRate != "" ||
+		v.Start.Duration > 0 || v.Duration.Duration > 0 || v.Crop != nil
+}
+
+// needsTranscoding reports whether the source of v has to be transcoded with
+// ffmpeg or can be used as it is.
+func (v VideoFile) needsTranscoding() bool {
+	return v.declaresFormat() || !strings.EqualFold(filepath.Ext(v.Name), filepath.Ext(v.URL))
+}
+
+func (c VideoCrop) filter() string {
+	return fmt.Sprintf("crop=%v:%v:%v:%v", c.Width, c.Height, c.X, c.Y)
+}
+
+// prepare fetches and prepares v, if it is not cached, and links the prepared
+// video to dir/v.Name. If v declares neither a checksum nor a format, a video
+// already present in dir is kept, as it can not be told whether it was
+// prepared from the URL.
+func (c videoCache) prepare(v VideoFile, dir string) error {
+	target := filepath.Join(dir, v.Name)
+	if v.SHA256 == "" && !v.declaresFormat() {
+		if _, err := os.Stat(target); err == nil {
+			log.Printf("using %v, no checksum or format declared to prepare it from %v\n", target, v.URL)
+			return nil
+		}
+	}
+	if crop := v.Crop; crop != nil && (crop.Width <= 0 || crop.Height <= 0 || crop.X < 0 || crop.Y < 0) {
+		return fmt.Errorf("invalid crop of %v: %+v", v.Name, *crop)
+	}
+
+	source, checksum, err := c.fetch(v.URL, v.SHA256)
+	if err != nil {
+		return err
+	}
+
+	prepared := source
+	if v.needsTranscoding() {
+		key := sha256.Sum256([]byte(checksum + "|" + v.preparationKey()))
+		prepared = filepath.Join(c.dir, "prepared", hex.EncodeToString(key[:]), v.Name)
+		if _, err := os.Stat(prepared); os.IsNotExist(err) {
+			log.Printf("transcoding %v to %v\n", v.URL, prepared)
+			if err := transcodeVideo(source, prepared, v); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// Hard links do not work across file systems, copy the video then.
+	if err := os.Link(prepared, target); err != nil {
+		return copyFile(prepared, target)
+	}
+	return nil
+}
+
+// fetch returns the path of the cached content of url and its checksum. If
+// a checksum is declared, the content is verified and only downloaded if it
+// is not cached yet. Otherwise the last download of url is used.
+func (c videoCache) fetch(url, declared string) (string, string, error) {
+	declared = strings.ToLower(declared)
+	known := declared
+	if known == "" {
+		log.Printf("no checksum declared for %v, the video is not verified\n", url)
+		if data, err := ioutil.ReadFile(c.urlPath(url)); err == nil {
+			known = strings.TrimSpace(string(data))
+		}
+	}
+	if known != "" {
+		if _, err := os.Stat(c.contentPath(known)); err == nil {
+			return c.contentPath(known), known, nil
+		} else if !os.IsNotExist(err) {
+			return "", "", err
+		}
+	}
+
+	checksum, err := c.download(url)
+	if err != nil {
+		return "", "", err
+	}
+	if declared != "" && checksum != declared {
+		os.Remove(c.contentPath(checksum))
+		return "", "", fmt.Errorf("checksum mismatch of %v: expected %v, got %v", url, declared, checksum)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.urlPath(url)), os.ModePerm); err != nil {
+		return "", "", err
+	}
+	if err := ioutil.WriteFile(c.urlPath(url), []byte(checksum+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return c.contentPath(checksum), checksum, nil
+}
+
+func (c videoCache) contentPath(checksum string) string {
+	return filepath.Join(c.dir, "sha256", checksum)
+}
+
+func (c videoCache) urlPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, "urls", hex.EncodeToString(sum[:]))
+}
+
+// download stores the content of url in the cache and returns its checksum.
+// Besides HTTP(S), file:// URLs of local files are supported.
+func (c videoCache) download(url string) (string, error) {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	transport.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	client := &http.Client{Transport: transport}
+
+	log.Printf("downloading %v\n", url)
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %v: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %v: %v", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Join(c.dir, "sha256"), os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(filepath.Join(c.dir, "sha256"), "download-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	hash := sha256.New()
+	if _, err = io.Copy(tmp, io.TeeReader(resp.Body, hash)); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to download %v: %w", url, err)
+	}
+	if err = tmp.Close(); err != nil {
+		return "", err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	return checksum, os.Rename(tmp.Name(), c.contentPath(checksum))
+}
+
+// transcodeVideo converts source to the raw format, resolution, frame rate
+// and duration declared by v and writes it to target. The container format
+// is chosen by ffmpeg from the extension of target.
+func transcodeVideo(source, target string, v VideoFile) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	// Write to a temporary file with the same extension first, so that
+	// interrupted transcodings are not cached.
+	tmp := filepath.Join(filepath.Dir(target), "tmp-"+filepath.Base(target))
+
+	var stderr bytes.Buffer
+	ffmpeg := exec.Command("ffmpeg", transcodeArgs(source, tmp, v)...)
+	ffmpeg.Stderr = &stderr
+	if err := ffmpeg.Run(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to transcode %v: %w: %v", source, err, stderr.String())
+	}
+	return os.Rename(tmp, target)
+}
+
+// transcodeArgs returns the ffmpeg arguments to transcode source to target.
+// The source is cropped before it is scaled, so that the crop is given in
+// pixels of the source.
+func transcodeArgs(source, target string, v VideoFile) []string {
+	args := []string{"-hide_banner", "-v", "error", "-y"}
+	if v.Start.Duration > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%f", v.Start.Seconds()))
+	}
+	args = append(args, "-i", source)
+	if v.Duration.Duration > 0 {
+		args = append(args, "-t", fmt.Sprintf("%f", v.Duration.Seconds()))
+	}
+	var filters []string
+	if v.Crop != nil {
+		filters = append(filters, v.Crop.filter())
+	}
+	if v.Width > 0 || v.Height > 0 {
+		w, h := v.Width, v.Height
+		if w <= 0 {
+			w = -2
+		}
+		if h <= 0 {
+			h = -2
+		}
+		filters = append(filters, fmt.Sprintf("scale=%v:%v", w, h))
+	}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
+	}
+	if v.FrameRate != "" {
+		args = append(args, "-r", v.FrameRate)
+	}
+	if v.PixelFormat != "" {
+		args = append(args, "-pix_fmt", v.PixelFormat)
+	}
+	return append(args, "-an", target)
+}
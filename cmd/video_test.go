@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const videoTestContent = "YUV4MPEG2 W2 H2 F25:1 C420\nFRAME\nabcdef"
+
+func videoTestChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// videoTestServer serves content at /video.y4m and counts the requests.
+func videoTestServer(t *testing.T, content string) (*httptest.Server, *int32) {
+	var requests int32
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path != "/video.y4m" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+	return s, &requests
+}
+
+func readVideoTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestPrepareVideoFile(t *testing.T) {
+	source := writeTestFile(t, "source.y4m", videoTestContent)
+	c := videoCache{dir: t.TempDir()}
+	dir := t.TempDir()
+	v := VideoFile{URL: "file://" + source, Name: "video.y4m", SHA256: videoTestChecksum(videoTestContent)}
+	if err := c.prepare(v, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readVideoTestFile(t, filepath.Join(dir, v.Name)); got != videoTestContent {
+		t.Errorf("got %q, want %q", got, videoTestContent)
+	}
+
+	// The cached video is used once the source is gone.
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	other := t.TempDir()
+	if err := c.prepare(v, other); err != nil {
+		t.Fatalf("cached video not used: %v", err)
+	}
+	if got := readVideoTestFile(t, filepath.Join(other, v.Name)); got != videoTestContent {
+		t.Errorf("got %q, want %q", got, videoTestContent)
+	}
+}
+
+func TestPrepareVideoHTTP(t *testing.T) {
+	s, requests := videoTestServer(t, videoTestContent)
+	c := videoCache{dir: t.TempDir()}
+	dir := t.TempDir()
+	v := VideoFile{URL: s.URL + "/video.y4m", Name: "video.y4m", SHA256: strings.ToUpper(videoTestChecksum(videoTestContent))}
+	for i := 0; i < 2; i++ {
+		if err := c.prepare(v, dir); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := readVideoTestFile(t, filepath.Join(dir, v.Name)); got != videoTestContent {
+			t.Errorf("got %q, want %q", got, videoTestContent)
+		}
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("got %v requests, want a single download", n)
+	}
+
+	missing := VideoFile{URL: s.URL + "/missing.y4m", Name: "missing.y4m"}
+	if err := c.prepare(missing, dir); err == nil {
+		t.Error("expected error for a missing video")
+	}
+}
+
+func TestPrepareVideoChecksumMismatch(t *testing.T) {
+	s, _ := videoTestServer(t, videoTestContent)
+	c := videoCache{dir: t.TempDir()}
+	dir := t.TempDir()
+	v := VideoFile{URL: s.URL + "/video.y4m", Name: "video.y4m", SHA256: videoTestChecksum("other content")}
+	err := c.prepare(v, dir)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, v.Name)); !os.IsNotExist(err) {
+		t.Errorf("video of mismatching checksum was prepared: %v", err)
+	}
+	if _, err := os.Stat(c.contentPath(videoTestChecksum(videoTestContent))); !os.IsNotExist(err) {
+		t.Errorf("video of mismatching checksum was cached: %v", err)
+	}
+}
+
+func TestPrepareVideoWithoutChecksum(t *testing.T) {
+	s, requests := videoTestServer(t, videoTestContent)
+	c := videoCache{dir: t.TempDir()}
+	dir := t.TempDir()
+	v := VideoFile{URL: s.URL + "/video.y4m", Name: "video.y4m"}
+
+	if err := c.prepare(v, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readVideoTestFile(t, filepath.Join(dir, v.Name)); got != videoTestContent {
+		t.Errorf("got %q, want %q", got, videoTestContent)
+	}
+
+	// A video placed in the input directory is not replaced without a
+	// declared checksum or format.
+	placed := filepath.Join(t.TempDir(), v.Name)
+	if err := ioutil.WriteFile(placed, []byte("placed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.prepare(v, filepath.Dir(placed)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readVideoTestFile(t, placed); got != "placed" {
+		t.Errorf("placed video was replaced by %q", got)
+	}
+
+	// Without the target, the last download of the URL is used.
+	other := t.TempDir()
+	if err := c.prepare(v, other); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := readVideoTestFile(t, filepath.Join(other, v.Name)); got != videoTestContent {
+		t.Errorf("got %q, want %q", got, videoTestContent)
+	}
+	if n := atomic.LoadInt32(requests); n != 1 {
+		t.Errorf("got %v requests, want a single download", n)
+	}
+}
+
+func TestPrepareVideoInvalidCrop(t *testing.T) {
+	c := videoCache{dir: t.TempDir()}
+	v := VideoFile{URL: "file:///missing.mkv", Name: "video.y4m", Crop: &VideoCrop{Width: 0, Height: 10}}
+	err := c.prepare(v, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "invalid crop") {
+		t.Errorf("expected invalid crop, got %v", err)
+	}
+}
+
+func TestTranscodeArgs(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		v    VideoFile
+		want string
+	}{
+		{
+			name: "container only",
+			v:    VideoFile{URL: "http://host/video.mkv", Name: "video.y4m"},
+			want: "-hide_banner -v error -y -i source.mkv -an target.y4m",
+		},
+		{
+			name: "raw format",
+			v: VideoFile{
+				PixelFormat: "yuv420p",
+				Width:       1280,
+				FrameRate:   "25",
+				Start:       Duration{10 * time.Second},
+				Duration:    Duration{90 * time.Second},
+			},
+			want: "-hide_banner -v error -y -ss 10.000000 -i source.mkv -t 90.000000 -vf scale=1280:-2 -r 25 -pix_fmt yuv420p -an target.y4m",
+		},
+		{
+			name: "crop and scale",
+			v: VideoFile{
+				Width:  640,
+				Height: 360,
+				Crop:   &VideoCrop{Width: 1280, Height: 720, X: 320, Y: 180},
+			},
+			want: "-hide_banner -v error -y -i source.mkv -vf crop=1280:720:320:180,scale=640:360 -an target.y4m",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transcodeArgs("source.mkv", "target.y4m", tt.v)
+			if want := strings.Fields(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestNeedsTranscoding(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		v    VideoFile
+		want bool
+	}{
+		{name: "same container", v: VideoFile{URL: "http://host/a.Y4M", Name: "b.y4m"}},
+		{name: "other container", v: VideoFile{URL: "http://host/a.mkv", Name: "b.y4m"}, want: true},
+		{name: "crop", v: VideoFile{URL: "http://host/a.y4m", Name: "b.y4m", Crop: &VideoCrop{Width: 1, Height: 1}}, want: true},
+		{name: "duration", v: VideoFile{URL: "http://host/a.y4m", Name: "b.y4m", Duration: Duration{time.Second}}, want: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.v.needsTranscoding(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+// Interactive plots of the HTML report. rtqPlots renders the plots emitted by
+// `build --interactive` into a container. Every plot can be zoomed with the
+// mouse wheel (holding shift zooms the y axis), panned by dragging and reset
+// by double clicking. Hovering shows the nearest point and the legend toggles
+// the series.
+(function () {
+  "use strict";
+
+  var colors = [
+    "#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd",
+    "#8c564b", "#e377c2", "#7f7f7f", "#bcbd22", "#17becf"
+  ];
+
+  var margin = { top: 10, right: 10, bottom: 40, left: 60 };
+
+  // niceTicks returns about count rounded tick values between min and max.
+  function niceTicks(min, max, count) {
+    var span = max - min;
+    if (!(span > 0)) {
+      return [min];
+    }
+    var step = Math.pow(10, Math.floor(Math.log10(span / count)));
+    var err = count / span * step;
+    if (err <= 0.15) {
+      step *= 10;
+    } else if (err <= 0.35) {
+      step *= 5;
+    } else if (err <= 0.75) {
+      step *= 2;
+    }
+    var ticks = [];
+    for (var t = Math.ceil(min / step) * step; t <= max + step / 1e6; t += step) {
+      ticks.push(Math.abs(t) < step / 1e6 ? 0 : t);
+    }
+    return ticks;
+  }
+
+  function format(v) {
+    if (v === 0) {
+      return "0";
+    }
+    var a = Math.abs(v);
+    if (a >= 1e6 || a < 1e-3) {
+      return v.toExponential(2);
+    }
+    return String(Math.round(v * 1000) / 1000);
+  }
+
+  // extent returns the range of the points of the visible series.
+  function extent(series, hidden) {
+    var e = { xmin: Infinity, xmax: -Infinity, ymin: Infinity, ymax: -Infinity };
+    series.forEach(function (s, i) {
+      if (hidden[i]) {
+        return;
+      }
+      s.points.forEach(function (p) {
+        e.xmin = Math.min(e.xmin, p[0]);
+        e.xmax = Math.max(e.xmax, p[0]);
+        e.ymin = Math.min(e.ymin, p[1]);
+        e.ymax = Math.max(e.ymax, p[1]);
+      });
+    });
+    if (!isFinite(e.xmin)) {
+      return { xmin: 0, xmax: 1, ymin: 0, ymax: 1 };
+    }
+    if (e.xmin === e.xmax) {
+      e.xmin -= 1;
+      e.xmax += 1;
+    }
+    if (e.ymin === e.ymax) {
+      e.ymin -= 1;
+      e.ymax += 1;
+    }
+    var pad = (e.ymax - e.ymin) * 0.05;
+    e.ymin = e.ymin >= 0 && e.ymin - pad < 0 ? 0 : e.ymin - pad;
+    e.ymax += pad;
+    return e;
+  }
+
+  // firstAtOrAfter returns the index of the first point with x >= x, the
+  // points are sorted by x.
+  function firstAtOrAfter(points, x) {
+    var lo = 0;
+    var hi = points.length;
+    while (lo < hi) {
+      var mid = (lo + hi) >> 1;
+      if (points[mid][0] < x) {
+        lo = mid + 1;
+      } else {
+        hi = mid;
+      }
+    }
+    return lo;
+  }
+
+  function isSorted(points) {
+    for (var i = 1; i < points.length; i++) {
+      if (points[i][0] < points[i - 1][0]) {
+        return false;
+      }
+    }
+    return true;
+  }
+
+  function Plot(container, plot) {
+    var self = this;
+    this.plot = plot;
+    this.hidden = plot.series.map(function () { return false; });
+    plot.series.forEach(function (s) {
+      if (!isSorted(s.points)) {
+        s.points = s.points.slice().sort(function (a, b) { return a[0] - b[0]; });
+      }
+    });
+
+    var figure = document.createElement("div");
+    figure.className = "rtq-plot";
+    var title = document.createElement("h4");
+    title.textContent = plot.title;
+    figure.appendChild(title);
+    this.canvas = document.createElement("canvas");
+    figure.appendChild(this.canvas);
+    this.tooltip = document.createElement("div");
+    this.tooltip.className = "rtq-plot-tooltip";
+    figure.appendChild(this.tooltip);
+
+    var legend = document.createElement("div");
+    legend.className = "rtq-plot-legend";
+    plot.series.forEach(function (s, i) {
+      var label = document.createElement("label");
+      var checkbox = document.createElement("input");
+      checkbox.type = "checkbox";
+      checkbox.checked = true;
+      checkbox.addEventListener("change", function () {
+        self.hidden[i] = !checkbox.checked;
+        self.draw();
+      });
+      var swatch = document.createElement("span");
+      swatch.className = "rtq-swatch";
+      swatch.style.backgroundColor = colors[i % colors.length];
+      label.appendChild(checkbox);
+      label.appendChild(swatch);
+      label.appendChild(document.createTextNode(s.name));
+      legend.appendChild(label);
+    });
+    figure.appendChild(legend);
+    container.appendChild(figure);
+
+    this.reset();
+    this.listen();
+    window.addEventListener("resize", function () { self.draw(); });
+  }
+
+  Plot.prototype.reset = function () {
+    this.view = extent(this.plot.series, this.hidden);
+    this.draw();
+  };
+
+  Plot.prototype.area = function () {
+    return {
+      x: margin.left,
+      y: margin.top,
+      w: this.canvas.clientWidth - margin.left - margin.right,
+      h: this.canvas.clientHeight - margin.top - margin.bottom
+    };
+  };
+
+  Plot.prototype.toScreen = function (p) {
+    var a = this.area();
+    var v = this.view;
+    return [
+      a.x + (p[0] - v.xmin) / (v.xmax - v.xmin) * a.w,
+      a.y + a.h - (p[1] - v.ymin) / (v.ymax - v.ymin) * a.h
+    ];
+  };
+
+  Plot.prototype.draw = function () {
+    var canvas = this.canvas;
+    var ratio = window.devicePixelRatio || 1;
+    canvas.width = canvas.clientWidth * ratio;
+    canvas.height = canvas.clientHeight * ratio;
+    var ctx = canvas.getContext("2d");
+    ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+    ctx.clearRect(0, 0, canvas.clientWidth, canvas.clientHeight);
+
+    var a = this.area();
+    var v = this.view;
+    var self = this;
+
+    ctx.font = "11px sans-serif";
+    ctx.strokeStyle = "#e0e0e0";
+    ctx.fillStyle = "#212529";
+    ctx.lineWidth = 1;
+    ctx.textAlign = "center";
+    ctx.textBaseline = "top";
+    niceTicks(v.xmin, v.xmax, 8).forEach(function (t) {
+      var x = self.toScreen([t, v.ymin])[0];
+      ctx.beginPath();
+      ctx.moveTo(x, a.y);
+      ctx.lineTo(x, a.y + a.h);
+      ctx.stroke();
+      ctx.fillText(format(t), x, a.y + a.h + 4);
+    });
+    ctx.textAlign = "right";
+    ctx.textBaseline = "middle";
+    niceTicks(v.ymin, v.ymax, 6).forEach(function (t) {
+      var y = self.toScreen([v.xmin, t])[1];
+      ctx.beginPath();
+      ctx.moveTo(a.x, y);
+      ctx.lineTo(a.x + a.w, y);
+      ctx.stroke();
+      ctx.fillText(format(t), a.x - 4, y);
+    });
+    ctx.textAlign = "center";
+    ctx.textBaseline = "bottom";
+    ctx.fillText(this.plot.x_label, a.x + a.w / 2, canvas.clientHeight - 2);
+    ctx.save();
+    ctx.translate(12, a.y + a.h / 2);
+    ctx.rotate(-Math.PI / 2);
+    ctx.textBaseline = "middle";
+    ctx.fillText(this.plot.y_label, 0, 0);
+    ctx.restore();
+    ctx.strokeStyle = "#212529";
+    ctx.strokeRect(a.x, a.y, a.w, a.h);
+
+    ctx.save();
+    ctx.beginPath();
+    ctx.rect(a.x, a.y, a.w, a.h);
+    ctx.clip();
+    this.plot.series.forEach(function (s, i) {
+      if (self.hidden[i] || s.points.length === 0) {
+        return;
+      }
+      var first = Math.max(0, firstAtOrAfter(s.points, v.xmin) - 1);
+      var last = Math.min(s.points.length, firstAtOrAfter(s.points, v.xmax) + 1);
+      ctx.strokeStyle = colors[i % colors.length];
+      ctx.fillStyle = ctx.strokeStyle;
+      ctx.lineWidth = 1.25;
+      if (last - first === 1) {
+        var p = self.toScreen(s.points[first]);
+        ctx.fillRect(p[0] - 1.5, p[1] - 1.5, 3, 3);
+        return;
+      }
+      ctx.beginPath();
+      for (var j = first; j < last; j++) {
+        var q = self.toScreen(s.points[j]);
+        if (j === first) {
+          ctx.moveTo(q[0], q[1]);
+        } else {
+          ctx.lineTo(q[0], q[1]);
+        }
+      }
+      ctx.stroke();
+    });
+    ctx.restore();
+  };
+
+  // nearest returns the visible point closest to the screen position x, y.
+  Plot.prototype.nearest = function (x, y) {
+    var a = this.area();
+    var v = this.view;
+    var dataX = v.xmin + (x - a.x) / a.w * (v.xmax - v.xmin);
+    var best = null;
+    var self = this;
+    this.plot.series.forEach(function (s, i) {
+      if (self.hidden[i] || s.points.length === 0) {
+        return;
+      }
+      var k = firstAtOrAfter(s.points, dataX);
+      for (var j = Math.max(0, k - 2); j < Math.min(s.points.length, k + 2); j++) {
+        var p = self.toScreen(s.points[j]);
+        var d = Math.hypot(p[0] - x, p[1] - y);
+        if (best === null || d < best.distance) {
+          best = { distance: d, series: i, point: s.points[j], screen: p };
+        }
+      }
+    });
+    return best;
+  };
+
+  Plot.prototype.listen = function () {
+    var self = this;
+    var canvas = this.canvas;
+    var drag = null;
+
+    canvas.addEventListener("wheel", function (e) {
+      e.preventDefault();
+      var a = self.area();
+      var v = self.view;
+      var factor = e.deltaY < 0 ? 0.8 : 1.25;
+      var rect = canvas.getBoundingClientRect();
+      if (e.shiftKey) {
+        var cy = v.ymax - (e.clientY - rect.top - a.y) / a.h * (v.ymax - v.ymin);
+        v.ymin = cy - (cy - v.ymin) * factor;
+        v.ymax = cy + (v.ymax - cy) * factor;
+      } else {
+        var cx = v.xmin + (e.clientX - rect.left - a.x) / a.w * (v.xmax - v.xmin);
+        v.xmin = cx - (cx - v.xmin) * factor;
+        v.xmax = cx + (v.xmax - cx) * factor;
+      }
+      self.draw();
+    }, { passive: false });
+
+    canvas.addEventListener("pointerdown", function (e) {
+      drag = { x: e.clientX, y: e.clientY, view: Object.assign({}, self.view) };
+      canvas.setPointerCapture(e.pointerId);
+      canvas.classList.add("rtq-dragging");
+      self.tooltip.style.display = "none";
+    });
+
+    canvas.addEventListener("pointermove", function (e) {
+      var a = self.area();
+      if (drag) {
+        var dx = (e.clientX - drag.x) / a.w * (drag.view.xmax - drag.view.xmin);
+        var dy = (e.clientY - drag.y) / a.h * (drag.view.ymax - drag.view.ymin);
+        self.view = {
+          xmin: drag.view.xmin - dx,
+          xmax: drag.view.xmax - dx,
+          ymin: drag.view.ymin + dy,
+          ymax: drag.view.ymax + dy
+        };
+        self.draw();
+        return;
+      }
+      var rect = canvas.getBoundingClientRect();
+      var best = self.nearest(e.clientX - rect.left, e.clientY - rect.top);
+      if (best === null || best.distance > 20) {
+        self.tooltip.style.display = "none";
+        return;
+      }
+      self.tooltip.textContent = self.plot.series[best.series].name + ": " +
+        format(best.point[0]) + " " + self.plot.x_label + ", " +
+        format(best.point[1]) + " " + self.plot.y_label;
+      self.tooltip.style.left = (canvas.offsetLeft + best.screen[0] + 8) + "px";
+      self.tooltip.style.top = (canvas.offsetTop + best.screen[1] + 8) + "px";
+      self.tooltip.style.display = "block";
+    });
+
+    function endDrag() {
+      drag = null;
+      canvas.classList.remove("rtq-dragging");
+    }
+    canvas.addEventListener("pointerup", endDrag);
+    canvas.addEventListener("pointercancel", endDrag);
+    canvas.addEventListener("pointerleave", function () {
+      self.tooltip.style.display = "none";
+    });
+    canvas.addEventListener("dblclick", function () {
+      self.reset();
+    });
+  };
+
+  window.rtqPlots = function (container, plots) {
+    (plots || []).forEach(function (plot) {
+      new Plot(container, plot);
+    });
+  };
+})();